@@ -9,7 +9,8 @@ import (
 )
 
 type DB struct {
-	pool *db.DB
+	pool     *db.DB
+	snapshot *dbSnapshot
 }
 
 type Tx struct {
@@ -190,21 +191,7 @@ func (db *DB) MakeId(table string, idField string, idSize uint) (string, error)
 	var id string
 	var err error
 	for i:=0; i<100; i++ {
-		if idSize > 20 {
-			id = u.UniqueId()
-		} else if idSize > 14 {
-			id = u.UniqueId()[0:idSize]
-		} else if idSize > 12 {
-			id = u.ShortUniqueId()[0:idSize]
-		} else if idSize > 10 {
-			id = u.Id12()[0:idSize]
-		} else if idSize > 8 {
-			id = u.Id10()[0:idSize]
-		} else if idSize >= 6 {
-			id = u.Id8()[0:idSize]
-		} else {
-			id = u.Id6()
-		}
+		id = makeId(idSize)
 		r := db.pool.Query(fmt.Sprintf("SELECT COUNT(*) FROM `%s` WHERE `%s`=?", table, idField), id)
 		err = r.Error
 		if r.IntOnR1C1() == 0 {
@@ -214,6 +201,24 @@ func (db *DB) MakeId(table string, idField string, idSize uint) (string, error)
 	return id, err
 }
 
+func makeId(idSize uint) string {
+	if idSize > 20 {
+		return u.UniqueId()
+	} else if idSize > 14 {
+		return u.UniqueId()[0:idSize]
+	} else if idSize > 12 {
+		return u.ShortUniqueId()[0:idSize]
+	} else if idSize > 10 {
+		return u.Id12()[0:idSize]
+	} else if idSize > 8 {
+		return u.Id10()[0:idSize]
+	} else if idSize >= 6 {
+		return u.Id8()[0:idSize]
+	} else {
+		return u.Id6()
+	}
+}
+
 // Commit 提交事务
 func (tx *Tx) Commit() error {
 	return tx.conn.Commit()
@@ -0,0 +1,500 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ssgo/u"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type dbSnapshot struct {
+	tables []string
+	data   map[string][]map[string]interface{}
+}
+
+var fixtureTplMatcher = regexp.MustCompile(`\{\{\s*([^}]+?)\s*\}\}`)
+var fixtureTimeMatcher = regexp.MustCompile(`^(now|today|ts|tsMs)\s*(([+-])\s*(\d+)\s*(ms|s|m|h|d|w))?$`)
+
+// LoadFixtures 加载测试数据（清空涉及的表，按照外键依赖顺序插入数据）
+// * fixtures 存放fixture文件的目录（文件名为表名，支持.yml、.yaml、.json）或者 {表名: [数据, ...]} 格式的对象
+// LoadFixtures 数据支持数组或 {别名: 数据} 格式，字符串中可以使用模版：{{id}}、{{id:12}} 生成ID，{{now}}、{{now-1d}}、{{today+2h}} 生成时间，{{ts}}、{{tsMs+30s}} 生成时间戳，{{ref:表名.别名.字段}} 引用其他行的值（包括插入后生成的自增ID）
+// LoadFixtures 同一张表内引用了其他别名的行在被引用的行之后插入
+// LoadFixtures 没有提供数据但通过外键引用了这些表的表也会被清空，否则无法删除被引用的数据
+// LoadFixtures return 插入的总行数
+func (db *DB) LoadFixtures(fixtures interface{}) (int64, error) {
+	var tableData map[string]interface{}
+	if dirname, ok := fixtures.(string); ok {
+		var err error
+		if tableData, err = readFixtureDir(dirname); err != nil {
+			return 0, err
+		}
+	} else {
+		tableData = map[string]interface{}{}
+		u.Convert(fixtures, &tableData)
+	}
+
+	tables := make([]string, 0, len(tableData))
+	for table := range tableData {
+		tables = append(tables, table)
+	}
+	tables, err := db.addDependentTables(tables)
+	if err != nil {
+		return 0, err
+	}
+	if tables, err = db.sortTablesByDepends(tables); err != nil {
+		return 0, err
+	}
+
+	tx := db.pool.Begin()
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	defer tx.CheckFinished()
+
+	for i := len(tables) - 1; i >= 0; i-- {
+		quotedTable, err := quoteTableName(tables[i])
+		if err != nil {
+			return 0, err
+		}
+		if r := tx.Exec("DELETE FROM " + quotedTable); r.Error != nil {
+			return 0, r.Error
+		}
+	}
+
+	refs := map[string]map[string]interface{}{}
+	var n int64
+	for _, table := range tables {
+		if tableData[table] == nil {
+			continue
+		}
+		idField, err := db.autoIncrementField(table)
+		if err != nil {
+			return n, err
+		}
+		aliases, rows := makeFixtureRows(tableData[table])
+		order, err := sortFixtureRows(table, aliases, rows)
+		if err != nil {
+			return n, err
+		}
+		for _, i := range order {
+			row := rows[i]
+			data := map[string]interface{}{}
+			for k, v := range row {
+				if data[k], err = makeFixtureValue(v, refs); err != nil {
+					return n, fmt.Errorf("fixture %s.%s: %s", table, aliases[i], err.Error())
+				}
+			}
+			r := tx.Insert(table, data)
+			if r.Error != nil {
+				return n, r.Error
+			}
+			// 没有指定自增ID时记录数据库生成的ID，使 {{ref:表名.别名.id}} 可以引用
+			if idField != "" && data[idField] == nil {
+				data[idField] = r.Id()
+			}
+			refs[table+"."+aliases[i]] = data
+			n++
+		}
+	}
+	return n, tx.Commit()
+}
+
+// Snapshot 保存当前的数据快照，用于在测试用例之间通过 restore 恢复数据
+// Snapshot tables 需要保存的表，不指定时保存所有的表，通过外键引用了这些表的表也会一起保存
+func (db *DB) Snapshot(tables ...string) error {
+	var err error
+	if len(tables) == 0 {
+		if tables, err = db.listTables(); err != nil {
+			return err
+		}
+	} else if tables, err = db.addDependentTables(tables); err != nil {
+		return err
+	}
+	if tables, err = db.sortTablesByDepends(tables); err != nil {
+		return err
+	}
+
+	snapshot := &dbSnapshot{tables: tables, data: map[string][]map[string]interface{}{}}
+	for _, table := range tables {
+		quotedTable, err := quoteTableName(table)
+		if err != nil {
+			return err
+		}
+		r := db.pool.Query("SELECT * FROM " + quotedTable)
+		if r.Error != nil {
+			return r.Error
+		}
+		snapshot.data[table] = r.MapResults()
+	}
+	db.snapshot = snapshot
+	return nil
+}
+
+// Restore 将数据恢复到最后一次 snapshot 时的状态
+func (db *DB) Restore() error {
+	if db.snapshot == nil {
+		return errors.New("no snapshot to restore")
+	}
+
+	tx := db.pool.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.CheckFinished()
+
+	tables := db.snapshot.tables
+	for i := len(tables) - 1; i >= 0; i-- {
+		quotedTable, err := quoteTableName(tables[i])
+		if err != nil {
+			return err
+		}
+		if r := tx.Exec("DELETE FROM " + quotedTable); r.Error != nil {
+			return r.Error
+		}
+	}
+	for _, table := range tables {
+		for _, row := range db.snapshot.data[table] {
+			if r := tx.Insert(table, row); r.Error != nil {
+				return r.Error
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func readFixtureDir(dirname string) (map[string]interface{}, error) {
+	files, err := os.ReadDir(dirname)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	for _, f := range files {
+		ext := path.Ext(f.Name())
+		if f.IsDir() || (ext != ".yml" && ext != ".yaml" && ext != ".json") {
+			continue
+		}
+		buf, err := u.ReadFileBytes(path.Join(dirname, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		// JSON是YAML的子集，统一使用YAML解析
+		var data interface{}
+		if err = yaml.Unmarshal(buf, &data); err != nil {
+			return nil, fmt.Errorf("fixture %s: %s", f.Name(), err.Error())
+		}
+		out[strings.TrimSuffix(f.Name(), ext)] = data
+	}
+	return out, nil
+}
+
+func makeFixtureRows(data interface{}) ([]string, []map[string]interface{}) {
+	aliases := make([]string, 0)
+	rows := make([]map[string]interface{}, 0)
+	if list, ok := data.([]interface{}); ok {
+		for i, item := range list {
+			row := map[string]interface{}{}
+			u.Convert(item, &row)
+			aliases = append(aliases, u.String(i))
+			rows = append(rows, row)
+		}
+	} else {
+		named := map[string]map[string]interface{}{}
+		u.Convert(data, &named)
+		for alias := range named {
+			aliases = append(aliases, alias)
+		}
+		sort.Strings(aliases)
+		for _, alias := range aliases {
+			rows = append(rows, named[alias])
+		}
+	}
+	return aliases, rows
+}
+
+// sortFixtureRows 按照同一张表内的引用排序，被 {{ref:表名.别名.字段}} 引用的行先插入，返回行的下标
+func sortFixtureRows(table string, aliases []string, rows []map[string]interface{}) ([]int, error) {
+	index := map[string]int{}
+	for i, alias := range aliases {
+		index[alias] = i
+	}
+
+	out := make([]int, 0, len(rows))
+	state := make([]int, len(rows))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case 1:
+			return fmt.Errorf("fixture %s.%s: circular ref", table, aliases[i])
+		case 2:
+			return nil
+		}
+		state[i] = 1
+		for _, v := range rows[i] {
+			str, ok := v.(string)
+			if !ok {
+				continue
+			}
+			for _, m := range fixtureTplMatcher.FindAllStringSubmatch(str, -1) {
+				tpl := strings.TrimSpace(m[1])
+				if !strings.HasPrefix(tpl, "ref:"+table+".") {
+					continue
+				}
+				name := tpl[len(table)+5:]
+				pos := strings.LastIndexByte(name, '.')
+				if pos == -1 {
+					continue
+				}
+				if refIndex, found := index[name[0:pos]]; found {
+					if err := visit(refIndex); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		state[i] = 2
+		out = append(out, i)
+		return nil
+	}
+	for i := range rows {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func makeFixtureValue(v interface{}, refs map[string]map[string]interface{}) (interface{}, error) {
+	var str string
+	switch realValue := v.(type) {
+	case string:
+		str = realValue
+	case map[string]interface{}, []interface{}:
+		// 对象和数组以JSON格式存储
+		return u.Json(v), nil
+	default:
+		return v, nil
+	}
+
+	var err error
+	apply := func(tpl string) interface{} {
+		tpl = strings.TrimSpace(tpl)
+		switch {
+		case tpl == "id":
+			return u.UniqueId()
+		case strings.HasPrefix(tpl, "id:"):
+			return makeId(u.Uint(tpl[3:]))
+		case strings.HasPrefix(tpl, "ref:"):
+			name := tpl[4:]
+			pos := strings.LastIndexByte(name, '.')
+			if pos == -1 || refs[name[0:pos]] == nil {
+				err = fmt.Errorf("bad ref %s", name)
+				return nil
+			}
+			return refs[name[0:pos]][name[pos+1:]]
+		}
+		if m := fixtureTimeMatcher.FindStringSubmatch(tpl); m != nil {
+			tm := time.Now()
+			if m[1] == "today" {
+				tm = time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, tm.Location())
+			}
+			if m[2] != "" {
+				offset := time.Duration(u.Int64(m[4]))
+				switch m[5] {
+				case "ms":
+					offset *= time.Millisecond
+				case "s":
+					offset *= time.Second
+				case "m":
+					offset *= time.Minute
+				case "h":
+					offset *= time.Hour
+				case "d":
+					offset *= time.Hour * 24
+				case "w":
+					offset *= time.Hour * 24 * 7
+				}
+				if m[3] == "-" {
+					offset = -offset
+				}
+				tm = tm.Add(offset)
+			}
+			switch m[1] {
+			case "ts":
+				return tm.Unix()
+			case "tsMs":
+				return tm.UnixNano() / int64(time.Millisecond)
+			}
+			return tm.Format("2006-01-02 15:04:05")
+		}
+		err = fmt.Errorf("unknown template {{%s}}", tpl)
+		return nil
+	}
+
+	// 整个值是一个模版时保留原始类型
+	if m := fixtureTplMatcher.FindStringSubmatch(str); m != nil && m[0] == str {
+		out := apply(m[1])
+		return out, err
+	}
+	out := fixtureTplMatcher.ReplaceAllStringFunc(str, func(s string) string {
+		return u.String(apply(fixtureTplMatcher.FindStringSubmatch(s)[1]))
+	})
+	return out, err
+}
+
+func (db *DB) listTables() ([]string, error) {
+	requestSql := "SHOW TABLES"
+	if db.isSqlite() {
+		requestSql = "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'"
+	}
+	r := db.pool.Query(requestSql)
+	return r.StringsOnC1(), r.Error
+}
+
+// foreignKeyTables 获取表通过外键引用的其他表
+func (db *DB) foreignKeyTables(table string) ([]string, error) {
+	requestSql := "SELECT DISTINCT REFERENCED_TABLE_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME=? AND REFERENCED_TABLE_NAME IS NOT NULL"
+	args := []interface{}{table}
+	if db.isSqlite() {
+		requestSql = fmt.Sprintf("SELECT DISTINCT `table` FROM pragma_foreign_key_list('%s')", strings.ReplaceAll(table, "'", "''"))
+		args = nil
+	}
+	r := db.pool.Query(requestSql, args...)
+	return r.StringsOnC1(), r.Error
+}
+
+// addDependentTables 添加通过外键（直接或间接）引用了这些表的其他表，删除被引用的数据前需要先清空它们
+func (db *DB) addDependentTables(tables []string) ([]string, error) {
+	allTables, err := db.listTables()
+	if err != nil {
+		return nil, err
+	}
+	refs := map[string][]string{}
+	for _, table := range allTables {
+		if refs[table], err = db.foreignKeyTables(table); err != nil {
+			return nil, err
+		}
+	}
+
+	inList := map[string]bool{}
+	out := make([]string, 0, len(tables))
+	for _, table := range tables {
+		if !inList[table] {
+			inList[table] = true
+			out = append(out, table)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, table := range allTables {
+			if inList[table] {
+				continue
+			}
+			for _, refTable := range refs[table] {
+				if inList[refTable] {
+					inList[table] = true
+					out = append(out, table)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// autoIncrementField 获取表的自增字段，没有时返回空字符串
+func (db *DB) autoIncrementField(table string) (string, error) {
+	if db.isSqlite() {
+		// 只有一个 INTEGER 主键时它是 rowid 的别名，插入时自动生成
+		r := db.pool.Query(fmt.Sprintf("SELECT `name`, `type` FROM pragma_table_info('%s') WHERE `pk`>0", strings.ReplaceAll(table, "'", "''")))
+		if r.Error != nil {
+			return "", r.Error
+		}
+		fields := r.StringMapResults()
+		if len(fields) == 1 && strings.ToUpper(fields[0]["type"]) == "INTEGER" {
+			return fields[0]["name"], nil
+		}
+		return "", nil
+	}
+	r := db.pool.Query("SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME=? AND EXTRA LIKE '%auto_increment%'", table)
+	if r.Error != nil {
+		return "", r.Error
+	}
+	if fields := r.StringsOnC1(); len(fields) > 0 {
+		return fields[0], nil
+	}
+	return "", nil
+}
+
+// sortTablesByDepends 按照外键依赖排序，被引用的表排在前面
+func (db *DB) sortTablesByDepends(tables []string) ([]string, error) {
+	sort.Strings(tables)
+	inList := map[string]bool{}
+	for _, table := range tables {
+		inList[table] = true
+	}
+
+	depends := map[string][]string{}
+	for _, table := range tables {
+		refTables, err := db.foreignKeyTables(table)
+		if err != nil {
+			return nil, err
+		}
+		for _, refTable := range refTables {
+			if refTable != table && inList[refTable] {
+				depends[table] = append(depends[table], refTable)
+			}
+		}
+	}
+
+	out := make([]string, 0, len(tables))
+	state := map[string]int{}
+	var visit func(table string) error
+	visit = func(table string) error {
+		switch state[table] {
+		case 1:
+			return fmt.Errorf("circular foreign key on table %s", table)
+		case 2:
+			return nil
+		}
+		state[table] = 1
+		for _, refTable := range depends[table] {
+			if err := visit(refTable); err != nil {
+				return err
+			}
+		}
+		state[table] = 2
+		out = append(out, table)
+		return nil
+	}
+	for _, table := range tables {
+		if err := visit(table); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (db *DB) isSqlite() bool {
+	return strings.HasPrefix(db.pool.Config.Type, "sqlite")
+}
+
+func quoteTableName(table string) (string, error) {
+	if table == "" {
+		return "", errors.New("empty table name")
+	}
+	if table[0] == '`' {
+		return table, nil
+	}
+	a := strings.SplitN(table, ".", 2)
+	for i := range a {
+		a[i] = "`" + a[i] + "`"
+	}
+	return strings.Join(a, "."), nil
+}
@@ -0,0 +1,206 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/ssgo/db"
+)
+
+// memorySqliteDriver 使用名称作为共享内存数据库的名称，sqlite3:// 的地址无法表示 file:xxx?mode=memory
+type memorySqliteDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+func (d *memorySqliteDriver) Open(name string) (driver.Conn, error) {
+	return d.SQLiteDriver.Open("file:" + name + "?mode=memory&cache=shared&_foreign_keys=1")
+}
+
+func init() {
+	sql.Register("sqlitemem", &memorySqliteDriver{})
+}
+
+// newTestDB 创建 user、order、orderItem 三张表，user 通过 managerId 引用自己，orderItem 引用 order，order 引用 user
+func newTestDB(t *testing.T) *DB {
+	d := &DB{pool: db.GetDB("sqlitemem://"+strings.ReplaceAll(t.Name(), "/", "_"), nil)}
+	for _, s := range []string{
+		"CREATE TABLE `user` (`id` INTEGER PRIMARY KEY, `name` TEXT, `managerId` INTEGER REFERENCES `user`(`id`))",
+		"CREATE TABLE `order` (`id` INTEGER PRIMARY KEY, `userId` INTEGER NOT NULL REFERENCES `user`(`id`), `title` TEXT)",
+		"CREATE TABLE `orderItem` (`orderId` INTEGER NOT NULL REFERENCES `order`(`id`), `sku` TEXT)",
+	} {
+		if r := d.pool.Exec(s); r.Error != nil {
+			t.Fatal(r.Error)
+		}
+	}
+	return d
+}
+
+func queryRows(t *testing.T, d *DB, requestSql string) []map[string]string {
+	r := d.pool.Query(requestSql)
+	if r.Error != nil {
+		t.Fatal(r.Error)
+	}
+	return r.StringMapResults()
+}
+
+func TestLoadFixtures(t *testing.T) {
+	d := newTestDB(t)
+	d.pool.Exec("INSERT INTO `user` (`id`, `name`) VALUES (99, 'old')")
+	d.pool.Exec("INSERT INTO `order` (`id`, `userId`, `title`) VALUES (99, 99, 'old')")
+	d.pool.Exec("INSERT INTO `orderItem` (`orderId`, `sku`) VALUES (99, 'old')")
+
+	n, err := d.LoadFixtures(map[string]interface{}{
+		"order": map[string]interface{}{
+			"first": map[string]interface{}{"userId": "{{ref:user.alice.id}}", "title": "order of {{ref:user.alice.name}}"},
+		},
+		"user": map[string]interface{}{
+			// alice 排在 bob 前面，但是引用了 bob 插入后生成的ID
+			"alice": map[string]interface{}{"name": "Alice", "managerId": "{{ref:user.bob.id}}"},
+			"bob":   map[string]interface{}{"name": "Bob"},
+			"carol": map[string]interface{}{"id": 100, "name": "Carol"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Fatal("inserted rows", n)
+	}
+	// 没有提供数据的依赖表被清空，否则无法删除 order
+	if items := queryRows(t, d, "SELECT * FROM `orderItem`"); len(items) != 0 {
+		t.Fatal("dependent table not cleared", items)
+	}
+	users := queryRows(t, d, "SELECT `u`.`name`, `m`.`name` AS `manager` FROM `user` `u` LEFT JOIN `user` `m` ON `m`.`id`=`u`.`managerId` ORDER BY `u`.`name`")
+	if len(users) != 3 || users[0]["name"] != "Alice" || users[0]["manager"] != "Bob" {
+		t.Fatal("bad ref to later alias", users)
+	}
+	orders := queryRows(t, d, "SELECT `o`.`title`, `u`.`name` FROM `order` `o` JOIN `user` `u` ON `u`.`id`=`o`.`userId`")
+	if len(orders) != 1 || orders[0]["name"] != "Alice" || orders[0]["title"] != "order of Alice" {
+		t.Fatal("bad ref to auto increment id", orders)
+	}
+}
+
+func TestLoadFixturesDir(t *testing.T) {
+	d := newTestDB(t)
+	dir := t.TempDir()
+	_ = os.WriteFile(path.Join(dir, "user.yml"), []byte("- name: Alice\n- name: Bob\n"), 0644)
+	_ = os.WriteFile(path.Join(dir, "order.json"), []byte(`[{"userId": "{{ref:user.1.id}}", "title": "{{ts}}"}]`), 0644)
+	_ = os.WriteFile(path.Join(dir, "readme.txt"), []byte("ignored"), 0644)
+
+	if _, err := d.LoadFixtures(dir); err != nil {
+		t.Fatal(err)
+	}
+	orders := queryRows(t, d, "SELECT `u`.`name` FROM `order` `o` JOIN `user` `u` ON `u`.`id`=`o`.`userId`")
+	if len(queryRows(t, d, "SELECT * FROM `user`")) != 2 || len(orders) != 1 || orders[0]["name"] != "Bob" {
+		t.Fatal("bad fixture dir", orders)
+	}
+}
+
+func TestLoadFixturesErrors(t *testing.T) {
+	d := newTestDB(t)
+	tests := []struct {
+		name     string
+		fixtures map[string]interface{}
+	}{
+		{"empty table name", map[string]interface{}{"": []interface{}{map[string]interface{}{"name": "x"}}}},
+		{"bad ref", map[string]interface{}{"user": []interface{}{map[string]interface{}{"name": "{{ref:user.none.id}}"}}}},
+		{"circular ref", map[string]interface{}{"user": map[string]interface{}{
+			"a": map[string]interface{}{"managerId": "{{ref:user.b.id}}"},
+			"b": map[string]interface{}{"managerId": "{{ref:user.a.id}}"},
+		}}},
+		{"unknown template", map[string]interface{}{"user": []interface{}{map[string]interface{}{"name": "{{what}}"}}}},
+		{"foreign key", map[string]interface{}{"order": []interface{}{map[string]interface{}{"userId": 12345}}}},
+	}
+	for _, test := range tests {
+		if _, err := d.LoadFixtures(test.fixtures); err == nil {
+			t.Error(test.name, "should fail")
+		}
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	d := newTestDB(t)
+	if _, err := d.LoadFixtures(map[string]interface{}{
+		"user":      []interface{}{map[string]interface{}{"name": "Alice"}},
+		"order":     []interface{}{map[string]interface{}{"userId": "{{ref:user.0.id}}", "title": "a"}},
+		"orderItem": []interface{}{map[string]interface{}{"orderId": "{{ref:order.0.id}}", "sku": "x"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// 只指定 user 时引用它的表也会保存，恢复时才能删除 user
+	if err := d.Snapshot("user"); err != nil {
+		t.Fatal(err)
+	}
+	d.pool.Exec("INSERT INTO `user` (`name`) VALUES ('Bob')")
+	d.pool.Exec("INSERT INTO `orderItem` (`orderId`, `sku`) SELECT `id`, 'y' FROM `order`")
+	if err := d.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	users := queryRows(t, d, "SELECT `name` FROM `user`")
+	items := queryRows(t, d, "SELECT `sku` FROM `orderItem`")
+	if len(users) != 1 || users[0]["name"] != "Alice" || len(items) != 1 || items[0]["sku"] != "x" {
+		t.Fatal("bad restore", users, items)
+	}
+	if err := d.Snapshot(""); err == nil {
+		t.Fatal("snapshot of empty table name should fail")
+	}
+}
+
+func TestMakeFixtureValue(t *testing.T) {
+	refs := map[string]map[string]interface{}{"user.alice": {"id": int64(7), "name": "Alice"}}
+	now := time.Now()
+	tests := []struct {
+		value  interface{}
+		expect interface{}
+	}{
+		{"{{ref:user.alice.id}}", int64(7)},
+		{"id={{ref:user.alice.id}}", "id=7"},
+		{123, 123},
+		{map[string]interface{}{"a": 1}, `{"a":1}`},
+		{"{{ts}}", now.Unix()},
+		{"{{ts+1d}}", now.Add(24 * time.Hour).Unix()},
+		{"{{ts - 2h}}", now.Add(-2 * time.Hour).Unix()},
+		{"{{today}}", time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Format("2006-01-02 15:04:05")},
+	}
+	for _, test := range tests {
+		out, err := makeFixtureValue(test.value, refs)
+		if err != nil {
+			t.Error(test.value, err)
+		} else if n, ok := out.(int64); ok && test.expect != out {
+			// 跨秒时允许1秒的误差
+			if expect, _ := test.expect.(int64); n-expect > 1 || expect-n > 1 {
+				t.Error(test.value, out, test.expect)
+			}
+		} else if !ok && out != test.expect {
+			t.Error(test.value, out, test.expect)
+		}
+	}
+	if out, _ := makeFixtureValue("{{id:12}}", refs); len(out.(string)) != 12 {
+		t.Error("bad id", out)
+	}
+}
+
+func TestQuoteTableName(t *testing.T) {
+	tests := []struct {
+		table  string
+		expect string
+		ok     bool
+	}{
+		{"user", "`user`", true},
+		{"test.user", "`test`.`user`", true},
+		{"`user`", "`user`", true},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		out, err := quoteTableName(test.table)
+		if out != test.expect || (err == nil) != test.ok {
+			t.Error(test.table, out, err)
+		}
+	}
+}
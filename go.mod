@@ -6,7 +6,9 @@ require (
 	github.com/ZZMarquis/gm v1.3.2
 	github.com/api-go/plugin v1.0.4
 	github.com/emmansun/gmsm v0.21.1
+	github.com/gomodule/redigo v1.8.8
 	github.com/gorilla/websocket v1.5.1
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/obscuren/ecies v0.0.0-20150213224233-7c0f4a9b18d9
 	github.com/ssgo/db v0.6.11
 	github.com/ssgo/discover v0.6.11
@@ -14,16 +16,17 @@ require (
 	github.com/ssgo/log v0.6.11
 	github.com/ssgo/redis v0.6.11
 	github.com/ssgo/u v0.6.11
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/go-sql-driver/mysql v1.5.0 // indirect
-	github.com/gomodule/redigo v1.8.8 // indirect
 	github.com/mitchellh/mapstructure v1.4.1 // indirect
 	github.com/ssgo/config v0.6.11 // indirect
 	github.com/ssgo/standard v0.6.11 // indirect
 	github.com/stretchr/testify v1.8.1 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	golang.org/x/crypto v0.14.0 // indirect
 	golang.org/x/net v0.17.0 // indirect
 	golang.org/x/sys v0.13.0 // indirect
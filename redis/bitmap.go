@@ -0,0 +1,137 @@
+package redis
+
+import (
+	"strings"
+
+	"github.com/ssgo/u"
+)
+
+type bitRangeOption struct {
+	Start *int64
+	End   *int64
+	Unit  string
+}
+
+type bitFieldOperation struct {
+	Op        string
+	Type      string
+	Offset    interface{}
+	Value     int64
+	Increment int64
+	Behavior  string
+}
+
+// SetBit 设置位图中指定偏移量上的位
+// SetBit value 0 或 1
+// SetBit return 原来的位
+func (rd *Redis) SetBit(key string, offset int64, value int) int64 {
+	reply, _ := rd.doRaw("SETBIT", rd.makeKey(key), offset, value)
+	return replyInt(reply)
+}
+
+// GetBit 获取位图中指定偏移量上的位
+// GetBit return 0 或 1，超出长度时返回0
+func (rd *Redis) GetBit(key string, offset int64) int64 {
+	reply, _ := rd.doRaw("GETBIT", rd.makeKey(key), offset)
+	return replyInt(reply)
+}
+
+// BitCount 统计位图中值为1的位的数量
+// BitCount options 选项 {start, end, unit}，unit 为 BYTE（默认）或 BIT，只指定 start 时 end 默认为-1（到结尾）
+func (rd *Redis) BitCount(key string, options *map[string]interface{}) int64 {
+	args := append([]interface{}{rd.makeKey(key)}, makeBitRangeArgs(options, false)...)
+	reply, _ := rd.doRaw("BITCOUNT", args...)
+	return replyInt(reply)
+}
+
+// BitPos 查找位图中第一个值为 bit 的位
+// BitPos options 选项 {start, end, unit}，unit 为 BYTE（默认）或 BIT，指定了 unit 而没有指定 end 时 end 默认为-1（到结尾）
+// BitPos return 位置，找不到时返回-1
+func (rd *Redis) BitPos(key string, bit int, options *map[string]interface{}) int64 {
+	args := append([]interface{}{rd.makeKey(key), bit}, makeBitRangeArgs(options, true)...)
+	reply, _ := rd.doRaw("BITPOS", args...)
+	return replyInt(reply)
+}
+
+// makeBitRangeArgs 生成 BITCOUNT/BITPOS 的范围参数
+// BITCOUNT 必须同时指定 start 和 end，unit 必须跟在 end 后面，没有指定 end 时使用-1，避免 start 或 unit 被忽略
+func makeBitRangeArgs(options *map[string]interface{}, startOnly bool) []interface{} {
+	opt := bitRangeOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	args := make([]interface{}, 0, 3)
+	if opt.Start == nil {
+		return args
+	}
+	if opt.End == nil && (!startOnly || opt.Unit != "") {
+		end := int64(-1)
+		opt.End = &end
+	}
+	args = append(args, *opt.Start)
+	if opt.End != nil {
+		args = append(args, *opt.End)
+		if opt.Unit != "" {
+			args = append(args, strings.ToUpper(opt.Unit))
+		}
+	}
+	return args
+}
+
+// BitOp 对多个位图进行位运算并将结果保存到 destKey
+// BitOp operation AND、OR、XOR 或 NOT
+// BitOp return 结果的字节长度
+func (rd *Redis) BitOp(operation, destKey string, keys ...string) int64 {
+	args := append([]interface{}{strings.ToUpper(operation), rd.makeKey(destKey)}, rd.makeKeys(keys)...)
+	reply, _ := rd.doRaw("BITOP", args...)
+	return replyInt(reply)
+}
+
+// BitField 对位图中任意宽度的整数进行读写
+// BitField operations 操作列表 [{op, type, offset, value, increment, behavior}]，op 为 get、set、incrBy 或 overflow，type 例如 u8、i16，offset 可以使用 "#n" 表示第n个type宽度，behavior 为 overflow 的 WRAP、SAT 或 FAIL
+// BitField return 按照顺序返回每个 get、set、incrBy 的结果，overflow 为 FAIL 时溢出的操作返回null
+func (rd *Redis) BitField(key string, operations []map[string]interface{}) []interface{} {
+	args := []interface{}{rd.makeKey(key)}
+	for _, operation := range operations {
+		op := bitFieldOperation{}
+		u.Convert(operation, &op)
+		switch strings.ToUpper(op.Op) {
+		case "GET":
+			args = append(args, "GET", op.Type, op.Offset)
+		case "SET":
+			args = append(args, "SET", op.Type, op.Offset, op.Value)
+		case "INCRBY":
+			args = append(args, "INCRBY", op.Type, op.Offset, op.Increment)
+		case "OVERFLOW":
+			args = append(args, "OVERFLOW", strings.ToUpper(op.Behavior))
+		}
+	}
+	reply, _ := rd.doRaw("BITFIELD", args...)
+	arr, _ := reply.([]interface{})
+	out := make([]interface{}, len(arr))
+	for i, v := range arr {
+		if v != nil {
+			out[i] = replyInt(v)
+		}
+	}
+	return out
+}
+
+// PFAdd 向 HyperLogLog 中添加元素
+// PFAdd return 估算的基数是否发生了变化
+func (rd *Redis) PFAdd(key string, elements ...interface{}) bool {
+	reply, _ := rd.doRaw("PFADD", append([]interface{}{rd.makeKey(key)}, rd.encodeValues(elements)...)...)
+	return replyBool(reply)
+}
+
+// PFCount 获取一个或多个 HyperLogLog 合并后的估算基数
+func (rd *Redis) PFCount(keys ...string) int64 {
+	reply, _ := rd.doRaw("PFCOUNT", rd.makeKeys(keys)...)
+	return replyInt(reply)
+}
+
+// PFMerge 将多个 HyperLogLog 合并到 destKey
+func (rd *Redis) PFMerge(destKey string, sourceKeys ...string) bool {
+	reply, _ := rd.doRaw("PFMERGE", append([]interface{}{rd.makeKey(destKey)}, rd.makeKeys(sourceKeys)...)...)
+	return replyBool(reply)
+}
@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBitmap(t *testing.T) {
+	rd := newTestRedis(t, "?prefix=app:")
+	// 0x0f 0xf0 0x01
+	for _, offset := range []int64{4, 5, 6, 7, 8, 9, 10, 11, 23} {
+		rd.SetBit("b", offset, 1)
+	}
+	if rd.SetBit("b", 4, 0) != 1 || rd.SetBit("b", 4, 1) != 0 || rd.GetBit("b", 5) != 1 || rd.GetBit("b", 1000) != 0 {
+		t.Error("bad setbit or getbit")
+	}
+
+	tests := []struct {
+		options map[string]interface{}
+		expect  int64
+	}{
+		{nil, 9},
+		{map[string]interface{}{"start": 1, "end": 1}, 4},
+		{map[string]interface{}{"start": 1}, 5},
+	}
+	for _, test := range tests {
+		if n := rd.BitCount("b", &test.options); n != test.expect {
+			t.Errorf("bitcount %v: got %d, expect %d", test.options, n, test.expect)
+		}
+	}
+	if rd.BitPos("b", 1, nil) != 4 || rd.BitPos("b", 0, &map[string]interface{}{"start": 1}) != 12 {
+		t.Error("bad bitpos")
+	}
+
+	rd.SetBit("c", 4, 1)
+	if rd.BitOp("and", "d", "b", "c") != 3 || rd.BitCount("d", nil) != 1 || rd.BitOp("OR", "d", "b", "c") != 3 || rd.BitCount("d", nil) != 9 {
+		t.Error("bad bitop")
+	}
+}
+
+// BIT 单位需要 Redis 7.0 及以上的版本
+func TestBitUnit(t *testing.T) {
+	rd := newTestRedis(t, "")
+	rd.SetBit("b", 4, 1)
+	rd.SetBit("b", 5, 1)
+	rd.SetBit("b", 9, 1)
+	if _, err := rd.doRaw("BITCOUNT", "b", 0, -1, "BIT"); err != nil {
+		t.Skip("BIT unit not supported:", err)
+	}
+	if n := rd.BitCount("b", &map[string]interface{}{"start": 0, "end": 4, "unit": "bit"}); n != 1 {
+		t.Error("bad bitcount in bits", n)
+	}
+	if n := rd.BitCount("b", &map[string]interface{}{"start": 5, "unit": "bit"}); n != 2 {
+		t.Error("bad bitcount without end", n)
+	}
+	if n := rd.BitPos("b", 1, &map[string]interface{}{"start": 6, "unit": "bit"}); n != 9 {
+		t.Error("bad bitpos in bits", n)
+	}
+}
+
+func TestBitRangeArgs(t *testing.T) {
+	tests := []struct {
+		options   map[string]interface{}
+		startOnly bool
+		expect    []interface{}
+	}{
+		{nil, false, []interface{}{}},
+		{map[string]interface{}{"end": 3}, false, []interface{}{}},
+		{map[string]interface{}{"start": 1}, false, []interface{}{int64(1), int64(-1)}},
+		{map[string]interface{}{"start": 1, "end": 3, "unit": "bit"}, false, []interface{}{int64(1), int64(3), "BIT"}},
+		{map[string]interface{}{"start": 1, "unit": "bit"}, false, []interface{}{int64(1), int64(-1), "BIT"}},
+		{map[string]interface{}{"start": 1}, true, []interface{}{int64(1)}},
+		{map[string]interface{}{"start": 1, "unit": "byte"}, true, []interface{}{int64(1), int64(-1), "BYTE"}},
+	}
+	for _, test := range tests {
+		if out := makeBitRangeArgs(&test.options, test.startOnly); !reflect.DeepEqual(out, test.expect) {
+			t.Errorf("%v %v: got %#v, expect %#v", test.options, test.startOnly, out, test.expect)
+		}
+	}
+}
+
+func TestBitField(t *testing.T) {
+	rd := newTestRedis(t, "")
+	if _, err := rd.doRaw("BITFIELD", "f", "GET", "u8", 0); err != nil {
+		t.Skip("BITFIELD not supported:", err)
+	}
+	out := rd.BitField("f", []map[string]interface{}{
+		{"op": "set", "type": "u8", "offset": 0, "value": 200},
+		{"op": "get", "type": "u8", "offset": 0},
+		{"op": "incrBy", "type": "u8", "offset": "#1", "increment": 10},
+		{"op": "overflow", "behavior": "fail"},
+		{"op": "incrBy", "type": "u8", "offset": 0, "increment": 100},
+		{"op": "overflow", "behavior": "sat"},
+		{"op": "incrBy", "type": "u8", "offset": 0, "increment": 100},
+	})
+	if expect := []interface{}{int64(0), int64(200), int64(10), nil, int64(255)}; !reflect.DeepEqual(out, expect) {
+		t.Error("bad bitfield", out)
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	rd := newTestRedis(t, "")
+	if !rd.PFAdd("h1", "a", "b", "c") || rd.PFAdd("h1", "a") || !rd.PFAdd("h2", "d", "e") {
+		t.Error("bad pfadd")
+	}
+	if rd.PFCount("h1") != 3 || rd.PFCount("h1", "h2") != 5 {
+		t.Error("bad pfcount")
+	}
+	if !rd.PFMerge("h3", "h1", "h2") || rd.PFCount("h3") != 5 {
+		t.Error("bad pfmerge")
+	}
+}
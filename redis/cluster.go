@@ -0,0 +1,613 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/ssgo/redis"
+	"github.com/ssgo/u"
+)
+
+const clusterSlots = 16384
+const clusterMaxRedirects = 5
+
+// 没有Key的命令，在集群中发送到任意节点
+var keylessCommands = map[string]bool{
+	"ASKING": true, "AUTH": true, "CLIENT": true, "CLUSTER": true, "COMMAND": true, "CONFIG": true,
+	"DBSIZE": true, "DISCARD": true, "ECHO": true, "EXEC": true, "FLUSHALL": true, "FLUSHDB": true,
+	"HELLO": true, "INFO": true, "KEYS": true, "LASTSAVE": true, "MULTI": true, "PING": true,
+	"PSUBSCRIBE": true, "PUBLISH": true, "PUNSUBSCRIBE": true, "QUIT": true, "RANDOMKEY": true,
+	"READONLY": true, "ROLE": true, "SCAN": true, "SCRIPT": true, "SELECT": true, "SLOWLOG": true,
+	"SUBSCRIBE": true, "TIME": true, "UNSUBSCRIBE": true, "UNWATCH": true, "WAIT": true,
+}
+
+// cluster 集群的槽位信息，按照Key所在的槽位将命令发送到对应的主节点，收到 MOVED/ASK 时自动重定向
+// 地址格式：redis-cluster://:password@node1:7000,node2:7001
+type cluster struct {
+	urlInfo     *url.URL
+	seeds       []string
+	pool        *redis.Redis
+	nodes       map[string]*redis.Redis
+	slots       []string
+	lock        sync.RWMutex
+	refreshLock sync.Mutex
+}
+
+func newCluster(urlInfo *url.URL) *cluster {
+	c := &cluster{
+		urlInfo: urlInfo,
+		seeds:   splitHosts(urlInfo),
+		nodes:   map[string]*redis.Redis{},
+		slots:   make([]string, clusterSlots),
+	}
+	// 连接池中的每个连接是一个 clusterConn，在内部按需连接各个节点
+	c.pool = newTopologyPool(urlInfo, c.seeds[0], "0")
+	c.pool.GetPool().Dial = c.dial
+	clusterPoolsLock.Lock()
+	clusterPools[c.pool.GetPool()] = c
+	clusterPoolsLock.Unlock()
+	return c
+}
+
+// clusterPools 按照连接池查找对应的集群，用来拒绝只能在单个节点上执行的操作
+var clusterPools = map[*redigo.Pool]*cluster{}
+var clusterPoolsLock sync.Mutex
+
+func isClusterPool(pool *redis.Redis) bool {
+	clusterPoolsLock.Lock()
+	defer clusterPoolsLock.Unlock()
+	return clusterPools[pool.GetPool()] != nil
+}
+
+func (c *cluster) dial() (redigo.Conn, error) {
+	if c.anyNode() == "" {
+		if err := c.refresh(); err != nil {
+			return nil, err
+		}
+	}
+	return &clusterConn{cluster: c, conns: map[string]redigo.Conn{}, dirty: map[string]bool{}}, nil
+}
+
+func (c *cluster) dialNode(addr string) (redigo.Conn, error) {
+	c.lock.Lock()
+	pool := c.nodes[addr]
+	if pool == nil {
+		pool = newNodePool(c.pool, addr, 0)
+		c.nodes[addr] = pool
+	}
+	c.lock.Unlock()
+	return pool.GetPool().Dial()
+}
+
+// refresh 从任意一个可用的节点重新获取槽位信息
+func (c *cluster) refresh() error {
+	c.refreshLock.Lock()
+	defer c.refreshLock.Unlock()
+
+	addrs := append(c.masters(), c.seeds...)
+	err := errors.New("no cluster node available")
+	for _, addr := range addrs {
+		var conn redigo.Conn
+		if conn, err = c.dialNode(addr); err != nil {
+			continue
+		}
+		var reply interface{}
+		reply, err = conn.Do("CLUSTER", "SLOTS")
+		_ = conn.Close()
+		if err != nil {
+			continue
+		}
+		slots := make([]string, clusterSlots)
+		arr, _ := reply.([]interface{})
+		for _, v := range arr {
+			info, _ := v.([]interface{})
+			if len(info) < 3 {
+				continue
+			}
+			master, _ := info[2].([]interface{})
+			if len(master) < 2 {
+				continue
+			}
+			host := replyString(master[0])
+			if host == "" || host == "?" {
+				// 节点没有声明地址时使用当前连接的地址
+				host, _, _ = net.SplitHostPort(addr)
+			}
+			node := net.JoinHostPort(host, replyString(master[1]))
+			for slot := replyInt(info[0]); slot <= replyInt(info[1]) && slot < clusterSlots; slot++ {
+				slots[slot] = node
+			}
+		}
+		c.lock.Lock()
+		c.slots = slots
+		c.lock.Unlock()
+		return nil
+	}
+	return err
+}
+
+func (c *cluster) slotNode(slot int) string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.slots[slot]
+}
+
+func (c *cluster) setSlot(slot int, addr string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if slot >= 0 && slot < clusterSlots {
+		c.slots[slot] = addr
+	}
+}
+
+func (c *cluster) anyNode() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, addr := range c.slots {
+		if addr != "" {
+			return addr
+		}
+	}
+	return ""
+}
+
+func (c *cluster) masters() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	found := map[string]bool{}
+	out := make([]string, 0)
+	for _, addr := range c.slots {
+		if addr != "" && !found[addr] {
+			found[addr] = true
+			out = append(out, addr)
+		}
+	}
+	return out
+}
+
+// clusterCommand 已经发送等待回复的命令
+type clusterCommand struct {
+	addr    string
+	cmd     string
+	args    []interface{}
+	inMulti bool
+}
+
+// clusterConn 实现 redigo.Conn，按照Key将命令发送到对应节点的连接上
+// 事务和订阅期间固定使用同一个节点，MULTI 会推迟到第一个带Key的命令确定节点后发送
+type clusterConn struct {
+	cluster     *cluster
+	conns       map[string]redigo.Conn
+	dirty       map[string]bool
+	pending     []clusterCommand
+	pinned      string
+	last        string
+	multiQueued bool
+	inMulti     bool
+	closed      bool
+}
+
+func (c *clusterConn) Close() error {
+	c.closed = true
+	for addr, conn := range c.conns {
+		_ = conn.Close()
+		delete(c.conns, addr)
+	}
+	return nil
+}
+
+func (c *clusterConn) Err() error {
+	if c.closed {
+		return errors.New("redigo: closed")
+	}
+	for addr, conn := range c.conns {
+		if conn.Err() != nil {
+			_ = conn.Close()
+			delete(c.conns, addr)
+		}
+	}
+	return nil
+}
+
+func (c *clusterConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.do(nil, cmd, args)
+}
+
+func (c *clusterConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return c.do(&timeout, cmd, args)
+}
+
+func (c *clusterConn) Send(cmd string, args ...interface{}) error {
+	name := strings.ToUpper(cmd)
+	if name == "MULTI" && c.pinned == "" {
+		c.multiQueued = true
+		return nil
+	}
+	addr := c.route(cmd, args)
+	if c.multiQueued {
+		// 使用第一个命令所在的节点执行事务
+		c.multiQueued = false
+		c.pinned = addr
+		if err := c.sendTo(addr, "MULTI", nil); err != nil {
+			return err
+		}
+	}
+	return c.sendTo(addr, cmd, args)
+}
+
+func (c *clusterConn) Flush() error {
+	for addr := range c.dirty {
+		delete(c.dirty, addr)
+		if conn := c.conns[addr]; conn != nil {
+			if err := conn.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *clusterConn) Receive() (interface{}, error) {
+	return c.receive(nil)
+}
+
+func (c *clusterConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return c.receive(&timeout)
+}
+
+func (c *clusterConn) do(timeout *time.Duration, cmd string, args []interface{}) (interface{}, error) {
+	name := strings.ToUpper(cmd)
+	if cmd != "" && len(c.pending) == 0 && !c.multiQueued && !c.inMulti {
+		switch name {
+		case "MULTI":
+			if c.pinned == "" {
+				c.multiQueued = true
+				return "OK", nil
+			}
+		case "SCRIPT", "FLUSHDB", "FLUSHALL":
+			if c.pinned == "" {
+				// 脚本需要加载到所有的主节点，清空数据也需要在所有的主节点上执行
+				replies, err := c.doAll(timeout, cmd, args)
+				if err != nil {
+					return nil, err
+				}
+				return replies[len(replies)-1], nil
+			}
+		case "KEYS":
+			if c.pinned == "" {
+				replies, err := c.doAll(timeout, cmd, args)
+				if err != nil {
+					return nil, err
+				}
+				keys := make([]interface{}, 0)
+				for _, reply := range replies {
+					arr, _ := reply.([]interface{})
+					keys = append(keys, arr...)
+				}
+				return keys, nil
+			}
+		case "DBSIZE":
+			if c.pinned == "" {
+				replies, err := c.doAll(timeout, cmd, args)
+				if err != nil {
+					return nil, err
+				}
+				var size int64
+				for _, reply := range replies {
+					size += replyInt(reply)
+				}
+				return size, nil
+			}
+		case "SCAN":
+			if c.pinned == "" {
+				return c.scan(timeout, args)
+			}
+		}
+		addr := c.route(cmd, args)
+		c.track(name, addr)
+		return c.doRedirect(timeout, addr, false, cmd, args)
+	}
+
+	if cmd != "" {
+		if err := c.Send(cmd, args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	// 与 redigo 一致，返回最后一个回复和第一个错误
+	var reply interface{}
+	var err error
+	for len(c.pending) > 0 {
+		r, e := c.receive(timeout)
+		if e != nil {
+			redisErr, ok := e.(redigo.Error)
+			if !ok {
+				return nil, e
+			}
+			r = redisErr
+			if err == nil {
+				err = e
+			}
+		}
+		reply = r
+	}
+	return reply, err
+}
+
+// doAll 在所有的主节点上执行命令（管道中的命令不会分发）
+func (c *clusterConn) doAll(timeout *time.Duration, cmd string, args []interface{}) ([]interface{}, error) {
+	masters := c.cluster.masters()
+	if len(masters) == 0 {
+		return nil, errors.New("no cluster node available")
+	}
+	replies := make([]interface{}, 0, len(masters))
+	for _, addr := range masters {
+		reply, err := c.doRedirect(timeout, addr, false, cmd, args)
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
+
+// scan 依次迭代每个主节点，返回的游标由节点内的游标和3位节点序号组成，所有节点迭代完成后返回 "0"
+func (c *clusterConn) scan(timeout *time.Duration, args []interface{}) (interface{}, error) {
+	masters := c.cluster.masters()
+	if len(args) == 0 || len(masters) == 0 {
+		return nil, errors.New("no cluster node available")
+	}
+	index, nodeCursor := 0, "0"
+	if cursor := keyString(args[0]); cursor != "0" {
+		if len(cursor) < 4 {
+			return nil, errors.New("ERR invalid cursor")
+		}
+		index = u.Int(cursor[len(cursor)-3:])
+		nodeCursor = cursor[:len(cursor)-3]
+	}
+	if index >= len(masters) {
+		// 迭代期间节点减少
+		return []interface{}{[]byte("0"), []interface{}{}}, nil
+	}
+	nodeArgs := append([]interface{}{nodeCursor}, args[1:]...)
+	reply, err := c.doRedirect(timeout, masters[index], false, "SCAN", nodeArgs)
+	arr, _ := reply.([]interface{})
+	if err != nil || len(arr) != 2 {
+		return reply, err
+	}
+	nextCursor := replyString(arr[0])
+	if nextCursor == "0" {
+		index++
+		if index >= len(masters) {
+			return arr, nil
+		}
+	}
+	arr[0] = []byte(fmt.Sprintf("%s%03d", nextCursor, index))
+	return arr, nil
+}
+
+// doRedirect 在指定节点上执行命令，收到 MOVED/ASK 时重定向到新的节点
+func (c *clusterConn) doRedirect(timeout *time.Duration, addr string, asking bool, cmd string, args []interface{}) (interface{}, error) {
+	for i := 0; i < clusterMaxRedirects; i++ {
+		conn, err := c.conn(addr)
+		if err != nil {
+			// 节点无法连接时刷新槽位信息后重试一次
+			if i == 0 && c.pinned == "" && c.cluster.refresh() == nil {
+				if newAddr := c.route(cmd, args); newAddr != addr {
+					addr = newAddr
+					continue
+				}
+			}
+			return nil, err
+		}
+		if asking {
+			if _, err := conn.Do("ASKING"); err != nil {
+				return nil, err
+			}
+			asking = false
+		}
+		var reply interface{}
+		if timeout != nil {
+			reply, err = redigo.DoWithTimeout(conn, *timeout, cmd, args...)
+		} else {
+			reply, err = conn.Do(cmd, args...)
+		}
+		c.last = addr
+		if kind, slot, target := parseRedirect(err); kind != "" {
+			if kind == "MOVED" {
+				c.cluster.setSlot(slot, target)
+			} else {
+				asking = true
+			}
+			addr = target
+			continue
+		}
+		return reply, err
+	}
+	return nil, errors.New("too many redis cluster redirects")
+}
+
+func (c *clusterConn) sendTo(addr, cmd string, args []interface{}) error {
+	conn, err := c.conn(addr)
+	if err != nil {
+		return err
+	}
+	if err = conn.Send(cmd, args...); err != nil {
+		return err
+	}
+	name := strings.ToUpper(cmd)
+	c.track(name, addr)
+	c.dirty[addr] = true
+	c.last = addr
+	c.pending = append(c.pending, clusterCommand{addr: addr, cmd: cmd, args: args, inMulti: c.inMulti && name != "EXEC" && name != "DISCARD"})
+	if name == "MULTI" {
+		c.inMulti = true
+	}
+	return nil
+}
+
+// track 记录事务和订阅的状态，期间的命令都固定发送到同一个节点
+func (c *clusterConn) track(name, addr string) {
+	switch name {
+	case "WATCH", "SUBSCRIBE", "PSUBSCRIBE":
+		c.pinned = addr
+	case "EXEC", "DISCARD":
+		c.pinned = ""
+		c.inMulti = false
+	case "UNWATCH":
+		if !c.inMulti {
+			c.pinned = ""
+		}
+	}
+}
+
+func (c *clusterConn) receive(timeout *time.Duration) (interface{}, error) {
+	if len(c.pending) == 0 {
+		// 订阅模式下没有对应的命令，从订阅所在的节点读取
+		addr := c.pinned
+		if addr == "" {
+			addr = c.last
+		}
+		conn := c.conns[addr]
+		if conn == nil {
+			return nil, errors.New("redis cluster: no pending reply")
+		}
+		return receiveWithTimeout(conn, timeout)
+	}
+
+	p := c.pending[0]
+	c.pending = c.pending[1:]
+	conn := c.conns[p.addr]
+	if conn == nil {
+		return nil, errors.New("redis cluster: connection closed")
+	}
+	reply, err := receiveWithTimeout(conn, timeout)
+	if kind, slot, target := parseRedirect(err); kind != "" && !p.inMulti {
+		if kind == "MOVED" {
+			c.cluster.setSlot(slot, target)
+		}
+		return c.doRedirect(timeout, target, kind == "ASK", p.cmd, p.args)
+	}
+	return reply, err
+}
+
+func receiveWithTimeout(conn redigo.Conn, timeout *time.Duration) (interface{}, error) {
+	if timeout != nil {
+		return redigo.ReceiveWithTimeout(conn, *timeout)
+	}
+	return conn.Receive()
+}
+
+func (c *clusterConn) conn(addr string) (redigo.Conn, error) {
+	if conn := c.conns[addr]; conn != nil && conn.Err() == nil {
+		return conn, nil
+	}
+	conn, err := c.cluster.dialNode(addr)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[addr] = conn
+	return conn, nil
+}
+
+func (c *clusterConn) route(cmd string, args []interface{}) string {
+	if c.pinned != "" {
+		return c.pinned
+	}
+	if key, ok := commandKey(cmd, args); ok {
+		if addr := c.cluster.slotNode(keySlot(key)); addr != "" {
+			return addr
+		}
+	}
+	if c.last != "" {
+		return c.last
+	}
+	return c.cluster.anyNode()
+}
+
+// commandKey 获取命令中用来计算槽位的第一个Key
+func commandKey(cmd string, args []interface{}) (string, bool) {
+	keyIndex := 0
+	switch strings.ToUpper(cmd) {
+	case "EVAL", "EVALSHA", "EVAL_RO", "EVALSHA_RO", "FCALL", "FCALL_RO":
+		if len(args) < 3 || u.Int(keyString(args[1])) <= 0 {
+			return "", false
+		}
+		keyIndex = 2
+	case "XREAD", "XREADGROUP":
+		for i, arg := range args {
+			if strings.ToUpper(keyString(arg)) == "STREAMS" && i+1 < len(args) {
+				return keyString(args[i+1]), true
+			}
+		}
+		return "", false
+	case "XGROUP", "XINFO", "OBJECT", "MEMORY", "BITOP", "LMPOP", "ZMPOP", "SINTERCARD", "ZINTERCARD", "ZUNION", "ZINTER", "ZDIFF":
+		keyIndex = 1
+	case "BLMPOP", "BZMPOP":
+		keyIndex = 2
+	default:
+		if keylessCommands[strings.ToUpper(cmd)] {
+			return "", false
+		}
+	}
+	if keyIndex >= len(args) {
+		return "", false
+	}
+	return keyString(args[keyIndex]), true
+}
+
+func keyString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(arg)
+}
+
+// keySlot 计算Key所在的槽位，Key中包含 {tag} 时只使用 tag 计算
+func keySlot(key string) int {
+	if tag, ok := hashTag(key); ok {
+		key = tag
+	}
+	return int(crc16(key) % clusterSlots)
+}
+
+// crc16 CRC16-CCITT (XMODEM)
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// parseRedirect 解析 "MOVED 3999 127.0.0.1:6381" 或 "ASK 3999 127.0.0.1:6381"
+func parseRedirect(err error) (string, int, string) {
+	if _, ok := err.(redigo.Error); !ok {
+		return "", 0, ""
+	}
+	parts := strings.Split(err.Error(), " ")
+	if len(parts) == 3 && (parts[0] == "MOVED" || parts[0] == "ASK") {
+		slot, _ := strconv.Atoi(parts[1])
+		return parts[0], slot, parts[2]
+	}
+	return "", 0, ""
+}
@@ -0,0 +1,142 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/json"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// valueCodec 连接配置中的值编码方式，决定写入时如何序列化、读取时如何反序列化
+// json-auto 写入时对象和数组序列化为JSON，读取时尝试按照JSON反序列化，"00123" 会被读取为数字 123
+// raw 写入方式与 json-auto 相同，读取时总是返回字符串
+// json-strict 写入时所有值都序列化为JSON并加上标记字节，字符串带有引号作为类型标记，读取时只反序列化带有标记的值，其他值（例如 INCR 写入的计数或其他客户端写入的数据）作为字符串返回
+// msgpack 写入时所有值都序列化为 MessagePack 并加上标记字节，读取时只反序列化带有标记的值，其他值（例如 INCR 写入的计数或其他客户端写入的数据）按照 json-auto 读取
+type valueCodec string
+
+// msgpackMarker MessagePack 中从未使用的字节，也不是合法的UTF-8开头，用来区分 msgpack 编码写入的值
+const msgpackMarker = 0xc1
+
+// jsonMarker 不是合法的UTF-8开头，用来区分 json-strict 编码写入的值
+const jsonMarker = 0xc0
+
+const (
+	codecJsonAuto   valueCodec = "json-auto"
+	codecRaw        valueCodec = "raw"
+	codecJsonStrict valueCodec = "json-strict"
+	codecMsgpack    valueCodec = "msgpack"
+)
+
+func makeValueCodec(name string) valueCodec {
+	switch valueCodec(name) {
+	case codecRaw, codecJsonStrict, codecMsgpack:
+		return valueCodec(name)
+	}
+	return codecJsonAuto
+}
+
+// encodeValue 按照编码方式序列化写入的值，[]byte 总是原样写入
+func (c valueCodec) encodeValue(value interface{}) interface{} {
+	if _, isBytes := value.([]byte); isBytes {
+		return value
+	}
+	switch c {
+	case codecJsonStrict:
+		if encoded, err := json.Marshal(value); err == nil {
+			return append([]byte{jsonMarker}, encoded...)
+		}
+	case codecMsgpack:
+		if encoded, err := msgpack.Marshal(value); err == nil {
+			return append([]byte{msgpackMarker}, encoded...)
+		}
+	}
+	return value
+}
+
+func (c valueCodec) encodeValues(values []interface{}) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = c.encodeValue(v)
+	}
+	return out
+}
+
+// encodePairs 序列化 key-value 交替排列的参数中的值
+func (c valueCodec) encodePairs(pairs []interface{}) []interface{} {
+	out := make([]interface{}, len(pairs))
+	for i, v := range pairs {
+		if i%2 == 1 {
+			v = c.encodeValue(v)
+		}
+		out[i] = v
+	}
+	return out
+}
+
+// decodeBytes 按照编码方式反序列化读取到的值
+func (c valueCodec) decodeBytes(buf []byte) interface{} {
+	switch c {
+	case codecRaw:
+		return string(buf)
+	case codecJsonStrict:
+		if len(buf) > 1 && buf[0] == jsonMarker {
+			var v interface{}
+			if json.Unmarshal(buf[1:], &v) == nil {
+				return v
+			}
+		}
+		return string(buf)
+	case codecMsgpack:
+		if len(buf) > 1 && buf[0] == msgpackMarker {
+			reader := bytes.NewReader(buf[1:])
+			dec := msgpack.NewDecoder(reader)
+			// 不完整或有多余数据时说明不是 MessagePack 格式
+			if v, err := dec.DecodeInterfaceLoose(); err == nil && reader.Len() == 0 {
+				return v
+			}
+			return string(buf)
+		}
+	}
+	var v interface{}
+	if json.Unmarshal(buf, &v) == nil {
+		return v
+	}
+	return string(buf)
+}
+
+// replyValue 将回复转换为脚本中使用的值，字符串按照编码方式反序列化
+func (c valueCodec) replyValue(reply interface{}) interface{} {
+	switch v := reply.(type) {
+	case []byte:
+		return c.decodeBytes(v)
+	case []interface{}:
+		return c.replyValues(v)
+	case redigo.Error:
+		return v.Error()
+	}
+	return reply
+}
+
+func (c valueCodec) replyValues(reply interface{}) []interface{} {
+	arr, _ := reply.([]interface{})
+	out := make([]interface{}, len(arr))
+	for i, v := range arr {
+		out[i] = c.replyValue(v)
+	}
+	return out
+}
+
+// replyMap 将 field-value 交替排列的回复转换为对象
+func (c valueCodec) replyMap(reply interface{}) map[string]interface{} {
+	arr, _ := reply.([]interface{})
+	out := map[string]interface{}{}
+	for i := 0; i+1 < len(arr); i += 2 {
+		out[replyString(arr[i])] = c.replyValue(arr[i+1])
+	}
+	return out
+}
+
+func (c valueCodec) decodeValue(reply interface{}) interface{}  { return c.replyValue(reply) }
+func (c valueCodec) decodeValues(reply interface{}) interface{} { return c.replyValues(reply) }
+func (c valueCodec) decodeMap(reply interface{}) interface{}    { return c.replyMap(reply) }
@@ -0,0 +1,132 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func TestValueCodec(t *testing.T) {
+	object := map[string]interface{}{"name": "Tom", "tags": []interface{}{"a"}}
+	tests := []struct {
+		codec  valueCodec
+		value  interface{}
+		expect interface{}
+	}{
+		{codecJsonAuto, "hello", "hello"},
+		{codecJsonAuto, "123", float64(123)},
+		{codecJsonAuto, 123, float64(123)},
+		{codecJsonAuto, object, object},
+		{codecRaw, "123", "123"},
+		{codecRaw, object, `{"name":"Tom","tags":["a"]}`},
+		{codecJsonStrict, "123", "123"},
+		{codecJsonStrict, "true", "true"},
+		{codecJsonStrict, 123, float64(123)},
+		{codecJsonStrict, object, object},
+		{codecMsgpack, "5", "5"},
+		{codecMsgpack, "a", "a"},
+		{codecMsgpack, 123, int64(123)},
+		{codecMsgpack, true, true},
+		{codecMsgpack, object, object},
+	}
+	for _, test := range tests {
+		rd := newTestRedis(t, "?codec="+string(test.codec))
+		rd.Set("k", test.value)
+		if out := rd.Get("k"); !reflect.DeepEqual(out, test.expect) {
+			t.Errorf("%s %#v: got %#v, expect %#v", test.codec, test.value, out, test.expect)
+		}
+	}
+}
+
+// 没有经过编码写入的值（计数器、其他客户端写入的数据）不能被误判为 MessagePack
+func TestMsgpackCodecPlainValues(t *testing.T) {
+	rd := newTestRedis(t, "?codec=msgpack")
+	rd.IncrBy("counter", 5)
+	rd.HIncrBy("hash", "n", 97)
+	rd.doRaw("SET", rd.makeKey("plain"), "a")
+	rd.doRaw("SET", rd.makeKey("marker"), []byte{msgpackMarker})
+
+	tests := []struct {
+		value  interface{}
+		expect interface{}
+	}{
+		{rd.Get("counter"), float64(5)},
+		{rd.HGet("hash", "n"), float64(97)},
+		{rd.Get("plain"), "a"},
+		{rd.Get("marker"), string([]byte{msgpackMarker})},
+		{rd.GetString("counter"), "5"},
+	}
+	for i, test := range tests {
+		if !reflect.DeepEqual(test.value, test.expect) {
+			t.Errorf("%d: got %#v, expect %#v", i, test.value, test.expect)
+		}
+	}
+}
+
+// json-strict 只反序列化自己写入的值，其他客户端写入的数据原样作为字符串返回
+func TestJsonStrictCodecForeignValues(t *testing.T) {
+	rd := newTestRedis(t, "?codec=json-strict")
+	conn := rd.pool.GetPool().Get()
+	defer conn.Close()
+	for key, value := range map[string]string{"number": "123", "bool": "true", "object": `{"a":1}`, "quoted": `"a"`, "null": "null"} {
+		if _, err := conn.Do("SET", key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rd.Set("mine", map[string]interface{}{"a": 1})
+	rd.Set("text", "123")
+	rd.HSet("hash", "n", 5)
+	rd.HIncrBy("hash", "count", 3)
+	rd.RPush("list", "1", "x")
+
+	tests := []struct {
+		value  interface{}
+		expect interface{}
+	}{
+		{rd.Get("number"), "123"},
+		{rd.Get("bool"), "true"},
+		{rd.Get("object"), `{"a":1}`},
+		{rd.Get("quoted"), `"a"`},
+		{rd.Get("null"), "null"},
+		{rd.Get("mine"), map[string]interface{}{"a": float64(1)}},
+		{rd.Get("text"), "123"},
+		{rd.HGetAll("hash"), map[string]interface{}{"n": float64(5), "count": "3"}},
+		{rd.LRange("list", 0, -1), []interface{}{"1", "x"}},
+		{rd.MGet("number", "mine"), []interface{}{"123", map[string]interface{}{"a": float64(1)}}},
+	}
+	for i, test := range tests {
+		if !reflect.DeepEqual(test.value, test.expect) {
+			t.Errorf("%d: got %#v, expect %#v", i, test.value, test.expect)
+		}
+	}
+
+	// 其他客户端读取到的是带有标记的JSON
+	if v, _ := redigo.Bytes(conn.Do("GET", "text")); string(v) != "\xc0\"123\"" {
+		t.Errorf("bad stored value %q", v)
+	}
+}
+
+// 发布的消息不经过值编码，订阅者按照自己连接的编码方式读取
+func TestPublishNotEncoded(t *testing.T) {
+	rd := newTestRedis(t, "?codec=json-strict")
+	reader := newTestRedis(t, "")
+	callback, messages := receiveMessages()
+	if err := reader.Subscribe(nil, []string{"news"}, callback); err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Unsubscribe()
+	for i := 0; i < 100 && numSub(reader, "news") != 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	p := rd.Pipeline()
+	p.Publish("news", "hello")
+	if _, err := p.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	waitMessage(t, messages, &testMessage{"news", "hello"})
+	rd.Publish("news", `{"a":1}`)
+	waitMessage(t, messages, &testMessage{"news", map[string]interface{}{"a": float64(1)}})
+}
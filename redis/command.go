@@ -0,0 +1,169 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/ssgo/log"
+)
+
+// doRaw 执行Redis命令并返回原始的回复数据，用于需要处理嵌套结构或整数回复的命令
+func (rd *Redis) doRaw(cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := rd.getConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return rd.doOnConn(conn, cmd, args...)
+}
+
+// getConn 从连接池中取出一个连接，使用完后需要调用 Close 归还
+func (rd *Redis) getConn() (redigo.Conn, error) {
+	conn := rd.pool.GetConnection()
+	if conn == nil {
+		err := errors.New("operate on a bad redis pool")
+		rd.pool.LogError(err.Error())
+		return nil, err
+	}
+	if conn.Err() != nil {
+		err := conn.Err()
+		rd.pool.LogError(err.Error())
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// doOnConn 在指定的连接上执行Redis命令，并按照连接池的配置记录慢请求和错误日志
+func (rd *Redis) doOnConn(conn redigo.Conn, cmd string, args ...interface{}) (interface{}, error) {
+	startTime := time.Now()
+	reply, err := conn.Do(cmd, makeRedisArgs(args)...)
+	rd.logCommand(cmd, args, startTime, err)
+	return reply, err
+}
+
+// doBlocking 在独立的连接上执行阻塞命令，避免长时间占用连接池中的连接
+// timeout 为命令本身的阻塞时间，0 表示一直等待
+func (rd *Redis) doBlocking(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := rd.pool.GetPool().Dial()
+	if err != nil {
+		rd.pool.LogError(err.Error())
+		return nil, err
+	}
+	defer conn.Close()
+
+	readTimeout := time.Duration(0)
+	if timeout > 0 {
+		// 在阻塞时间的基础上预留网络传输的时间
+		readTimeout = timeout + time.Second
+	}
+	startTime := time.Now()
+	reply, err := redigo.DoWithTimeout(conn, readTimeout, cmd, makeRedisArgs(args)...)
+	if err != nil {
+		rd.pool.LogQueryError(err.Error(), cmd, args, log.MakeUesdTime(startTime, time.Now()))
+	}
+	return reply, err
+}
+
+func (rd *Redis) logCommand(cmd string, args []interface{}, startTime time.Time, err error) {
+	usedTime := log.MakeUesdTime(startTime, time.Now())
+	if err != nil {
+		rd.pool.LogQueryError(err.Error(), cmd, args, usedTime)
+	} else if rd.pool.Config.LogSlow > 0 && usedTime >= float32(rd.pool.Config.LogSlow.TimeDuration()/time.Millisecond) {
+		// 记录慢请求日志
+		rd.pool.LogQuery(cmd, args, usedTime)
+	}
+}
+
+// splitCommand 兼容 Do("SET key", value) 的写法，将命令中空格分隔的部分作为参数
+func splitCommand(cmd string, values []interface{}) (string, []interface{}) {
+	cmdArr := strings.Split(cmd, " ")
+	if len(cmdArr) == 1 {
+		return cmd, values
+	}
+	args := make([]interface{}, 0, len(cmdArr)-1+len(values))
+	for _, arg := range cmdArr[1:] {
+		args = append(args, arg)
+	}
+	return cmdArr[0], append(args, values...)
+}
+
+// makeRedisArgs 将对象和数组参数序列化为JSON，与Set等方法的存储格式保持一致
+func makeRedisArgs(args []interface{}) []interface{} {
+	out := make([]interface{}, len(args))
+	for i, arg := range args {
+		out[i] = arg
+		if arg == nil {
+			continue
+		}
+		t := reflect.TypeOf(arg)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Struct || t.Kind() == reflect.Map || (t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8) {
+			if encoded, err := json.Marshal(arg); err == nil {
+				out[i] = encoded
+			}
+		}
+	}
+	return out
+}
+
+func replyString(reply interface{}) string {
+	switch v := reply.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+	return ""
+}
+
+func replyStrings(reply interface{}) []string {
+	arr, _ := reply.([]interface{})
+	out := make([]string, len(arr))
+	for i, v := range arr {
+		out[i] = replyString(v)
+	}
+	return out
+}
+
+func replyInt(reply interface{}) int64 {
+	switch v := reply.(type) {
+	case int64:
+		return v
+	case []byte, string:
+		i, _ := strconv.ParseInt(replyString(v), 10, 64)
+		return i
+	}
+	return 0
+}
+
+func replyFloat(reply interface{}) float64 {
+	switch v := reply.(type) {
+	case int64:
+		return float64(v)
+	case []byte, string:
+		f, _ := strconv.ParseFloat(replyString(v), 64)
+		return f
+	}
+	return 0
+}
+
+func replyBool(reply interface{}) bool {
+	switch v := reply.(type) {
+	case int64:
+		return v != 0
+	case []byte, string:
+		s := replyString(v)
+		return s == "OK" || s == "1"
+	}
+	return false
+}
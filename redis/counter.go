@@ -0,0 +1,199 @@
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/ssgo/u"
+)
+
+// 每个哈希表保存同一个维度的连续 counterChunkSize 个时间桶，整个哈希表到期后自动删除
+const counterChunkSize = 60
+
+// 一次查询最多返回的时间桶数量
+const counterMaxPoints = 10000
+
+// 1970-01-01 是星期四，以周为单位的时间桶向后偏移4天，从星期一 00:00 UTC 开始
+const counterWeekOffset = 4 * 86400
+
+type Counter struct {
+	rd          *Redis
+	name        string
+	resolutions []counterResolution
+	retention   int64
+}
+
+type counterResolution struct {
+	name    string
+	seconds int64
+	offset  int64
+}
+
+type CounterPoint struct {
+	Time  int64
+	Value int64
+}
+
+type counterOption struct {
+	Resolutions []string
+	Retention   int64
+}
+
+// Counter 获取按时间分桶的计数器，每次计数同时累加到所有精度中，用于统计每分钟、每小时、每天的调用次数等
+// Counter name 计数器名称，所有Key使用 {name} 作为 hash tag，在集群中位于同一个节点
+// Counter options 选项 {resolutions, retention}，resolutions 为时间精度列表（默认 ['1m', '1h', '1d']），支持 s、m、h、d、w 单位，retention 为每个精度保留的时间桶数量（默认120）
+// Counter return 计数器对象，时间桶按照UTC对齐，以周为单位的时间桶从星期一 00:00 开始
+func (rd *Redis) Counter(name string, options *map[string]interface{}) (*Counter, error) {
+	opt := counterOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	if len(opt.Resolutions) == 0 {
+		opt.Resolutions = []string{"1m", "1h", "1d"}
+	}
+	if opt.Retention <= 0 {
+		opt.Retention = 120
+	}
+	c := &Counter{rd: rd, name: name, retention: opt.Retention}
+	for _, name := range opt.Resolutions {
+		seconds, err := parseCounterResolution(name)
+		if err != nil {
+			return nil, err
+		}
+		r := counterResolution{name: name, seconds: seconds}
+		if seconds%(7*86400) == 0 {
+			r.offset = counterWeekOffset
+		}
+		c.resolutions = append(c.resolutions, r)
+	}
+	return c, nil
+}
+
+// parseCounterResolution 解析时间精度，例如 30s、1m、1h、1d、1w
+func parseCounterResolution(resolution string) (int64, error) {
+	units := map[byte]int64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+	if len(resolution) >= 2 {
+		if unit, ok := units[resolution[len(resolution)-1]]; ok {
+			if n, err := strconv.ParseInt(resolution[:len(resolution)-1], 10, 64); err == nil && n > 0 {
+				return n * unit, nil
+			}
+		}
+	}
+	return 0, errors.New("invalid counter resolution: " + resolution)
+}
+
+func (c *Counter) resolution(name string) (counterResolution, bool) {
+	for _, r := range c.resolutions {
+		if r.name == name {
+			return r, true
+		}
+	}
+	return counterResolution{}, false
+}
+
+// align 获取时间所在的时间桶的起始时间
+func (r counterResolution) align(timestamp, seconds int64) int64 {
+	mod := (timestamp - r.offset) % seconds
+	if mod < 0 {
+		mod += seconds
+	}
+	return timestamp - mod
+}
+
+// chunkKey 获取时间桶所在的哈希表，哈希表中的字段为时间桶的起始时间
+func (c *Counter) chunkKey(dimension string, r counterResolution, bucket int64) (string, int64) {
+	chunkSeconds := r.seconds * counterChunkSize
+	chunkStart := r.align(bucket, chunkSeconds)
+	return c.rd.makeKey("counter:{" + c.name + "}:" + r.name + ":" + dimension + ":" + strconv.FormatInt(chunkStart, 10)), chunkStart + chunkSeconds
+}
+
+// Incr 增加计数，在一个管道中更新所有精度的时间桶并设置过期时间
+// Incr dimension 维度，例如客户端ID
+// Incr n 增加的数量，默认为1
+// Incr timestamp 计数的时间（秒级时间戳），默认为当前时间
+// Incr return 每个精度当前时间桶的计数 {resolution: value}
+func (c *Counter) Incr(dimension string, n *int64, timestamp *int64) (map[string]int64, error) {
+	increment := int64(1)
+	if n != nil {
+		increment = *n
+	}
+	now := time.Now().Unix()
+	if timestamp != nil && *timestamp > 0 {
+		now = *timestamp
+	}
+
+	p := c.rd.Pipeline()
+	for _, r := range c.resolutions {
+		bucket := r.align(now, r.seconds)
+		key, chunkEnd := c.chunkKey(dimension, r, bucket)
+		p.add(decodeInt, "HINCRBY", key, bucket, increment)
+		// 哈希表中最后一个时间桶超过保留时间后整个哈希表过期
+		p.add(decodeBool, "EXPIREAT", key, chunkEnd+r.seconds*c.retention)
+	}
+	results, err := p.Exec()
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]int64{}
+	for i, r := range c.resolutions {
+		if results[i*2].Error != "" {
+			return out, errors.New(results[i*2].Error)
+		}
+		out[r.name] = u.Int64(results[i*2].Result)
+	}
+	return out, nil
+}
+
+// Range 获取时间序列
+// Range resolution 时间精度，必须是创建计数器时指定的精度之一
+// Range from 开始时间（秒级时间戳）
+// Range to 结束时间（秒级时间戳），包含 to 所在的时间桶
+// Range return [{time, value}]，time 为时间桶的起始时间，没有计数的时间桶 value 为0
+func (c *Counter) Range(dimension, resolution string, from, to int64) ([]CounterPoint, error) {
+	r, ok := c.resolution(resolution)
+	if !ok {
+		return nil, errors.New("unknown counter resolution: " + resolution)
+	}
+	from = r.align(from, r.seconds)
+	to = r.align(to, r.seconds)
+	if to < from {
+		return []CounterPoint{}, nil
+	}
+	if (to-from)/r.seconds+1 > counterMaxPoints {
+		return nil, errors.New("too many points in counter range")
+	}
+
+	// 按照哈希表分组，每个哈希表使用一次 HMGET
+	p := c.rd.Pipeline()
+	out := make([]CounterPoint, 0, (to-from)/r.seconds+1)
+	groups := make([][]int, 0)
+	for bucket := from; bucket <= to; {
+		key, chunkEnd := c.chunkKey(dimension, r, bucket)
+		args := []interface{}{key}
+		group := make([]int, 0)
+		for ; bucket <= to && bucket < chunkEnd; bucket += r.seconds {
+			args = append(args, bucket)
+			group = append(group, len(out))
+			out = append(out, CounterPoint{Time: bucket})
+		}
+		p.add(decodeRaw, "HMGET", args...)
+		groups = append(groups, group)
+	}
+	results, err := p.Exec()
+	if err != nil {
+		return nil, err
+	}
+	for i, group := range groups {
+		if results[i].Error != "" {
+			return nil, errors.New(results[i].Error)
+		}
+		values, _ := results[i].Result.([]interface{})
+		for j, index := range group {
+			if j < len(values) && values[j] != nil {
+				out[index].Value = replyInt(values[j])
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCounter(t *testing.T) {
+	rd := newMemoryRedis(t, "")
+	// 2023-11-15 12:30:10 UTC，星期三
+	now := time.Date(2023, 11, 15, 12, 30, 10, 0, time.UTC).Unix()
+	rd.SetClock(now * 1000)
+	c, err := rd.Counter("api", &map[string]interface{}{"resolutions": []string{"1m", "1h", "1w"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	two := int64(2)
+	if out, err := c.Incr("client1", &two, &now); err != nil || !reflect.DeepEqual(out, map[string]int64{"1m": 2, "1h": 2, "1w": 2}) {
+		t.Fatal("bad incr", out, err)
+	}
+	later := now + 3600
+	_, _ = c.Incr("client1", nil, &later)
+
+	tests := []struct {
+		resolution string
+		from, to   int64
+		expect     []CounterPoint
+	}{
+		{"1m", now - 60, now + 60, []CounterPoint{{now - 70, 0}, {now - 10, 2}, {now + 50, 0}}},
+		{"1h", now, later, []CounterPoint{{now - 1810, 2}, {later - 1810, 1}}},
+		// 以周为单位的时间桶从星期一开始
+		{"1w", now, now, []CounterPoint{{time.Date(2023, 11, 13, 0, 0, 0, 0, time.UTC).Unix(), 3}}},
+		{"1m", now, now - 60, []CounterPoint{}},
+	}
+	for _, test := range tests {
+		points, err := c.Range("client1", test.resolution, test.from, test.to)
+		if err != nil || !reflect.DeepEqual(points, test.expect) {
+			t.Error(test.resolution, points, err, test.expect)
+		}
+	}
+	if _, err := c.Range("client1", "1d", now, now); err == nil {
+		t.Error("unknown resolution should fail")
+	}
+	if _, err := c.Range("client1", "1m", now-86400*30, now); err == nil {
+		t.Error("too many points should fail")
+	}
+}
+
+func TestCounterChunks(t *testing.T) {
+	rd := newMemoryRedis(t, "")
+	start := time.Date(2023, 11, 13, 0, 0, 0, 0, time.UTC).Unix()
+	rd.SetClock(start * 1000)
+	c, _ := rd.Counter("chunks", &map[string]interface{}{"resolutions": []string{"1w"}, "retention": 200})
+	// 跨越多个哈希表的时间桶
+	for week := int64(0); week < 130; week += 10 {
+		ts := start + week*604800 + 3600
+		_, _ = c.Incr("d", &week, &ts)
+	}
+	points, err := c.Range("d", "1w", start, start+129*604800)
+	if err != nil || len(points) != 130 {
+		t.Fatal("bad range", len(points), err)
+	}
+	for i, p := range points {
+		expect := int64(0)
+		if i%10 == 0 {
+			expect = int64(i)
+		}
+		if p.Time != start+int64(i)*604800 || p.Value != expect || time.Unix(p.Time, 0).UTC().Weekday() != time.Monday {
+			t.Fatal("bad point", i, p)
+		}
+	}
+}
+
+func TestCounterRedis(t *testing.T) {
+	rd := newTestRedis(t, "?prefix=app:")
+	c, _ := rd.Counter("api", &map[string]interface{}{"resolutions": []string{"1m", "1d"}, "retention": 2})
+	now := time.Now().Unix()
+	for i := 0; i < 3; i++ {
+		_, _ = c.Incr("client1", nil, &now)
+	}
+	points, err := c.Range("client1", "1m", now, now)
+	if err != nil || len(points) != 1 || points[0].Value != 3 || points[0].Time != now-now%60 {
+		t.Fatal("bad range", points, err)
+	}
+	// 哈希表在最后一个时间桶超过保留时间后过期
+	keys := rd.Keys("counter:{api}:1d:client1:*")
+	if len(keys) != 1 || !ttlBetween(rd, keys[0], 86400*2*1000, (60+2)*86400*1000) {
+		t.Fatal("bad chunk key", keys)
+	}
+}
+
+func TestParseCounterResolution(t *testing.T) {
+	tests := []struct {
+		resolution string
+		seconds    int64
+		ok         bool
+	}{
+		{"30s", 30, true},
+		{"5m", 300, true},
+		{"1h", 3600, true},
+		{"1d", 86400, true},
+		{"2w", 1209600, true},
+		{"1y", 0, false},
+		{"m", 0, false},
+		{"0m", 0, false},
+	}
+	for _, test := range tests {
+		seconds, err := parseCounterResolution(test.resolution)
+		if seconds != test.seconds || (err == nil) != test.ok {
+			t.Error(test.resolution, seconds, err)
+		}
+	}
+}
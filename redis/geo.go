@@ -0,0 +1,210 @@
+package redis
+
+import (
+	"strings"
+
+	"github.com/ssgo/u"
+)
+
+type GeoPosition struct {
+	Lon float64
+	Lat float64
+}
+
+type GeoResult struct {
+	Member   interface{}
+	Distance float64
+	Hash     int64
+	Lon      float64
+	Lat      float64
+}
+
+type geoLocation struct {
+	Member interface{}
+	Lon    float64
+	Lat    float64
+}
+
+type geoAddOption struct {
+	Nx bool
+	Xx bool
+	Ch bool
+}
+
+type geoSearchOption struct {
+	FromMember interface{}
+	FromLonLat []float64
+	ByRadius   float64
+	ByBox      []float64
+	Unit       string
+	Sort       string
+	Count      int
+	Any        bool
+	WithCoord  *bool
+	WithDist   *bool
+	WithHash   bool
+	StoreDist  bool
+}
+
+// GeoAdd 添加地理位置
+// GeoAdd locations 位置列表 [{member, lon, lat}]
+// GeoAdd options 选项 {nx, xx, ch}，nx 只添加新成员，xx 只更新已有成员，ch 返回值包含被更新的成员数
+// GeoAdd return 新添加的成员数
+func (rd *Redis) GeoAdd(key string, locations []map[string]interface{}, options *map[string]interface{}) int64 {
+	opt := geoAddOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	args := []interface{}{rd.makeKey(key)}
+	if opt.Nx {
+		args = append(args, "NX")
+	} else if opt.Xx {
+		args = append(args, "XX")
+	}
+	if opt.Ch {
+		args = append(args, "CH")
+	}
+	for _, location := range locations {
+		loc := geoLocation{}
+		u.Convert(location, &loc)
+		args = append(args, loc.Lon, loc.Lat, rd.encodeValue(loc.Member))
+	}
+	reply, _ := rd.doRaw("GEOADD", args...)
+	return replyInt(reply)
+}
+
+// GeoPos 获取成员的经纬度
+// GeoPos return 按照成员的顺序返回 {lon, lat}，成员不存在时返回null
+func (rd *Redis) GeoPos(key string, members ...interface{}) []*GeoPosition {
+	reply, _ := rd.doRaw("GEOPOS", append([]interface{}{rd.makeKey(key)}, rd.encodeValues(members)...)...)
+	arr, _ := reply.([]interface{})
+	out := make([]*GeoPosition, len(arr))
+	for i, v := range arr {
+		if pos, ok := v.([]interface{}); ok && len(pos) == 2 {
+			out[i] = &GeoPosition{Lon: replyFloat(pos[0]), Lat: replyFloat(pos[1])}
+		}
+	}
+	return out
+}
+
+// GeoDist 计算两个成员之间的距离
+// GeoDist unit 距离单位 m（默认）、km、mi、ft
+// GeoDist return 距离，成员不存在时返回null
+func (rd *Redis) GeoDist(key string, member1, member2 interface{}, unit *string) interface{} {
+	args := []interface{}{rd.makeKey(key), rd.encodeValue(member1), rd.encodeValue(member2)}
+	if unit != nil && *unit != "" {
+		args = append(args, strings.ToLower(*unit))
+	}
+	reply, _ := rd.doRaw("GEODIST", args...)
+	if reply == nil {
+		return nil
+	}
+	return replyFloat(reply)
+}
+
+// GeoHash 获取成员位置的 Geohash 字符串
+// GeoHash return 按照成员的顺序返回，成员不存在时返回空字符串
+func (rd *Redis) GeoHash(key string, members ...interface{}) []string {
+	reply, _ := rd.doRaw("GEOHASH", append([]interface{}{rd.makeKey(key)}, rd.encodeValues(members)...)...)
+	return replyStrings(reply)
+}
+
+// GeoSearch 查询指定范围内的成员
+// GeoSearch options 选项 {fromMember, fromLonLat, byRadius, byBox, unit, sort, count, any, withCoord, withDist, withHash}
+// GeoSearch options fromMember 以成员的位置为中心，fromLonLat 以 [lon, lat] 为中心，byRadius 为半径，byBox 为 [width, height]，unit 为 m（默认）、km、mi、ft
+// GeoSearch options sort 为 asc 或 desc，count 限制返回的数量，any 找到count个结果后立即返回，withCoord 和 withDist 默认为true
+// GeoSearch return [{member, distance, hash, lon, lat}]，distance 的单位与 unit 相同
+func (rd *Redis) GeoSearch(key string, options map[string]interface{}) []GeoResult {
+	opt := geoSearchOption{}
+	u.Convert(options, &opt)
+	withCoord := opt.WithCoord == nil || *opt.WithCoord
+	withDist := opt.WithDist == nil || *opt.WithDist
+	args := append([]interface{}{rd.makeKey(key)}, rd.makeGeoSearchArgs(opt)...)
+	if withCoord {
+		args = append(args, "WITHCOORD")
+	}
+	if withDist {
+		args = append(args, "WITHDIST")
+	}
+	if opt.WithHash {
+		args = append(args, "WITHHASH")
+	}
+	reply, _ := rd.doRaw("GEOSEARCH", args...)
+	arr, _ := reply.([]interface{})
+	out := make([]GeoResult, 0, len(arr))
+	for _, v := range arr {
+		item, ok := v.([]interface{})
+		if !ok {
+			// 没有任何 WITH 选项时只返回成员
+			out = append(out, GeoResult{Member: rd.replyValue(v)})
+			continue
+		}
+		if len(item) == 0 {
+			continue
+		}
+		// 回复的顺序固定为 member, dist, hash, coord
+		result := GeoResult{Member: rd.replyValue(item[0])}
+		i := 1
+		if withDist && i < len(item) {
+			result.Distance = replyFloat(item[i])
+			i++
+		}
+		if opt.WithHash && i < len(item) {
+			result.Hash = replyInt(item[i])
+			i++
+		}
+		if withCoord && i < len(item) {
+			if pos, ok := item[i].([]interface{}); ok && len(pos) == 2 {
+				result.Lon = replyFloat(pos[0])
+				result.Lat = replyFloat(pos[1])
+			}
+		}
+		out = append(out, result)
+	}
+	return out
+}
+
+// GeoSearchStore 查询指定范围内的成员并保存到 destination
+// GeoSearchStore options 选项与 GeoSearch 相同，storeDist 为true时保存距离而不是位置
+// GeoSearchStore return 保存的成员数
+func (rd *Redis) GeoSearchStore(destination, key string, options map[string]interface{}) int64 {
+	opt := geoSearchOption{}
+	u.Convert(options, &opt)
+	args := append([]interface{}{rd.makeKey(destination), rd.makeKey(key)}, rd.makeGeoSearchArgs(opt)...)
+	if opt.StoreDist {
+		args = append(args, "STOREDIST")
+	}
+	reply, _ := rd.doRaw("GEOSEARCHSTORE", args...)
+	return replyInt(reply)
+}
+
+func (rd *Redis) makeGeoSearchArgs(opt geoSearchOption) []interface{} {
+	unit := "m"
+	if opt.Unit != "" {
+		unit = strings.ToLower(opt.Unit)
+	}
+	args := make([]interface{}, 0)
+	if opt.FromMember != nil {
+		args = append(args, "FROMMEMBER", rd.encodeValue(opt.FromMember))
+	} else if len(opt.FromLonLat) == 2 {
+		args = append(args, "FROMLONLAT", opt.FromLonLat[0], opt.FromLonLat[1])
+	}
+	if len(opt.ByBox) == 2 {
+		args = append(args, "BYBOX", opt.ByBox[0], opt.ByBox[1], unit)
+	} else {
+		args = append(args, "BYRADIUS", opt.ByRadius, unit)
+	}
+	switch strings.ToUpper(opt.Sort) {
+	case "ASC":
+		args = append(args, "ASC")
+	case "DESC":
+		args = append(args, "DESC")
+	}
+	if opt.Count > 0 {
+		args = append(args, "COUNT", opt.Count)
+		if opt.Any {
+			args = append(args, "ANY")
+		}
+	}
+	return args
+}
@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func addTestCities(t *testing.T, rd *Redis) {
+	t.Helper()
+	n := rd.GeoAdd("cities", []map[string]interface{}{
+		{"member": "Palermo", "lon": 13.361389, "lat": 38.115556},
+		{"member": "Catania", "lon": 15.087269, "lat": 37.502669},
+		{"member": map[string]interface{}{"id": 1}, "lon": 12.758489, "lat": 38.788135},
+	}, nil)
+	if n != 3 {
+		t.Fatal("bad geoadd", n)
+	}
+}
+
+func TestGeo(t *testing.T) {
+	rd := newTestRedis(t, "?prefix=app:")
+	addTestCities(t, rd)
+	if rd.GeoAdd("cities", []map[string]interface{}{{"member": "Palermo", "lon": 13.4, "lat": 38.1}}, &map[string]interface{}{"nx": true}) != 0 {
+		t.Error("nx should not add existing member")
+	}
+	if n := rd.GeoAdd("cities", []map[string]interface{}{{"member": "Palermo", "lon": 13.361389, "lat": 38.115556}, {"member": "Rome", "lon": 12.5, "lat": 41.9}}, &map[string]interface{}{"xx": true, "ch": true}); n != 0 {
+		t.Error("xx should not add new member", n)
+	}
+
+	pos := rd.GeoPos("cities", "Palermo", map[string]interface{}{"id": 1}, "Rome")
+	if len(pos) != 3 || pos[0] == nil || math.Abs(pos[0].Lon-13.361389) > 0.0001 || math.Abs(pos[1].Lat-38.788135) > 0.0001 || pos[2] != nil {
+		t.Error("bad geopos", pos)
+	}
+
+	km := "km"
+	if d, ok := rd.GeoDist("cities", "Palermo", "Catania", &km).(float64); !ok || math.Abs(d-166.2742) > 0.01 {
+		t.Error("bad geodist", d)
+	}
+	if d, ok := rd.GeoDist("cities", "Palermo", "Catania", nil).(float64); !ok || math.Abs(d-166274.15) > 10 {
+		t.Error("bad geodist in meters", d)
+	}
+	if rd.GeoDist("cities", "Palermo", "Rome", nil) != nil {
+		t.Error("geodist of missing member should be null")
+	}
+}
+
+func TestGeoHash(t *testing.T) {
+	rd := newTestRedis(t, "")
+	addTestCities(t, rd)
+	if _, err := rd.doRaw("GEOHASH", "cities"); err != nil {
+		t.Skip("GEOHASH not supported:", err)
+	}
+	if hashes := rd.GeoHash("cities", "Palermo", "Rome"); len(hashes) != 2 || hashes[0] != "sqc8b49rny0" || hashes[1] != "" {
+		t.Error("bad geohash", hashes)
+	}
+}
+
+func TestGeoSearch(t *testing.T) {
+	rd := newTestRedis(t, "?prefix=app:")
+	addTestCities(t, rd)
+	if _, err := rd.doRaw("GEOSEARCH", rd.makeKey("cities"), "FROMLONLAT", 15, 37, "BYRADIUS", 1, "km"); err != nil {
+		t.Skip("GEOSEARCH not supported:", err)
+	}
+
+	results := rd.GeoSearch("cities", map[string]interface{}{"fromLonLat": []float64{15, 37}, "byRadius": 200, "unit": "km", "sort": "asc"})
+	if len(results) != 2 || results[0].Member != "Catania" || results[1].Member != "Palermo" {
+		t.Fatal("bad geosearch", results)
+	}
+	if math.Abs(results[0].Distance-56.4413) > 0.01 || math.Abs(results[0].Lon-15.087269) > 0.0001 || results[0].Hash != 0 {
+		t.Error("bad geosearch result", results[0])
+	}
+
+	results = rd.GeoSearch("cities", map[string]interface{}{"fromMember": "Palermo", "byBox": []float64{400, 400}, "unit": "km", "sort": "desc", "count": 1, "withHash": true})
+	if len(results) != 1 || results[0].Member != "Catania" || results[0].Hash == 0 {
+		t.Error("bad geosearch by box", results)
+	}
+
+	// 不需要距离和位置时只返回成员，对象成员会被反序列化
+	results = rd.GeoSearch("cities", map[string]interface{}{"fromMember": "Palermo", "byRadius": 100, "unit": "km", "sort": "asc", "withCoord": false, "withDist": false})
+	expect := []GeoResult{{Member: "Palermo"}, {Member: map[string]interface{}{"id": float64(1)}}}
+	if !reflect.DeepEqual(results, expect) {
+		t.Error("bad members", results)
+	}
+
+	if n := rd.GeoSearchStore("near", "cities", map[string]interface{}{"fromLonLat": []float64{15, 37}, "byRadius": 100, "unit": "km", "storeDist": true}); n != 1 {
+		t.Error("bad geosearchstore", n)
+	}
+	if score, ok := rd.ZScore("near", "Catania").(float64); !ok || math.Abs(score-56.4413) > 0.01 {
+		t.Error("distance not stored", score)
+	}
+}
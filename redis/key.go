@@ -0,0 +1,155 @@
+package redis
+
+import (
+	"strings"
+
+	"github.com/ssgo/u"
+)
+
+type setOption struct {
+	Ex      int64
+	Px      int64
+	Nx      bool
+	Xx      bool
+	KeepTtl bool
+	Get     bool
+}
+
+type copyOption struct {
+	Db      *int
+	Replace bool
+}
+
+// TTL 获取Key剩余的过期时间
+// TTL return 剩余的秒数，Key不存在时返回-2，没有设置过期时间时返回-1
+func (rd *Redis) TTL(key string) int64 {
+	reply, _ := rd.doRaw("TTL", rd.makeKey(key))
+	return replyInt(reply)
+}
+
+// PTTL 获取Key剩余的过期时间
+// PTTL return 剩余的毫秒数，Key不存在时返回-2，没有设置过期时间时返回-1
+func (rd *Redis) PTTL(key string) int64 {
+	reply, _ := rd.doRaw("PTTL", rd.makeKey(key))
+	return replyInt(reply)
+}
+
+// Persist 移除Key的过期时间
+// Persist return 是否成功，Key不存在或没有设置过期时间时返回false
+func (rd *Redis) Persist(key string) bool {
+	reply, _ := rd.doRaw("PERSIST", rd.makeKey(key))
+	return replyBool(reply)
+}
+
+// PExpire 设置Key的过期时间
+// PExpire milliseconds 过期时间的毫秒数
+// PExpire return 是否成功
+func (rd *Redis) PExpire(key string, milliseconds int64) bool {
+	reply, _ := rd.doRaw("PEXPIRE", rd.makeKey(key), milliseconds)
+	return replyBool(reply)
+}
+
+// Type 获取Key存储的数据类型
+// Type return string、list、set、zset、hash、stream，Key不存在时返回none
+func (rd *Redis) Type(key string) string {
+	reply, _ := rd.doRaw("TYPE", rd.makeKey(key))
+	return replyString(reply)
+}
+
+// Rename 修改Key的名称，newKey 已经存在时会被覆盖
+// Rename return 是否成功，Key不存在时返回false
+func (rd *Redis) Rename(key, newKey string) bool {
+	reply, _ := rd.doRaw("RENAME", rd.makeKey(key), rd.makeKey(newKey))
+	return replyBool(reply)
+}
+
+// RenameNX 仅当 newKey 不存在时修改Key的名称
+// RenameNX return 是否成功
+func (rd *Redis) RenameNX(key, newKey string) bool {
+	reply, _ := rd.doRaw("RENAMENX", rd.makeKey(key), rd.makeKey(newKey))
+	return replyBool(reply)
+}
+
+// Copy 复制Key的内容到 destination
+// Copy options 选项 {db, replace}，db 为目标数据库，replace 为 destination 已经存在时是否覆盖
+// Copy return 是否成功
+func (rd *Redis) Copy(source, destination string, options *map[string]interface{}) bool {
+	opt := copyOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	args := []interface{}{rd.makeKey(source), rd.makeKey(destination)}
+	if opt.Db != nil {
+		args = append(args, "DB", *opt.Db)
+	}
+	if opt.Replace {
+		args = append(args, "REPLACE")
+	}
+	reply, _ := rd.doRaw("COPY", args...)
+	return replyBool(reply)
+}
+
+// Unlink 在后台异步删除Key，不会阻塞服务器
+// Unlink return 成功删除的个数
+func (rd *Redis) Unlink(keys ...string) int64 {
+	reply, _ := rd.doRaw("UNLINK", rd.makeKeys(keys)...)
+	return replyInt(reply)
+}
+
+// Touch 更新Key的最后访问时间
+// Touch return 存在的Key的个数
+func (rd *Redis) Touch(keys ...string) int64 {
+	reply, _ := rd.doRaw("TOUCH", rd.makeKeys(keys)...)
+	return replyInt(reply)
+}
+
+// SetOpts 使用 SET 命令的原生选项存储内容到Key，所有选项在一个命令中原子执行
+// SetOpts options 选项 {ex, px, nx, xx, keepTtl, get}，ex 为过期秒数，px 为过期毫秒数，nx 仅当Key不存在时设置，xx 仅当Key存在时设置，keepTtl 保留原有的过期时间，get 返回旧的值
+// SetOpts return 是否成功，指定get时返回旧的值（Key不存在时返回null）
+func (rd *Redis) SetOpts(key string, value interface{}, options map[string]interface{}) interface{} {
+	opt := setOption{}
+	u.Convert(options, &opt)
+	args := []interface{}{rd.makeKey(key), rd.encodeValue(value)}
+	if opt.Ex > 0 {
+		args = append(args, "EX", opt.Ex)
+	} else if opt.Px > 0 {
+		args = append(args, "PX", opt.Px)
+	} else if opt.KeepTtl {
+		args = append(args, "KEEPTTL")
+	}
+	if opt.Nx {
+		args = append(args, "NX")
+	} else if opt.Xx {
+		args = append(args, "XX")
+	}
+	if opt.Get {
+		args = append(args, "GET")
+	}
+	reply, err := rd.doRaw("SET", args...)
+	if opt.Get {
+		if err != nil {
+			return nil
+		}
+		return rd.replyValue(reply)
+	}
+	return replyBool(reply)
+}
+
+// GetEX 读取Key的内容并更新过期时间，服务器支持时使用原子的 GETEX 命令
+// GetEX seconds 过期时间的秒数
+// GetEX return 如果是一个对象则返回反序列化后的对象，否则返回字符串
+func (rd *Redis) GetEX(key string, seconds int) interface{} {
+	reply, err := rd.doRaw("GETEX", rd.makeKey(key), "EX", seconds)
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "unknown command") {
+		// Redis 6.2 以前的版本不支持 GETEX
+		r := rd.pool.Do("GET " + rd.makeKey(key))
+		if r.String() != "" {
+			rd.pool.EXPIRE(rd.makeKey(key), seconds)
+		}
+		return rd.makeRedisResult(r)
+	}
+	if reply == nil {
+		return rd.decodeBytes(nil)
+	}
+	return rd.replyValue(reply)
+}
@@ -0,0 +1,94 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ssgo/redis"
+)
+
+// ttlBetween 判断Key剩余的毫秒数是否在范围内
+func ttlBetween(rd *Redis, key string, min, max int64) bool {
+	pttl := rd.PTTL(key)
+	return pttl > min && pttl <= max
+}
+
+func TestKeyCommands(t *testing.T) {
+	rd := newTestRedis(t, "?prefix=app:")
+	rd.Set("a", "1")
+	if rd.TTL("a") != -1 || rd.PTTL("none") != -2 || rd.Type("a") != "string" || rd.Type("none") != "none" {
+		t.Error("bad ttl or type")
+	}
+	if !rd.PExpire("a", 100000) || rd.PTTL("a") <= 90000 || !rd.Persist("a") || rd.Persist("a") {
+		t.Error("bad pexpire or persist")
+	}
+
+	if !rd.Rename("a", "b") || rd.Exists("a") || rd.Get("b") != float64(1) || rd.Rename("none", "c") {
+		t.Error("bad rename")
+	}
+	rd.Set("c", "3")
+	if rd.RenameNX("b", "c") || !rd.RenameNX("b", "d") {
+		t.Error("bad renamenx")
+	}
+	if !rd.Copy("c", "e", nil) || rd.Copy("c", "d", nil) || !rd.Copy("c", "d", &map[string]interface{}{"replace": true}) || rd.Get("d") != float64(3) {
+		t.Error("bad copy")
+	}
+	if rd.Touch("c", "d", "none") != 2 || rd.Unlink("c", "d", "none") != 2 {
+		t.Error("bad touch or unlink")
+	}
+	if keys := rd.Keys("*"); !reflect.DeepEqual(keys, []string{"e"}) {
+		t.Error("bad keys", keys)
+	}
+}
+
+func TestSetOpts(t *testing.T) {
+	rd := newTestRedis(t, "")
+	if rd.SetOpts("k", "v1", map[string]interface{}{"xx": true}) != false {
+		t.Error("xx should fail on missing key")
+	}
+	if rd.SetOpts("k", "v1", map[string]interface{}{"nx": true, "ex": 100}) != true || !ttlBetween(rd, "k", 99000, 100000) {
+		t.Error("bad nx with ex")
+	}
+	if rd.SetOpts("k", "v2", map[string]interface{}{"nx": true}) != false || rd.Get("k") != "v1" {
+		t.Error("nx should fail on existing key")
+	}
+	if out := rd.SetOpts("k", map[string]interface{}{"a": 1}, map[string]interface{}{"keepTtl": true, "get": true}); out != "v1" || !ttlBetween(rd, "k", 99000, 100000) {
+		t.Error("bad get with keepTtl", out)
+	}
+	if out := rd.Get("k"); !reflect.DeepEqual(out, map[string]interface{}{"a": float64(1)}) {
+		t.Error("bad value", out)
+	}
+	if out := rd.SetOpts("new", "v", map[string]interface{}{"px": 5000, "get": true}); out != nil || rd.PTTL("new") <= 4000 {
+		t.Error("get on missing key should return null", out)
+	}
+}
+
+func TestGetEX(t *testing.T) {
+	rd := newTestRedis(t, "")
+	rd.Set("k", `{"a":1}`)
+	if out := rd.GetEX("k", 100); !reflect.DeepEqual(out, map[string]interface{}{"a": float64(1)}) || !ttlBetween(rd, "k", 99000, 100000) {
+		t.Error("bad getex", out)
+	}
+	if out := rd.GetEX("none", 100); out != "" || rd.Exists("none") {
+		t.Error("bad getex on missing key", out)
+	}
+}
+
+// 服务器不支持 GETEX 时使用 GET + EXPIRE
+func TestGetEXFallback(t *testing.T) {
+	addr, err := startRedisServer("--rename-command", "GETEX", "")
+	if err != nil {
+		t.Skip("no redis-server", err)
+	}
+	rd := newRedis(redis.GetRedis("redis://"+addr+"/1", nil), defaultOption)
+	if _, err := rd.doRaw("GETEX", "k", "EX", 1); err == nil {
+		t.Fatal("GETEX not disabled")
+	}
+	rd.Set("k", "v")
+	if out := rd.GetEX("k", 100); out != "v" || !ttlBetween(rd, "k", 99000, 100000) {
+		t.Error("bad getex fallback", out)
+	}
+	if out := rd.GetEX("none", 100); out != "" || rd.Exists("none") {
+		t.Error("bad getex fallback on missing key", out)
+	}
+}
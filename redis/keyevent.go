@@ -0,0 +1,97 @@
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/api-go/plugin"
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/ssgo/u"
+)
+
+// notify-keyspace-events 中事件对应的标记，其他事件使用 A 开启
+var keyEventFlags = map[string]string{"expired": "x", "evicted": "e", "del": "g"}
+
+// A 是 g$lshzxetd 的别名
+const keyEventAllFlags = "g$lshzxetd"
+
+type keyEventOption struct {
+	Configure bool
+}
+
+// OnKeyEvent 监听Key的事件（键事件通知），在独立的连接上接收，脚本结束时自动取消，也可以使用 unsubscribe() 取消，redis-cluster 中事件分散在各个节点上，不支持监听
+// OnKeyEvent events 事件列表，例如 expired、del、evicted，不指定时监听这三种事件
+// OnKeyEvent pattern 只处理匹配的Key，例如 "order:*"，支持 *、?、[abc]，空字符串表示全部
+// OnKeyEvent callback 回调函数，参数为(key, event)，key 已经去掉了前缀
+// OnKeyEvent options 选项 {configure}，configure 为true时修改服务器的 notify-keyspace-events 开启需要的事件（需要CONFIG权限）
+func (rd *Redis) OnKeyEvent(ctx *plugin.Context, events []string, pattern string, callback func(string, string), options *map[string]interface{}) error {
+	opt := keyEventOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	if callback == nil {
+		return errors.New("no callback to receive key events")
+	}
+	if isClusterPool(rd.pool) {
+		// 键事件只在产生事件的节点上发布，集群中需要分别订阅每个节点
+		return errors.New("key events are not supported on redis-cluster")
+	}
+	if len(events) == 0 {
+		events = []string{"expired", "del", "evicted"}
+	}
+	if opt.Configure {
+		if err := rd.configureKeyEvents(events); err != nil {
+			return err
+		}
+	}
+
+	channelPrefix := "__keyevent@" + strconv.Itoa(rd.pool.Config.DB) + "__:"
+	// 系统频道不使用Key前缀和值编码
+	sub := rd.newSubscription(false, func(message redigo.Message) {
+		key := string(message.Data)
+		// 忽略不属于当前前缀的Key
+		if string(rd.keyPrefix) != "" && !strings.HasPrefix(key, string(rd.keyPrefix)) {
+			return
+		}
+		key = rd.trimKey(key)
+		if pattern == "" || matchGlob(pattern, key) {
+			callback(key, strings.TrimPrefix(message.Channel, channelPrefix))
+		}
+	})
+	for _, event := range events {
+		sub.names[channelPrefix+strings.ToLower(event)] = true
+	}
+	return rd.startSubscription(ctx, sub)
+}
+
+// configureKeyEvents 在服务器现有的配置上添加事件需要的标记
+func (rd *Redis) configureKeyEvents(events []string) error {
+	reply, err := rd.doRaw("CONFIG", "GET", "notify-keyspace-events")
+	if err != nil {
+		return err
+	}
+	current := ""
+	if values := replyStrings(reply); len(values) == 2 {
+		current = values[1]
+	}
+	flags := current
+	addFlag := func(flag string) {
+		if !strings.Contains(flags, flag) && !(strings.Contains(flags, "A") && strings.Contains(keyEventAllFlags, flag)) {
+			flags += flag
+		}
+	}
+	addFlag("E")
+	for _, event := range events {
+		if flag := keyEventFlags[strings.ToLower(event)]; flag != "" {
+			addFlag(flag)
+		} else {
+			addFlag("A")
+		}
+	}
+	if flags == current {
+		return nil
+	}
+	_, err = rd.doRaw("CONFIG", "SET", "notify-keyspace-events", flags)
+	return err
+}
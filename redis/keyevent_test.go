@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/api-go/plugin"
+	"github.com/api-go/plugins/runtime"
+)
+
+// receiveKeyEvents 返回一个键事件回调和接收回调参数的通道，事件格式为 "event key"
+func receiveKeyEvents() (func(string, string), chan string) {
+	events := make(chan string, 10)
+	return func(key, event string) {
+		events <- event + " " + key
+	}, events
+}
+
+func waitKeyEvent(t *testing.T, events chan string, expect string) {
+	t.Helper()
+	timeout := time.Second
+	if expect == "" {
+		timeout = 50 * time.Millisecond
+	}
+	select {
+	case event := <-events:
+		if event != expect {
+			t.Errorf("bad key event %q, expect %q", event, expect)
+		}
+	case <-time.After(timeout):
+		if expect != "" {
+			t.Errorf("no key event, expect %q", expect)
+		}
+	}
+}
+
+func TestKeyEvent(t *testing.T) {
+	rd := newMemoryRedis(t, "?prefix=app:")
+	other := newMemoryRedis(t, "")
+	ctx := plugin.NewContext(nil)
+	callback, events := receiveKeyEvents()
+	if err := rd.OnKeyEvent(ctx, nil, "order:*", callback, &map[string]interface{}{"configure": true}); err != nil {
+		t.Fatal(err)
+	}
+	rd.SetClock(1700000000000)
+	rd.SetEX("order:1", 10, "a")
+	rd.Set("order:2", "b")
+	rd.Set("user:1", "c")
+	other.Set("order:3", "d")
+
+	rd.Del("order:2", "user:1")
+	waitKeyEvent(t, events, "del order:2")
+	rd.AdvanceClock(10000)
+	waitKeyEvent(t, events, "expired order:1")
+	// 不匹配的Key和其他前缀的Key不会触发回调
+	other.Del("order:3")
+	waitKeyEvent(t, events, "")
+
+	// 脚本结束时自动取消
+	runtime.RunDestructors(ctx)
+	if len(rd.subs) != 0 {
+		t.Fatal("key event listener not stopped", rd.subs)
+	}
+	rd.Set("order:4", "e")
+	rd.Del("order:4")
+	waitKeyEvent(t, events, "")
+}
+
+func TestKeyEventRedis(t *testing.T) {
+	rd := newTestRedis(t, "")
+	if _, err := rd.doRaw("CONFIG", "GET", "notify-keyspace-events"); err != nil {
+		t.Skip("server does not support key events: ", err)
+	}
+	ctx := plugin.NewContext(nil)
+	defer runtime.RunDestructors(ctx)
+	callback, events := receiveKeyEvents()
+	if err := rd.OnKeyEvent(ctx, []string{"expired", "del"}, "", callback, &map[string]interface{}{"configure": true}); err != nil {
+		t.Skip("server does not support key events: ", err)
+	}
+	rd.Set("a", 1)
+	rd.Del("a")
+	waitKeyEvent(t, events, "del a")
+	rd.Set("b", 1)
+	rd.PExpire("b", 10)
+	waitKeyEvent(t, events, "expired b")
+}
+
+func TestKeyEventErrors(t *testing.T) {
+	rd := newMemoryRedis(t, "")
+	if err := rd.OnKeyEvent(nil, nil, "", nil, nil); err == nil {
+		t.Error("key events without callback should fail")
+	}
+}
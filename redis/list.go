@@ -0,0 +1,130 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/ssgo/u"
+)
+
+type ListPopResult struct {
+	Key   string
+	Value interface{}
+}
+
+type lPosOption struct {
+	Rank   int
+	Count  *int
+	MaxLen int
+}
+
+func makeBlockingTimeout(timeout float64) time.Duration {
+	return time.Duration(timeout * float64(time.Second))
+}
+
+// BLPop 阻塞式移出并获取列表的第一个元素，在独立的连接上执行
+// BLPop keys 一个或多个列表，按照顺序检查第一个非空的列表
+// BLPop timeout 最多等待的秒数（支持小数），0 表示一直等待
+// BLPop return {key, value}，超时时返回null
+func (rd *Redis) BLPop(keys []string, timeout float64) *ListPopResult {
+	return rd.bPop("BLPOP", keys, timeout)
+}
+
+// BRPop 阻塞式移出并获取列表的最后一个元素，在独立的连接上执行
+// BRPop return {key, value}，超时时返回null
+func (rd *Redis) BRPop(keys []string, timeout float64) *ListPopResult {
+	return rd.bPop("BRPOP", keys, timeout)
+}
+
+func (rd *Redis) bPop(cmd string, keys []string, timeout float64) *ListPopResult {
+	args := append(rd.makeKeys(keys), timeout)
+	reply, _ := rd.doBlocking(makeBlockingTimeout(timeout), cmd, args...)
+	arr, _ := reply.([]interface{})
+	if len(arr) != 2 {
+		return nil
+	}
+	return &ListPopResult{Key: rd.trimKey(replyString(arr[0])), Value: rd.replyValue(arr[1])}
+}
+
+// BLMove 阻塞式从 source 列表中移出一个元素并放入 destination 列表，在独立的连接上执行
+// BLMove whereFrom 从 source 的哪一端移出，LEFT 或 RIGHT
+// BLMove whereTo 放入 destination 的哪一端，LEFT 或 RIGHT
+// BLMove timeout 最多等待的秒数（支持小数），0 表示一直等待
+// BLMove return 移动的元素，超时时返回null
+func (rd *Redis) BLMove(source, destination, whereFrom, whereTo string, timeout float64) interface{} {
+	reply, _ := rd.doBlocking(makeBlockingTimeout(timeout), "BLMOVE", rd.makeKey(source), rd.makeKey(destination), whereFrom, whereTo, timeout)
+	return rd.replyValue(reply)
+}
+
+// BRPopLPush 阻塞式从 source 列表中移出最后一个元素并放入 destination 列表的头部，在独立的连接上执行
+// BRPopLPush return 移动的元素，超时时返回null
+func (rd *Redis) BRPopLPush(source, destination string, timeout float64) interface{} {
+	reply, _ := rd.doBlocking(makeBlockingTimeout(timeout), "BRPOPLPUSH", rd.makeKey(source), rd.makeKey(destination), timeout)
+	return rd.replyValue(reply)
+}
+
+// LMove 从 source 列表中移出一个元素并放入 destination 列表
+// LMove return 移动的元素，source 为空时返回null
+func (rd *Redis) LMove(source, destination, whereFrom, whereTo string) interface{} {
+	reply, _ := rd.doRaw("LMOVE", rd.makeKey(source), rd.makeKey(destination), whereFrom, whereTo)
+	return rd.replyValue(reply)
+}
+
+// LRem 移除列表中与 value 相等的元素
+// LRem count 大于0时从头部开始移除count个，小于0时从尾部开始移除，等于0时移除全部
+// LRem return 成功移除的个数
+func (rd *Redis) LRem(key string, count int, value interface{}) int64 {
+	reply, _ := rd.doRaw("LREM", rd.makeKey(key), count, rd.encodeValue(value))
+	return replyInt(reply)
+}
+
+// LTrim 只保留列表指定范围内的元素
+func (rd *Redis) LTrim(key string, start, stop int) bool {
+	reply, _ := rd.doRaw("LTRIM", rd.makeKey(key), start, stop)
+	return replyBool(reply)
+}
+
+// LIndex 获取列表中指定位置的元素
+// LIndex index 位置，负数表示从尾部开始计算
+// LIndex return 元素，不存在时返回null
+func (rd *Redis) LIndex(key string, index int) interface{} {
+	reply, _ := rd.doRaw("LINDEX", rd.makeKey(key), index)
+	return rd.replyValue(reply)
+}
+
+// LSet 设置列表中指定位置的元素
+func (rd *Redis) LSet(key string, index int, value interface{}) bool {
+	reply, _ := rd.doRaw("LSET", rd.makeKey(key), index, rd.encodeValue(value))
+	return replyBool(reply)
+}
+
+// LPos 查找元素在列表中的位置
+// LPos options 选项 {rank, count, maxLen}，rank 指定返回第几个匹配（负数从尾部开始），count 指定时返回多个位置，maxLen 限制比较的元素数量
+// LPos return 位置，不存在时返回null，指定count时返回位置数组
+func (rd *Redis) LPos(key string, value interface{}, options *map[string]interface{}) interface{} {
+	opt := lPosOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	args := []interface{}{rd.makeKey(key), rd.encodeValue(value)}
+	if opt.Rank != 0 {
+		args = append(args, "RANK", opt.Rank)
+	}
+	if opt.Count != nil {
+		args = append(args, "COUNT", *opt.Count)
+	}
+	if opt.MaxLen > 0 {
+		args = append(args, "MAXLEN", opt.MaxLen)
+	}
+	reply, _ := rd.doRaw("LPOS", args...)
+	if reply == nil {
+		return nil
+	}
+	if arr, ok := reply.([]interface{}); ok {
+		out := make([]int64, len(arr))
+		for i, v := range arr {
+			out[i] = replyInt(v)
+		}
+		return out
+	}
+	return replyInt(reply)
+}
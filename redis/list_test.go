@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBlockingPop(t *testing.T) {
+	rd := newTestRedis(t, "")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var result *ListPopResult
+	go func() {
+		defer wg.Done()
+		result = rd.BLPop([]string{"empty", "jobs"}, 2)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	rd.RPush("jobs", `{"id":1}`)
+	wg.Wait()
+	if result == nil || result.Key != "jobs" || !reflect.DeepEqual(result.Value, map[string]interface{}{"id": float64(1)}) {
+		t.Fatal("bad blocking pop", result)
+	}
+
+	startTime := time.Now()
+	if rd.BRPop([]string{"jobs"}, 0.1) != nil || time.Since(startTime) < 100*time.Millisecond {
+		t.Fatal("blocking pop should time out")
+	}
+	rd.RPush("jobs", "a", "b")
+	if result = rd.BRPop([]string{"jobs"}, 1); result == nil || result.Value != "b" {
+		t.Fatal("bad brpop", result)
+	}
+}
+
+func TestBlockingMove(t *testing.T) {
+	rd := newTestRedis(t, "")
+	rd.RPush("src", "a", "b")
+	if v := rd.BLMove("src", "dst", "LEFT", "RIGHT", 1); v != "a" {
+		t.Fatal("bad blmove", v)
+	}
+	if v := rd.BRPopLPush("src", "dst", 1); v != "b" {
+		t.Fatal("bad brpoplpush", v)
+	}
+	if v := rd.BLMove("src", "dst", "LEFT", "RIGHT", 0.05); v != nil {
+		t.Fatal("blmove should time out", v)
+	}
+	if out := rd.LRange("dst", 0, -1); !reflect.DeepEqual(out, []interface{}{"b", "a"}) {
+		t.Fatal("bad destination", out)
+	}
+}
+
+func TestListCommands(t *testing.T) {
+	rd := newTestRedis(t, "")
+	rd.RPush("l", "a", "b", "a", "c", "a")
+	if rd.LIndex("l", -1) != "a" || rd.LIndex("l", 10) != nil {
+		t.Error("bad lindex")
+	}
+	if rd.LPos("l", "a", nil) != int64(0) || rd.LPos("l", "none", nil) != nil {
+		t.Error("bad lpos")
+	}
+	if out := rd.LPos("l", "a", &map[string]interface{}{"rank": -1}); out != int64(4) {
+		t.Error("bad lpos rank", out)
+	}
+	if out := rd.LPos("l", "a", &map[string]interface{}{"count": 0}); !reflect.DeepEqual(out, []int64{0, 2, 4}) {
+		t.Error("bad lpos count", out)
+	}
+	if rd.LRem("l", -2, "a") != 2 || !rd.LSet("l", 0, "x") || rd.LSet("l", 10, "x") {
+		t.Error("bad lrem or lset")
+	}
+	if !rd.LTrim("l", 1, -1) {
+		t.Error("bad ltrim")
+	}
+	if out := rd.LRange("l", 0, -1); !reflect.DeepEqual(out, []interface{}{"b", "c"}) {
+		t.Error("bad list", out)
+	}
+	if rd.LMove("l", "l2", "RIGHT", "LEFT") != "c" || rd.LMove("none", "l2", "LEFT", "LEFT") != nil {
+		t.Error("bad lmove")
+	}
+}
@@ -0,0 +1,196 @@
+package redis
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/api-go/plugin"
+	"github.com/api-go/plugins/runtime"
+	"github.com/ssgo/u"
+)
+
+// 获取锁并生成递增的栅栏令牌，令牌可以用来拒绝过期锁持有者的写入
+var lockAcquireScript = newLuaScript(`if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return false`)
+
+var lockReleaseScript = newLuaScript(`if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+
+var lockExtendScript = newLuaScript(`if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`)
+
+type Lock struct {
+	rd        *Redis
+	key       string
+	token     string
+	fence     int64
+	ttl       int
+	held      bool
+	renewStop chan bool
+	lock      sync.Mutex
+}
+
+type lockOption struct {
+	Wait          int
+	RetryInterval int
+	AutoRenew     bool
+	MaxHold       int
+}
+
+// Lock 获取分布式锁，脚本结束时自动释放
+// Lock ttl 锁的过期时间，单位毫秒，必须大于0
+// Lock options 选项 {wait, retryInterval, autoRenew, maxHold}，wait 为获取锁时最多等待的毫秒数，retryInterval 为重试间隔（默认100毫秒）
+// Lock options autoRenew 在后台自动续期直到解锁或脚本结束，maxHold 为自动续期的最长毫秒数（默认600000），没有在脚本中运行时避免一直持有
+// Lock return 锁对象，等待超时仍未获取到时返回null
+func (rd *Redis) Lock(ctx *plugin.Context, key string, ttl int, options *map[string]interface{}) (*Lock, error) {
+	if ttl <= 0 {
+		return nil, errors.New("lock ttl must be greater than 0")
+	}
+	opt := lockOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	if opt.RetryInterval <= 0 {
+		opt.RetryInterval = 100
+	}
+	if opt.MaxHold <= 0 {
+		opt.MaxHold = 600000
+	}
+
+	key = rd.makeKey(key)
+	l := &Lock{rd: rd, key: key, token: hex.EncodeToString(u.MakeToken(16)), ttl: ttl}
+	deadline := time.Now().Add(time.Duration(opt.Wait) * time.Millisecond)
+	for {
+		// 栅栏令牌与锁位于集群的同一个槽位
+		reply, err := rd.evalScript(lockAcquireScript, []string{key, sameSlotKey(key, ":fence")}, l.token, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if reply != nil {
+			l.fence = replyInt(reply)
+			l.held = true
+			break
+		}
+		if !time.Now().Before(deadline) {
+			return nil, nil
+		}
+		time.Sleep(time.Duration(opt.RetryInterval) * time.Millisecond)
+	}
+
+	if opt.AutoRenew {
+		l.renewStop = make(chan bool, 1)
+		go l.autoRenew(l.renewStop, time.Now().Add(time.Duration(opt.MaxHold)*time.Millisecond))
+	}
+	runtime.AddDestructor(ctx, func() {
+		l.Unlock()
+	})
+	return l, nil
+}
+
+// autoRenew 按照过期时间的1/3定期续期，超过 deadline 后不再续期，锁随后自然过期
+func (l *Lock) autoRenew(stop chan bool, deadline time.Time) {
+	interval := time.Duration(l.getTtl()) * time.Millisecond / 3
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if time.Now().After(deadline) || !l.Extend(l.getTtl()) {
+				return
+			}
+		}
+	}
+}
+
+func (l *Lock) getTtl() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.ttl
+}
+
+// Unlock 释放锁，只有锁仍然由当前对象持有时才会删除
+// Unlock return 是否成功释放
+func (l *Lock) Unlock() bool {
+	l.lock.Lock()
+	if !l.held {
+		l.lock.Unlock()
+		return false
+	}
+	l.held = false
+	if l.renewStop != nil {
+		l.renewStop <- true
+		l.renewStop = nil
+	}
+	l.lock.Unlock()
+
+	reply, _ := l.rd.evalScript(lockReleaseScript, []string{l.key}, l.token)
+	return replyInt(reply) == 1
+}
+
+// Extend 延长锁的过期时间
+// Extend ttl 新的过期时间，单位毫秒，必须大于0
+// Extend return 是否成功，锁已经过期或被其他对象持有时返回false
+func (l *Lock) Extend(ttl int) bool {
+	if ttl <= 0 {
+		return false
+	}
+	reply, _ := l.rd.evalScript(lockExtendScript, []string{l.key}, l.token, ttl)
+	ok := replyInt(reply) == 1
+	l.lock.Lock()
+	if !ok {
+		l.held = false
+	} else {
+		l.ttl = ttl
+	}
+	l.lock.Unlock()
+	return ok
+}
+
+// IsHeld 检查锁是否仍然由当前对象持有
+func (l *Lock) IsHeld() bool {
+	reply, _ := l.rd.doRaw("GET", l.key)
+	return replyString(reply) == l.token
+}
+
+// Token 获取锁的随机令牌
+func (l *Lock) Token() string {
+	return l.token
+}
+
+// Fence 获取栅栏令牌，每次成功获取锁时递增，可以在写入存储时用来拒绝过期的锁持有者
+func (l *Lock) Fence() int64 {
+	return l.fence
+}
+
+// hashTag 获取Key中 {tag} 的内容，集群中只使用 tag 计算槽位
+func hashTag(key string) (string, bool) {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end], true
+		}
+	}
+	return "", false
+}
+
+// sameSlotKey 生成与 key 在集群中位于同一个槽位的关联Key，用于脚本和多Key命令
+// key 中没有 {tag} 时使用整个 key 作为 tag，例如 lock:a 生成 {lock:a}:fence
+func sameSlotKey(key, suffix string) string {
+	if _, ok := hashTag(key); ok {
+		return key + suffix
+	}
+	return "{" + key + "}" + suffix
+}
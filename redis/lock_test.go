@@ -0,0 +1,113 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/api-go/plugin"
+	"github.com/api-go/plugins/runtime"
+)
+
+func pttl(rd *Redis, key string) int64 {
+	reply, _ := rd.doRaw("PTTL", key)
+	return replyInt(reply)
+}
+
+func TestLock(t *testing.T) {
+	rd := newTestRedis(t, "")
+	l1, err := rd.Lock(nil, "job", 1000, nil)
+	if err != nil || l1 == nil || !l1.IsHeld() || l1.Fence() != 1 {
+		t.Fatal("lock not acquired", l1, err)
+	}
+	if l2, err := rd.Lock(nil, "job", 1000, nil); l2 != nil || err != nil {
+		t.Fatal("lock acquired twice", err)
+	}
+	if !l1.Extend(5000) || pttl(rd, "job") <= 1000 || l1.Extend(0) {
+		t.Fatal("extend failed", pttl(rd, "job"))
+	}
+	if !l1.Unlock() || l1.Unlock() || rd.Exists("job") {
+		t.Fatal("unlock failed")
+	}
+
+	// 栅栏令牌与锁在同一个槽位，每次获取时递增
+	if sameSlotKey("job", ":fence") != "{job}:fence" || sameSlotKey("{user}:job", ":fence") != "{user}:job:fence" {
+		t.Fatal("bad fence key")
+	}
+	l3, _ := rd.Lock(nil, "job", 50, nil)
+	if l3 == nil || l3.Fence() != 2 {
+		t.Fatal("bad fence", l3)
+	}
+
+	// 过期后其他对象可以获取，原来的持有者无法释放或续期
+	time.Sleep(100 * time.Millisecond)
+	l4, _ := rd.Lock(nil, "job", 1000, nil)
+	if l4 == nil || l3.IsHeld() || l3.Extend(1000) || l3.Unlock() || !l4.IsHeld() {
+		t.Fatal("expired lock still held")
+	}
+}
+
+func TestLockBadTtl(t *testing.T) {
+	rd := newTestRedis(t, "")
+	for _, ttl := range []int{0, -1} {
+		startTime := time.Now()
+		if l, err := rd.Lock(nil, "job", ttl, &map[string]interface{}{"wait": 1000}); l != nil || err == nil || time.Since(startTime) > 100*time.Millisecond {
+			t.Fatal("bad ttl should fail at once", ttl, l, err)
+		}
+	}
+}
+
+func TestLockWait(t *testing.T) {
+	rd := newTestRedis(t, "")
+	l1, _ := rd.Lock(nil, "job", 1000, nil)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l1.Unlock()
+	}()
+	startTime := time.Now()
+	l2, _ := rd.Lock(nil, "job", 1000, &map[string]interface{}{"wait": 1000, "retryInterval": 10})
+	if l2 == nil || time.Since(startTime) < 50*time.Millisecond {
+		t.Fatal("wait failed")
+	}
+	if l3, _ := rd.Lock(nil, "job", 1000, &map[string]interface{}{"wait": 30, "retryInterval": 10}); l3 != nil {
+		t.Fatal("lock acquired while held")
+	}
+}
+
+func TestLockScriptEnd(t *testing.T) {
+	rd := newTestRedis(t, "")
+	ctx := plugin.NewContext(nil)
+	l, _ := rd.Lock(ctx, "job", 60, &map[string]interface{}{"autoRenew": true})
+	time.Sleep(200 * time.Millisecond)
+	if !l.IsHeld() {
+		t.Fatal("lock not renewed")
+	}
+
+	// 脚本结束时释放锁并停止续期
+	runtime.RunDestructors(ctx)
+	if l.IsHeld() || rd.Exists("job") {
+		t.Fatal("lock not released at script end")
+	}
+	if l2, _ := rd.Lock(nil, "job", 1000, nil); l2 == nil {
+		t.Fatal("lock not acquired after script end")
+	}
+}
+
+func TestLockAutoRenew(t *testing.T) {
+	rd := newTestRedis(t, "")
+	l, _ := rd.Lock(nil, "job", 60, &map[string]interface{}{"autoRenew": true})
+	// 续期与修改过期时间同时进行
+	if !l.Extend(90) {
+		t.Fatal("extend failed")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if !l.IsHeld() || !l.Unlock() {
+		t.Fatal("unlock failed")
+	}
+
+	// 超过 maxHold 后停止续期，没有解锁的锁自然过期
+	l, _ = rd.Lock(nil, "job2", 60, &map[string]interface{}{"autoRenew": true, "maxHold": 100})
+	time.Sleep(300 * time.Millisecond)
+	if l.IsHeld() {
+		t.Fatal("lock renewed after maxHold")
+	}
+}
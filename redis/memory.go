@@ -0,0 +1,733 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/ssgo/redis"
+)
+
+var errMemoryConnClosed = errors.New("memory: use of closed network connection")
+
+// memoryStores 相同名称和数据库的 memory:// 地址共享同一份数据
+var memoryStores = map[string]*memoryStore{}
+
+// memoryPools 按照连接池查找对应的存储，用来控制时钟
+var memoryPools = map[*redigo.Pool]*memoryStore{}
+var memoryStoresLock sync.Mutex
+
+// memoryEntry 一个Key的值和过期时间
+// value 的类型：string 为 []byte，hash 为 map[string][]byte，list 为 [][]byte，set 为 map[string]bool，zset 为 map[string]float64
+type memoryEntry struct {
+	value    interface{}
+	expireAt int64
+}
+
+// memoryStore 进程内的Redis实现，用于在没有Redis服务器的环境中测试脚本
+// 地址格式：memory://name/db，过期时间使用可以控制的时钟，方便测试过期相关的逻辑
+// 不支持 Stream、Geo、BITFIELD 和任意的Lua脚本（只模拟插件内置的脚本），执行时返回错误
+type memoryStore struct {
+	db        string
+	entries   map[string]*memoryEntry
+	versions  map[string]uint64
+	version   uint64
+	conns     map[*memoryConn]bool
+	config    map[string]string
+	cmd       string
+	changed   chan bool
+	fixedTime int64
+	offset    int64
+	lock      sync.Mutex
+}
+
+func newMemoryStore(db string) *memoryStore {
+	return &memoryStore{
+		db:       db,
+		entries:  map[string]*memoryEntry{},
+		versions: map[string]uint64{},
+		conns:    map[*memoryConn]bool{},
+		config:   map[string]string{},
+		changed:  make(chan bool),
+	}
+}
+
+// newMemoryPool 创建连接到进程内存储的连接池，连接池的配置来自地址中的参数
+func newMemoryPool(urlInfo *url.URL) *redis.Redis {
+	name := urlInfo.Host
+	if name == "" {
+		name = "default"
+	}
+	db := strings.Trim(urlInfo.Path, "/")
+	if db == "" {
+		db = "0"
+	}
+
+	memoryStoresLock.Lock()
+	defer memoryStoresLock.Unlock()
+	store := memoryStores[name+"/"+db]
+	if store == nil {
+		store = newMemoryStore(db)
+		memoryStores[name+"/"+db] = store
+		go store.sweep()
+	}
+	// 使用不会被连接的地址区分不同的存储，避免共用 ssgo/redis 中缓存的连接池
+	pool := redis.GetRedis(makeNodeUrl(urlInfo, "memory-"+name+":0", db), nil)
+	pool.GetPool().Dial = store.dial
+	memoryPools[pool.GetPool()] = store
+	return pool
+}
+
+func getMemoryStore(pool *redis.Redis) *memoryStore {
+	memoryStoresLock.Lock()
+	defer memoryStoresLock.Unlock()
+	return memoryPools[pool.GetPool()]
+}
+
+func (s *memoryStore) dial() (redigo.Conn, error) {
+	return &memoryConn{store: s, notify: make(chan bool, 1)}, nil
+}
+
+// now 当前时钟的毫秒时间戳，需要在持有锁时调用
+func (s *memoryStore) now() int64 {
+	if s.fixedTime > 0 {
+		return s.fixedTime
+	}
+	return time.Now().UnixNano()/int64(time.Millisecond) + s.offset
+}
+
+// setClock 将时钟固定在指定的毫秒时间戳，0 表示恢复跟随系统时间
+func (s *memoryStore) setClock(unixMs int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if unixMs <= 0 {
+		s.fixedTime = 0
+		s.offset = 0
+	} else {
+		s.fixedTime = unixMs
+	}
+	s.expireKeys()
+}
+
+// advanceClock 将时钟向前拨动指定的毫秒数
+func (s *memoryStore) advanceClock(ms int64) int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.fixedTime > 0 {
+		s.fixedTime += ms
+	} else {
+		s.offset += ms
+	}
+	s.expireKeys()
+	return s.now()
+}
+
+// expireKeys 拨动时钟后删除所有到期的Key，触发过期事件并唤醒阻塞命令
+func (s *memoryStore) expireKeys() {
+	for key := range s.entries {
+		s.get(key)
+	}
+	s.notifyChanged()
+}
+
+// notifyChanged 唤醒等待数据变化的阻塞命令
+func (s *memoryStore) notifyChanged() {
+	close(s.changed)
+	s.changed = make(chan bool)
+}
+
+// get 获取未过期的Key，已经过期的Key会被删除
+func (s *memoryStore) get(key string) *memoryEntry {
+	entry := s.entries[key]
+	if entry != nil && entry.expireAt > 0 && entry.expireAt <= s.now() {
+		delete(s.entries, key)
+		s.touch(key)
+		s.notifyKeyEvent("expired", key)
+		return nil
+	}
+	return entry
+}
+
+// getValue 获取指定类型的值，类型不匹配时返回 WRONGTYPE 错误
+func (s *memoryStore) getValue(key, typ string) (*memoryEntry, error) {
+	entry := s.get(key)
+	if entry != nil && memoryType(entry.value) != typ {
+		return nil, errMemoryWrongType
+	}
+	return entry, nil
+}
+
+// put 保存值并清除过期时间
+func (s *memoryStore) put(key string, value interface{}) *memoryEntry {
+	entry := &memoryEntry{value: value}
+	s.entries[key] = entry
+	s.touch(key)
+	return entry
+}
+
+// update 修改集合类型的值后调用，集合为空时删除Key
+func (s *memoryStore) update(key string, entry *memoryEntry) {
+	if memoryLen(entry.value) == 0 {
+		delete(s.entries, key)
+	} else {
+		s.entries[key] = entry
+	}
+	s.touch(key)
+}
+
+func (s *memoryStore) del(key string) bool {
+	if s.get(key) == nil {
+		return false
+	}
+	delete(s.entries, key)
+	s.touch(key)
+	return true
+}
+
+// touch 记录Key的修改，用于 WATCH 检查和唤醒阻塞命令
+func (s *memoryStore) touch(key string) {
+	s.version++
+	s.versions[key] = s.version
+	s.notifyChanged()
+}
+
+// keys 按照顺序返回所有未过期的Key
+func (s *memoryStore) keys() []string {
+	keys := make([]string, 0, len(s.entries))
+	for key := range s.entries {
+		if s.get(key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// execute 执行一个命令，需要在持有锁时调用
+func (s *memoryStore) execute(cmd string, args [][]byte) interface{} {
+	command := memoryCommands[cmd]
+	if memoryUnsupportedCommands[cmd] {
+		return redigo.Error(fmt.Sprintf("ERR command '%s' is not supported by memory backend, use a redis server", strings.ToLower(cmd)))
+	}
+	if command.fn == nil {
+		return redigo.Error(fmt.Sprintf("ERR unknown command '%s' for memory backend", strings.ToLower(cmd)))
+	}
+	if len(args) < command.minArgs {
+		return memoryArgsError(cmd)
+	}
+	// 处理函数通过 s.cmd 区分共用同一个函数的命令，嵌套调用结束后恢复
+	prevCmd := s.cmd
+	s.cmd = cmd
+	reply, err := command.fn(s, args)
+	s.cmd = prevCmd
+	if err != nil {
+		return redigo.Error(err.Error())
+	}
+	return reply
+}
+
+// call 在脚本的模拟实现中执行命令
+func (s *memoryStore) call(cmd string, args ...string) interface{} {
+	argBytes := make([][]byte, len(args))
+	for i, arg := range args {
+		argBytes[i] = []byte(arg)
+	}
+	return s.execute(cmd, argBytes)
+}
+
+// sweep 开启了键事件通知时定期删除到期的Key，使过期事件不依赖于访问Key
+func (s *memoryStore) sweep() {
+	for range time.Tick(100 * time.Millisecond) {
+		s.lock.Lock()
+		if strings.Contains(s.config["notify-keyspace-events"], "E") {
+			for key := range s.entries {
+				s.get(key)
+			}
+		}
+		s.lock.Unlock()
+	}
+}
+
+// notifyKeyEvent 按照 notify-keyspace-events 的配置发送键事件通知
+func (s *memoryStore) notifyKeyEvent(event, key string) {
+	flags := s.config["notify-keyspace-events"]
+	flag := keyEventFlags[event]
+	if !strings.Contains(flags, "E") || flag == "" {
+		return
+	}
+	if strings.Contains(flags, flag) || (strings.Contains(flags, "A") && strings.Contains(keyEventAllFlags, flag)) {
+		s.publish("__keyevent@"+s.db+"__:"+event, []byte(key))
+	}
+}
+
+// publish 将消息发送给订阅了频道的连接，返回接收到消息的连接数
+func (s *memoryStore) publish(channel string, data []byte) int64 {
+	n := int64(0)
+	for conn := range s.conns {
+		if conn.deliver(channel, data) {
+			n++
+		}
+	}
+	return n
+}
+
+// memoryConn 实现 redigo.Conn，直接在进程内执行命令，不经过网络和协议编解码
+type memoryConn struct {
+	store    *memoryStore
+	replies  []interface{}
+	multi    [][][]byte
+	inMulti  bool
+	aborted  bool
+	watching map[string]uint64
+	channels map[string]bool
+	patterns map[string]bool
+	messages []interface{}
+	notify   chan bool
+	closed   bool
+	lock     sync.Mutex
+}
+
+func (c *memoryConn) Close() error {
+	c.store.lock.Lock()
+	delete(c.store.conns, c)
+	c.store.lock.Unlock()
+
+	c.lock.Lock()
+	c.closed = true
+	c.lock.Unlock()
+	select {
+	case c.notify <- true:
+	default:
+	}
+	return nil
+}
+
+func (c *memoryConn) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return errMemoryConnClosed
+	}
+	return nil
+}
+
+func (c *memoryConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.DoWithTimeout(0, cmd, args...)
+}
+
+// DoWithTimeout 执行命令并读取之前发送的所有命令的回复，返回最后一个回复和第一个错误
+func (c *memoryConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	if cmd != "" {
+		if err := c.send(timeout, cmd, args); err != nil {
+			return nil, err
+		}
+	}
+	c.lock.Lock()
+	replies := c.replies
+	c.replies = nil
+	c.lock.Unlock()
+
+	var reply interface{}
+	var err error
+	for _, r := range replies {
+		reply = r
+		if e, ok := r.(redigo.Error); ok && err == nil {
+			err = e
+		}
+	}
+	return reply, err
+}
+
+func (c *memoryConn) Send(cmd string, args ...interface{}) error {
+	return c.send(0, cmd, args)
+}
+
+func (c *memoryConn) Flush() error {
+	return c.Err()
+}
+
+func (c *memoryConn) Receive() (interface{}, error) {
+	return c.ReceiveWithTimeout(0)
+}
+
+// ReceiveWithTimeout 读取下一个回复，订阅状态下没有回复时等待消息，timeout 为 0 表示一直等待
+func (c *memoryConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		c.lock.Lock()
+		if c.closed {
+			c.lock.Unlock()
+			return nil, errMemoryConnClosed
+		}
+		if len(c.replies) > 0 {
+			reply := c.replies[0]
+			c.replies = c.replies[1:]
+			c.lock.Unlock()
+			if err, ok := reply.(redigo.Error); ok {
+				return nil, err
+			}
+			return reply, nil
+		}
+		if len(c.messages) > 0 {
+			message := c.messages[0]
+			c.messages = c.messages[1:]
+			c.lock.Unlock()
+			return message, nil
+		}
+		subscribed := len(c.channels)+len(c.patterns) > 0
+		c.lock.Unlock()
+		if !subscribed {
+			return nil, errors.New("memory: no reply to receive")
+		}
+		select {
+		case <-c.notify:
+		case <-deadline:
+			return nil, errors.New("memory: i/o timeout")
+		}
+	}
+}
+
+func (c *memoryConn) send(timeout time.Duration, cmd string, args []interface{}) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	argBytes := make([][]byte, len(args))
+	for i, arg := range args {
+		argBytes[i] = memoryArg(arg)
+	}
+	reply := c.execute(timeout, strings.ToUpper(cmd), argBytes)
+	c.lock.Lock()
+	c.replies = append(c.replies, reply)
+	c.lock.Unlock()
+	return nil
+}
+
+// execute 处理连接状态相关的命令（事务、订阅、阻塞命令、脚本），其他命令交给存储执行
+func (c *memoryConn) execute(timeout time.Duration, cmd string, args [][]byte) interface{} {
+	s := c.store
+	switch cmd {
+	case "MULTI":
+		if c.inMulti {
+			return redigo.Error("ERR MULTI calls can not be nested")
+		}
+		c.inMulti = true
+		c.aborted = false
+		c.multi = nil
+		return "OK"
+	case "EXEC":
+		if !c.inMulti {
+			return redigo.Error("ERR EXEC without MULTI")
+		}
+		return c.exec()
+	case "DISCARD":
+		if !c.inMulti {
+			return redigo.Error("ERR DISCARD without MULTI")
+		}
+		c.inMulti = false
+		c.multi = nil
+		c.watching = nil
+		return "OK"
+	case "WATCH":
+		if c.inMulti {
+			return redigo.Error("ERR WATCH inside MULTI is not allowed")
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if c.watching == nil {
+			c.watching = map[string]uint64{}
+		}
+		for _, key := range args {
+			s.get(string(key))
+			c.watching[string(key)] = s.versions[string(key)]
+		}
+		return "OK"
+	case "UNWATCH":
+		c.watching = nil
+		return "OK"
+	}
+
+	if c.inMulti {
+		if memoryCommands[cmd].fn == nil {
+			c.aborted = true
+			return redigo.Error(fmt.Sprintf("ERR unknown command '%s' for memory backend", strings.ToLower(cmd)))
+		}
+		c.multi = append(c.multi, append([][]byte{[]byte(cmd)}, args...))
+		return "QUEUED"
+	}
+
+	switch cmd {
+	case "SUBSCRIBE", "PSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE":
+		return c.subscribe(cmd, args)
+	}
+	if fn := memoryBlockingCommands[cmd]; fn != nil {
+		return c.block(timeout, cmd, args, fn)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.execute(cmd, args)
+}
+
+// exec 原子地执行事务中的命令，WATCH 的Key被修改过时放弃执行并返回nil
+func (c *memoryConn) exec() interface{} {
+	commands := c.multi
+	watching := c.watching
+	aborted := c.aborted
+	c.inMulti = false
+	c.multi = nil
+	c.watching = nil
+	if aborted {
+		return redigo.Error("EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	s := c.store
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, version := range watching {
+		s.get(key)
+		if s.versions[key] != version {
+			return nil
+		}
+	}
+	replies := make([]interface{}, len(commands))
+	for i, command := range commands {
+		replies[i] = s.execute(string(command[0]), command[1:])
+	}
+	return replies
+}
+
+// block 执行阻塞命令，数据变化或时钟拨动时重试，直到成功或超时
+func (c *memoryConn) block(timeout time.Duration, cmd string, args [][]byte, fn memoryBlockingFunc) interface{} {
+	if len(args) < 2 {
+		return memoryArgsError(cmd)
+	}
+	seconds, err := strconv.ParseFloat(string(args[len(args)-1]), 64)
+	if err != nil || seconds < 0 {
+		return redigo.Error("ERR timeout is not a float or out of range")
+	}
+	var deadline <-chan time.Time
+	if seconds > 0 {
+		timer := time.NewTimer(time.Duration(seconds * float64(time.Second)))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		s := c.store
+		s.lock.Lock()
+		s.cmd = cmd
+		reply, ok := fn(s, args[:len(args)-1])
+		changed := s.changed
+		s.lock.Unlock()
+		if ok {
+			return reply
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return nil
+		}
+		if c.Err() != nil {
+			return redigo.Error(errMemoryConnClosed.Error())
+		}
+	}
+}
+
+// subscribe 修改订阅的频道，每个频道回复一次 [类型, 频道, 订阅总数]
+func (c *memoryConn) subscribe(cmd string, args [][]byte) interface{} {
+	s := c.store
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.channels == nil {
+		c.channels = map[string]bool{}
+		c.patterns = map[string]bool{}
+	}
+	names := c.channels
+	if cmd == "PSUBSCRIBE" || cmd == "PUNSUBSCRIBE" {
+		names = c.patterns
+	}
+	kind := []byte(strings.ToLower(cmd))
+	if len(args) == 0 && (cmd == "UNSUBSCRIBE" || cmd == "PUNSUBSCRIBE") {
+		for name := range names {
+			args = append(args, []byte(name))
+		}
+		sort.Slice(args, func(i, j int) bool { return string(args[i]) < string(args[j]) })
+	}
+	replies := make([]interface{}, 0, len(args))
+	for _, name := range args {
+		if cmd == "SUBSCRIBE" || cmd == "PSUBSCRIBE" {
+			names[string(name)] = true
+		} else {
+			delete(names, string(name))
+		}
+		replies = append(replies, []interface{}{kind, name, int64(len(c.channels) + len(c.patterns))})
+	}
+	if len(c.channels)+len(c.patterns) > 0 {
+		s.conns[c] = true
+	} else {
+		delete(s.conns, c)
+	}
+	if len(replies) == 0 {
+		return []interface{}{kind, nil, int64(len(c.channels) + len(c.patterns))}
+	}
+	// 多个频道的回复除了最后一个以外都作为消息在之后读取
+	c.messages = append(c.messages, replies[1:]...)
+	return replies[0]
+}
+
+// deliver 将发布的消息放入连接的消息队列，需要在持有存储的锁时调用
+func (c *memoryConn) deliver(channel string, data []byte) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delivered := false
+	if c.channels[channel] {
+		c.messages = append(c.messages, []interface{}{[]byte("message"), []byte(channel), data})
+		delivered = true
+	}
+	for pattern := range c.patterns {
+		if matchGlob(pattern, channel) {
+			c.messages = append(c.messages, []interface{}{[]byte("pmessage"), []byte(pattern), []byte(channel), data})
+			delivered = true
+		}
+	}
+	if delivered {
+		select {
+		case c.notify <- true:
+		default:
+		}
+	}
+	return delivered
+}
+
+// memoryArg 按照 redigo 的规则将参数转换为字节
+func memoryArg(arg interface{}) []byte {
+	switch v := arg.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case int:
+		return []byte(strconv.Itoa(v))
+	case int64:
+		return []byte(strconv.FormatInt(v, 10))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		if v {
+			return []byte("1")
+		}
+		return []byte("0")
+	case nil:
+		return []byte{}
+	case redigo.Argument:
+		return memoryArg(v.RedisArg())
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+// matchGlob 按照Redis的规则匹配 glob 模式，支持 *、?、[abc]、[^a-z] 和 \ 转义
+func matchGlob(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchGlob(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				return pattern == s
+			}
+			class := pattern[1 : end+1]
+			pattern = pattern[end+2:]
+			not := strings.HasPrefix(class, "^")
+			if not {
+				class = class[1:]
+			}
+			matched := false
+			for i := 0; i < len(class); i++ {
+				if class[i] == '\\' && i+1 < len(class) {
+					i++
+					matched = matched || class[i] == s[0]
+				} else if i+2 < len(class) && class[i+1] == '-' {
+					lo, hi := class[i], class[i+2]
+					if lo > hi {
+						lo, hi = hi, lo
+					}
+					matched = matched || (s[0] >= lo && s[0] <= hi)
+					i += 2
+				} else {
+					matched = matched || class[i] == s[0]
+				}
+			}
+			if matched == not {
+				return false
+			}
+			s = s[1:]
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// SetClock 将 memory:// 连接的时钟固定在指定的毫秒时间戳，用于测试过期逻辑，0 表示恢复跟随系统时间
+// SetClock return 是否是 memory:// 连接
+func (rd *Redis) SetClock(unixMs int64) bool {
+	store := getMemoryStore(rd.pool)
+	if store == nil {
+		return false
+	}
+	store.setClock(unixMs)
+	return true
+}
+
+// AdvanceClock 将 memory:// 连接的时钟向前拨动，拨动后到期的Key会立即过期
+// AdvanceClock ms 毫秒数
+// AdvanceClock return 拨动后的毫秒时间戳，不是 memory:// 连接时返回0
+func (rd *Redis) AdvanceClock(ms int64) int64 {
+	store := getMemoryStore(rd.pool)
+	if store == nil {
+		return 0
+	}
+	return store.advanceClock(ms)
+}
@@ -0,0 +1,2609 @@
+package redis
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+var errMemoryWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+var errMemoryNotInteger = errors.New("ERR value is not an integer or out of range")
+var errMemoryNotFloat = errors.New("ERR value is not a valid float")
+var errMemorySyntax = errors.New("ERR syntax error")
+var errMemoryNoKey = errors.New("ERR no such key")
+
+// memoryUnsupportedCommands memory:// 没有实现的命令，执行时返回明确的错误，需要时请使用Redis服务器
+var memoryUnsupportedCommands = map[string]bool{
+	"BITFIELD": true, "BITFIELD_RO": true,
+	"GEOADD": true, "GEOPOS": true, "GEODIST": true, "GEOHASH": true, "GEOSEARCH": true, "GEOSEARCHSTORE": true,
+	"XADD": true, "XLEN": true, "XRANGE": true, "XREVRANGE": true, "XREAD": true, "XREADGROUP": true, "XDEL": true, "XTRIM": true,
+	"XGROUP": true, "XACK": true, "XPENDING": true, "XCLAIM": true, "XAUTOCLAIM": true, "XINFO": true,
+}
+
+type memoryFunc func(s *memoryStore, args [][]byte) (interface{}, error)
+
+// memoryBlockingFunc 阻塞命令的一次尝试，没有数据时返回false，args 不包含最后的超时参数
+type memoryBlockingFunc func(s *memoryStore, args [][]byte) (interface{}, bool)
+
+type memoryCommand struct {
+	fn      memoryFunc
+	minArgs int
+}
+
+type memoryZMember struct {
+	member string
+	score  float64
+}
+
+var memoryCommands map[string]memoryCommand
+var memoryBlockingCommands map[string]memoryBlockingFunc
+
+func init() {
+	memoryCommands = map[string]memoryCommand{
+		// 连接和服务器
+		"PING":     {memoryPing, 0},
+		"ECHO":     {memoryEcho, 1},
+		"SELECT":   {memoryOK, 1},
+		"QUIT":     {memoryOK, 0},
+		"TIME":     {memoryTime, 0},
+		"DBSIZE":   {memoryDBSize, 0},
+		"FLUSHDB":  {memoryFlush, 0},
+		"FLUSHALL": {memoryFlush, 0},
+		"INFO":     {memoryInfo, 0},
+		"CONFIG":   {memoryConfig, 1},
+		"SLOWLOG":  {memorySlowlog, 1},
+		"MEMORY":   {memoryMemory, 1},
+		"PUBLISH":  {memoryPublish, 2},
+		"EVAL":     {memoryEval, 2},
+		"EVALSHA":  {memoryEval, 2},
+		"SCRIPT":   {memoryScriptCommand, 1},
+
+		// Key
+		"DEL":       {memoryDel, 1},
+		"UNLINK":    {memoryDel, 1},
+		"EXISTS":    {memoryExists, 1},
+		"TOUCH":     {memoryExists, 1},
+		"EXPIRE":    {memoryExpire, 2},
+		"PEXPIRE":   {memoryExpire, 2},
+		"EXPIREAT":  {memoryExpire, 2},
+		"PEXPIREAT": {memoryExpire, 2},
+		"TTL":       {memoryTTL, 1},
+		"PTTL":      {memoryTTL, 1},
+		"PERSIST":   {memoryPersist, 1},
+		"TYPE":      {memoryTypeCommand, 1},
+		"RENAME":    {memoryRename, 2},
+		"RENAMENX":  {memoryRename, 2},
+		"COPY":      {memoryCopyCommand, 2},
+		"KEYS":      {memoryKeys, 1},
+		"SCAN":      {memoryScan, 1},
+		"RANDOMKEY": {memoryRandomKey, 0},
+
+		// 字符串
+		"GET":         {memoryGet, 1},
+		"SET":         {memorySet, 2},
+		"SETEX":       {memorySetEX, 3},
+		"PSETEX":      {memorySetEX, 3},
+		"SETNX":       {memorySetNX, 2},
+		"GETSET":      {memoryGetSet, 2},
+		"GETDEL":      {memoryGetDel, 1},
+		"GETEX":       {memoryGetEX, 1},
+		"MGET":        {memoryMGet, 1},
+		"MSET":        {memoryMSet, 2},
+		"MSETNX":      {memoryMSet, 2},
+		"INCR":        {memoryIncr, 1},
+		"DECR":        {memoryIncr, 1},
+		"INCRBY":      {memoryIncr, 2},
+		"DECRBY":      {memoryIncr, 2},
+		"INCRBYFLOAT": {memoryIncrByFloat, 2},
+		"APPEND":      {memoryAppend, 2},
+		"STRLEN":      {memoryStrLen, 1},
+		"SETBIT":      {memorySetBit, 3},
+		"GETBIT":      {memoryGetBit, 2},
+		"BITCOUNT":    {memoryBitCount, 1},
+		"BITPOS":      {memoryBitPos, 2},
+		"BITOP":       {memoryBitOp, 3},
+		"PFADD":       {memoryPFAdd, 1},
+		"PFCOUNT":     {memoryPFCount, 1},
+		"PFMERGE":     {memoryPFMerge, 1},
+
+		// 哈希表
+		"HGET":         {memoryHGet, 2},
+		"HSET":         {memoryHSet, 3},
+		"HMSET":        {memoryHSet, 3},
+		"HSETNX":       {memoryHSetNX, 3},
+		"HMGET":        {memoryHMGet, 2},
+		"HGETALL":      {memoryHGetAll, 1},
+		"HKEYS":        {memoryHGetAll, 1},
+		"HVALS":        {memoryHGetAll, 1},
+		"HLEN":         {memoryHLen, 1},
+		"HDEL":         {memoryHDel, 2},
+		"HEXISTS":      {memoryHExists, 2},
+		"HSTRLEN":      {memoryHStrLen, 2},
+		"HINCRBY":      {memoryHIncrBy, 3},
+		"HINCRBYFLOAT": {memoryHIncrByFloat, 3},
+		"HSCAN":        {memoryHScan, 2},
+
+		// 列表
+		"LPUSH":     {memoryPush, 2},
+		"RPUSH":     {memoryPush, 2},
+		"LPUSHX":    {memoryPush, 2},
+		"RPUSHX":    {memoryPush, 2},
+		"LPOP":      {memoryPop, 1},
+		"RPOP":      {memoryPop, 1},
+		"LLEN":      {memoryLLen, 1},
+		"LRANGE":    {memoryLRange, 3},
+		"LINDEX":    {memoryLIndex, 2},
+		"LSET":      {memoryLSet, 3},
+		"LREM":      {memoryLRem, 3},
+		"LTRIM":     {memoryLTrim, 3},
+		"LINSERT":   {memoryLInsert, 4},
+		"LPOS":      {memoryLPos, 2},
+		"LMOVE":     {memoryLMove, 4},
+		"RPOPLPUSH": {memoryLMove, 2},
+
+		// 集合
+		"SADD":        {memorySAdd, 2},
+		"SREM":        {memorySRem, 2},
+		"SMEMBERS":    {memorySMembers, 1},
+		"SISMEMBER":   {memorySIsMember, 2},
+		"SMISMEMBER":  {memorySIsMember, 2},
+		"SCARD":       {memorySCard, 1},
+		"SPOP":        {memorySPop, 1},
+		"SRANDMEMBER": {memorySPop, 1},
+		"SMOVE":       {memorySMove, 3},
+		"SINTER":      {memorySCombine, 1},
+		"SUNION":      {memorySCombine, 1},
+		"SDIFF":       {memorySCombine, 1},
+		"SINTERSTORE": {memorySCombine, 2},
+		"SUNIONSTORE": {memorySCombine, 2},
+		"SDIFFSTORE":  {memorySCombine, 2},
+		"SINTERCARD":  {memorySInterCard, 2},
+		"SSCAN":       {memorySScan, 2},
+
+		// 有序集合
+		"ZADD":             {memoryZAdd, 3},
+		"ZINCRBY":          {memoryZIncrBy, 3},
+		"ZSCORE":           {memoryZScore, 2},
+		"ZMSCORE":          {memoryZScore, 2},
+		"ZRANK":            {memoryZRank, 2},
+		"ZREVRANK":         {memoryZRank, 2},
+		"ZRANGE":           {memoryZRange, 3},
+		"ZREVRANGE":        {memoryZRangeAlias, 3},
+		"ZRANGEBYSCORE":    {memoryZRangeAlias, 3},
+		"ZREVRANGEBYSCORE": {memoryZRangeAlias, 3},
+		"ZREM":             {memoryZRem, 2},
+		"ZREMRANGEBYSCORE": {memoryZRemRange, 3},
+		"ZREMRANGEBYRANK":  {memoryZRemRange, 3},
+		"ZCARD":            {memoryZCard, 1},
+		"ZCOUNT":           {memoryZCount, 3},
+		"ZPOPMIN":          {memoryZPop, 1},
+		"ZPOPMAX":          {memoryZPop, 1},
+		"ZUNION":           {memoryZCombine, 2},
+		"ZINTER":           {memoryZCombine, 2},
+		"ZDIFF":            {memoryZCombine, 2},
+		"ZUNIONSTORE":      {memoryZCombine, 3},
+		"ZINTERSTORE":      {memoryZCombine, 3},
+		"ZDIFFSTORE":       {memoryZCombine, 3},
+		"ZSCAN":            {memoryZScan, 2},
+	}
+
+	memoryBlockingCommands = map[string]memoryBlockingFunc{
+		"BLPOP":      memoryBPop,
+		"BRPOP":      memoryBPop,
+		"BLMOVE":     memoryBLMove,
+		"BRPOPLPUSH": memoryBLMove,
+	}
+}
+
+func memoryArgsError(cmd string) redigo.Error {
+	return redigo.Error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+}
+
+func memoryType(value interface{}) string {
+	switch value.(type) {
+	case []byte:
+		return "string"
+	case map[string][]byte:
+		return "hash"
+	case [][]byte:
+		return "list"
+	case map[string]bool:
+		return "set"
+	case map[string]float64:
+		return "zset"
+	}
+	return "none"
+}
+
+func memoryLen(value interface{}) int {
+	switch v := value.(type) {
+	case []byte:
+		return len(v)
+	case map[string][]byte:
+		return len(v)
+	case [][]byte:
+		return len(v)
+	case map[string]bool:
+		return len(v)
+	case map[string]float64:
+		return len(v)
+	}
+	return 0
+}
+
+func memoryCopy(b []byte) []byte {
+	return append([]byte{}, b...)
+}
+
+// memoryClone 深拷贝一个值，用于 COPY
+func memoryClone(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return memoryCopy(v)
+	case map[string][]byte:
+		out := make(map[string][]byte, len(v))
+		for field, fieldValue := range v {
+			out[field] = fieldValue
+		}
+		return out
+	case [][]byte:
+		return append([][]byte{}, v...)
+	case map[string]bool:
+		out := make(map[string]bool, len(v))
+		for member := range v {
+			out[member] = true
+		}
+		return out
+	case map[string]float64:
+		out := make(map[string]float64, len(v))
+		for member, score := range v {
+			out[member] = score
+		}
+		return out
+	}
+	return value
+}
+
+func memoryInt(b []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, errMemoryNotInteger
+	}
+	return n, nil
+}
+
+func memoryFloat(b []byte) (float64, error) {
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errMemoryNotFloat
+	}
+	return f, nil
+}
+
+// memoryFormatFloat 按照Redis的格式输出浮点数，整数不带小数点
+func memoryFormatFloat(f float64) []byte {
+	switch {
+	case math.IsInf(f, 1):
+		return []byte("inf")
+	case math.IsInf(f, -1):
+		return []byte("-inf")
+	case f == math.Trunc(f) && math.Abs(f) < 1e17:
+		return []byte(strconv.FormatInt(int64(f), 10))
+	}
+	return []byte(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+func memoryUpper(b []byte) string {
+	return strings.ToUpper(string(b))
+}
+
+// memoryRange 将 start 和 stop（可以是负数）转换为 [lo, hi) 的下标范围
+func memoryRange(start, stop int64, n int) (int, int) {
+	if start < 0 {
+		start += int64(n)
+	}
+	if stop < 0 {
+		stop += int64(n)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= int64(n) {
+		stop = int64(n) - 1
+	}
+	if start > stop || start >= int64(n) {
+		return 0, 0
+	}
+	return int(start), int(stop) + 1
+}
+
+func memoryBulks(values []string) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = []byte(v)
+	}
+	return out
+}
+
+func memoryOK(_ *memoryStore, _ [][]byte) (interface{}, error) {
+	return "OK", nil
+}
+
+func memoryPing(_ *memoryStore, args [][]byte) (interface{}, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+	return "PONG", nil
+}
+
+func memoryEcho(_ *memoryStore, args [][]byte) (interface{}, error) {
+	return args[0], nil
+}
+
+func memoryTime(s *memoryStore, _ [][]byte) (interface{}, error) {
+	now := s.now()
+	return []interface{}{[]byte(strconv.FormatInt(now/1000, 10)), []byte(strconv.FormatInt(now%1000*1000, 10))}, nil
+}
+
+func memoryDBSize(s *memoryStore, _ [][]byte) (interface{}, error) {
+	return int64(len(s.keys())), nil
+}
+
+func memoryFlush(s *memoryStore, _ [][]byte) (interface{}, error) {
+	for key := range s.entries {
+		delete(s.entries, key)
+		s.touch(key)
+	}
+	return "OK", nil
+}
+
+func memoryInfo(s *memoryStore, args [][]byte) (interface{}, error) {
+	keys := s.keys()
+	expires := 0
+	usedMemory := 0
+	for _, key := range keys {
+		if s.entries[key].expireAt > 0 {
+			expires++
+		}
+		usedMemory += memoryUsage(key, s.entries[key])
+	}
+	sections := [][]string{
+		{"Server", "redis_version:7.0.0", "redis_mode:memory", "arch_bits:64"},
+		{"Memory", "used_memory:" + strconv.Itoa(usedMemory), "maxmemory:0", "maxmemory_policy:noeviction"},
+		{"Keyspace", fmt.Sprintf("db0:keys=%d,expires=%d,avg_ttl=0", len(keys), expires)},
+	}
+	section := ""
+	if len(args) > 0 {
+		section = strings.ToLower(string(args[0]))
+	}
+	lines := make([]string, 0)
+	for _, sec := range sections {
+		if section != "" && section != "all" && section != "everything" && section != "default" && section != strings.ToLower(sec[0]) {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "# "+sec[0])
+		lines = append(lines, sec[1:]...)
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n"), nil
+}
+
+func memoryConfig(s *memoryStore, args [][]byte) (interface{}, error) {
+	switch memoryUpper(args[0]) {
+	case "GET":
+		if len(args) < 2 {
+			return nil, memoryArgsError("config|get")
+		}
+		config := map[string]string{"notify-keyspace-events": "", "maxmemory": "0", "maxmemory-policy": "noeviction"}
+		for name, value := range s.config {
+			config[name] = value
+		}
+		names := make([]string, 0)
+		for name := range config {
+			if matchGlob(strings.ToLower(string(args[1])), name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		out := make([]interface{}, 0, len(names)*2)
+		for _, name := range names {
+			out = append(out, []byte(name), []byte(config[name]))
+		}
+		return out, nil
+	case "SET":
+		if len(args) < 3 || len(args)%2 == 0 {
+			return nil, memoryArgsError("config|set")
+		}
+		for i := 1; i+1 < len(args); i += 2 {
+			s.config[strings.ToLower(string(args[i]))] = string(args[i+1])
+		}
+		return "OK", nil
+	case "RESETSTAT", "REWRITE":
+		return "OK", nil
+	}
+	return nil, errMemorySyntax
+}
+
+func memorySlowlog(_ *memoryStore, args [][]byte) (interface{}, error) {
+	switch memoryUpper(args[0]) {
+	case "GET":
+		return []interface{}{}, nil
+	case "LEN":
+		return int64(0), nil
+	case "RESET":
+		return "OK", nil
+	}
+	return nil, errMemorySyntax
+}
+
+func memoryMemory(s *memoryStore, args [][]byte) (interface{}, error) {
+	if memoryUpper(args[0]) != "USAGE" || len(args) < 2 {
+		return nil, errMemorySyntax
+	}
+	entry := s.get(string(args[1]))
+	if entry == nil {
+		return nil, nil
+	}
+	return int64(memoryUsage(string(args[1]), entry)), nil
+}
+
+// memoryUsage 估算Key占用的内存，只计算Key和值的字节数
+func memoryUsage(key string, entry *memoryEntry) int {
+	size := len(key)
+	switch v := entry.value.(type) {
+	case []byte:
+		size += len(v)
+	case map[string][]byte:
+		for field, value := range v {
+			size += len(field) + len(value)
+		}
+	case [][]byte:
+		for _, item := range v {
+			size += len(item)
+		}
+	case map[string]bool:
+		for member := range v {
+			size += len(member)
+		}
+	case map[string]float64:
+		for member := range v {
+			size += len(member) + 8
+		}
+	}
+	return size
+}
+
+func memoryPublish(s *memoryStore, args [][]byte) (interface{}, error) {
+	return s.publish(string(args[0]), memoryCopy(args[1])), nil
+}
+
+// memoryEval 执行 EVAL/EVALSHA，只支持 memoryScripts 中模拟的插件内置脚本
+func memoryEval(s *memoryStore, args [][]byte) (interface{}, error) {
+	sha := strings.ToLower(string(args[0]))
+	if len(sha) != 40 || strings.Contains(string(args[0]), " ") {
+		sum := sha1.Sum(args[0])
+		sha = hex.EncodeToString(sum[:])
+	}
+	script := memoryScripts[sha]
+	if script == nil {
+		return nil, errors.New("ERR memory backend only runs the built-in scripts of this plugin, use a redis server for other lua scripts")
+	}
+	numKeys, err := memoryInt(args[1])
+	if err != nil || numKeys < 0 || int(numKeys) > len(args)-2 {
+		return nil, errors.New("ERR Number of keys can't be greater than number of args")
+	}
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = string(args[2+i])
+	}
+	argv := make([]string, 0, len(args))
+	for _, arg := range args[2+numKeys:] {
+		argv = append(argv, string(arg))
+	}
+	reply := script(s, keys, argv)
+	if err, ok := reply.(redigo.Error); ok {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func memoryScriptCommand(_ *memoryStore, args [][]byte) (interface{}, error) {
+	switch memoryUpper(args[0]) {
+	case "LOAD":
+		if len(args) < 2 {
+			return nil, memoryArgsError("script|load")
+		}
+		sum := sha1.Sum(args[1])
+		return []byte(hex.EncodeToString(sum[:])), nil
+	case "EXISTS":
+		out := make([]interface{}, 0, len(args)-1)
+		for _, sha := range args[1:] {
+			if memoryScripts[strings.ToLower(string(sha))] != nil {
+				out = append(out, int64(1))
+			} else {
+				out = append(out, int64(0))
+			}
+		}
+		return out, nil
+	case "FLUSH", "KILL":
+		return "OK", nil
+	}
+	return nil, errMemorySyntax
+}
+
+func memoryDel(s *memoryStore, args [][]byte) (interface{}, error) {
+	n := int64(0)
+	for _, key := range args {
+		if s.del(string(key)) {
+			s.notifyKeyEvent("del", string(key))
+			n++
+		}
+	}
+	return n, nil
+}
+
+func memoryExists(s *memoryStore, args [][]byte) (interface{}, error) {
+	n := int64(0)
+	for _, key := range args {
+		if s.get(string(key)) != nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// memoryExpireAt 根据 EX/PX/EXAT/PXAT 计算过期的毫秒时间戳
+func memoryExpireAt(s *memoryStore, unit string, b []byte) (int64, error) {
+	n, err := memoryInt(b)
+	if err != nil {
+		return 0, err
+	}
+	switch unit {
+	case "EX", "EXPIRE":
+		return s.now() + n*1000, nil
+	case "PX", "PEXPIRE":
+		return s.now() + n, nil
+	case "EXAT", "EXPIREAT":
+		return n * 1000, nil
+	}
+	return n, nil
+}
+
+func memoryExpire(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry := s.get(string(args[0]))
+	if entry == nil {
+		return int64(0), nil
+	}
+	expireAt, err := memoryExpireAt(s, s.cmd, args[1])
+	if err != nil {
+		return nil, err
+	}
+	for _, flag := range args[2:] {
+		switch memoryUpper(flag) {
+		case "NX":
+			if entry.expireAt > 0 {
+				return int64(0), nil
+			}
+		case "XX":
+			if entry.expireAt == 0 {
+				return int64(0), nil
+			}
+		case "GT":
+			if entry.expireAt == 0 || expireAt <= entry.expireAt {
+				return int64(0), nil
+			}
+		case "LT":
+			if entry.expireAt > 0 && expireAt >= entry.expireAt {
+				return int64(0), nil
+			}
+		default:
+			return nil, errMemorySyntax
+		}
+	}
+	if expireAt <= s.now() {
+		s.del(string(args[0]))
+		return int64(1), nil
+	}
+	entry.expireAt = expireAt
+	s.touch(string(args[0]))
+	return int64(1), nil
+}
+
+func memoryTTL(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry := s.get(string(args[0]))
+	if entry == nil {
+		return int64(-2), nil
+	}
+	if entry.expireAt == 0 {
+		return int64(-1), nil
+	}
+	ttl := entry.expireAt - s.now()
+	if s.cmd == "TTL" {
+		return (ttl + 500) / 1000, nil
+	}
+	return ttl, nil
+}
+
+func memoryPersist(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry := s.get(string(args[0]))
+	if entry == nil || entry.expireAt == 0 {
+		return int64(0), nil
+	}
+	entry.expireAt = 0
+	s.touch(string(args[0]))
+	return int64(1), nil
+}
+
+func memoryTypeCommand(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry := s.get(string(args[0]))
+	if entry == nil {
+		return "none", nil
+	}
+	return memoryType(entry.value), nil
+}
+
+func memoryRename(s *memoryStore, args [][]byte) (interface{}, error) {
+	key, newKey := string(args[0]), string(args[1])
+	entry := s.get(key)
+	if entry == nil {
+		return nil, errMemoryNoKey
+	}
+	if s.cmd == "RENAMENX" {
+		if s.get(newKey) != nil {
+			return int64(0), nil
+		}
+	}
+	delete(s.entries, key)
+	s.touch(key)
+	s.entries[newKey] = entry
+	s.touch(newKey)
+	if s.cmd == "RENAMENX" {
+		return int64(1), nil
+	}
+	return "OK", nil
+}
+
+func memoryCopyCommand(s *memoryStore, args [][]byte) (interface{}, error) {
+	replace := false
+	for i := 2; i < len(args); i++ {
+		switch memoryUpper(args[i]) {
+		case "REPLACE":
+			replace = true
+		case "DB":
+			return nil, errors.New("ERR DB option is not supported by memory backend")
+		default:
+			return nil, errMemorySyntax
+		}
+	}
+	entry := s.get(string(args[0]))
+	if entry == nil || (!replace && s.get(string(args[1])) != nil) {
+		return int64(0), nil
+	}
+	s.put(string(args[1]), memoryClone(entry.value)).expireAt = entry.expireAt
+	return int64(1), nil
+}
+
+func memoryKeys(s *memoryStore, args [][]byte) (interface{}, error) {
+	out := make([]string, 0)
+	for _, key := range s.keys() {
+		if matchGlob(string(args[0]), key) {
+			out = append(out, key)
+		}
+	}
+	return memoryBulks(out), nil
+}
+
+func memoryRandomKey(s *memoryStore, _ [][]byte) (interface{}, error) {
+	keys := s.keys()
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return []byte(keys[rand.Intn(len(keys))]), nil
+}
+
+// memoryScanItems 按照游标分页返回 items，cursor 为 items 中的下标
+// typeOf 用于 SCAN 的 TYPE 过滤，其他命令为nil，expand 将一个匹配的项展开为回复中的一个或多个元素
+func memoryScanItems(items []string, args [][]byte, typeOf func(string) string, expand func(string) []interface{}) (interface{}, error) {
+	cursor, err := memoryInt(args[0])
+	if err != nil || cursor < 0 {
+		return nil, errors.New("ERR invalid cursor")
+	}
+	match, typ := "", ""
+	count := int64(10)
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, errMemorySyntax
+		}
+		switch memoryUpper(args[i]) {
+		case "MATCH":
+			match = string(args[i+1])
+		case "COUNT":
+			if count, err = memoryInt(args[i+1]); err != nil || count < 1 {
+				return nil, errMemorySyntax
+			}
+		case "TYPE":
+			if typeOf == nil {
+				return nil, errMemorySyntax
+			}
+			typ = strings.ToLower(string(args[i+1]))
+		default:
+			return nil, errMemorySyntax
+		}
+	}
+	out := make([]interface{}, 0)
+	end := cursor + count
+	if end >= int64(len(items)) {
+		end = int64(len(items))
+	}
+	for i := cursor; i < end; i++ {
+		item := items[i]
+		if (match == "" || matchGlob(match, item)) && (typ == "" || typeOf(item) == typ) {
+			out = append(out, expand(item)...)
+		}
+	}
+	next := end
+	if end >= int64(len(items)) {
+		next = 0
+	}
+	return []interface{}{[]byte(strconv.FormatInt(next, 10)), out}, nil
+}
+
+func memoryScan(s *memoryStore, args [][]byte) (interface{}, error) {
+	typeOf := func(key string) string {
+		if entry := s.entries[key]; entry != nil {
+			return memoryType(entry.value)
+		}
+		return "none"
+	}
+	return memoryScanItems(s.keys(), args, typeOf, func(key string) []interface{} { return []interface{}{[]byte(key)} })
+}
+
+func memoryGetString(s *memoryStore, key string) ([]byte, error) {
+	entry, err := s.getValue(key, "string")
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return entry.value.([]byte), nil
+}
+
+func memoryGet(s *memoryStore, args [][]byte) (interface{}, error) {
+	value, err := memoryGetString(s, string(args[0]))
+	if value == nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// memorySet SET key value [NX|XX] [GET] [EX|PX|EXAT|PXAT time|KEEPTTL]
+func memorySet(s *memoryStore, args [][]byte) (interface{}, error) {
+	key := string(args[0])
+	var nx, xx, get, keepTtl bool
+	expireAt := int64(0)
+	for i := 2; i < len(args); i++ {
+		switch flag := memoryUpper(args[i]); flag {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keepTtl = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if i+1 >= len(args) {
+				return nil, errMemorySyntax
+			}
+			at, err := memoryExpireAt(s, flag, args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			expireAt = at
+			i++
+		default:
+			return nil, errMemorySyntax
+		}
+	}
+
+	old := s.get(key)
+	var oldValue interface{}
+	if get && old != nil {
+		if memoryType(old.value) != "string" {
+			return nil, errMemoryWrongType
+		}
+		oldValue = old.value
+	}
+	if (nx && old != nil) || (xx && old == nil) {
+		return oldValue, nil
+	}
+	if expireAt > 0 && expireAt <= s.now() {
+		s.del(key)
+	} else {
+		entry := s.put(key, memoryCopy(args[1]))
+		if keepTtl && old != nil {
+			entry.expireAt = old.expireAt
+		} else {
+			entry.expireAt = expireAt
+		}
+	}
+	if get {
+		return oldValue, nil
+	}
+	return "OK", nil
+}
+
+func memorySetEX(s *memoryStore, args [][]byte) (interface{}, error) {
+	unit := "EX"
+	if s.cmd == "PSETEX" {
+		unit = "PX"
+	}
+	if n, err := memoryInt(args[1]); err != nil || n <= 0 {
+		return nil, fmt.Errorf("ERR invalid expire time in '%s' command", strings.ToLower(s.cmd))
+	}
+	return memorySet(s, [][]byte{args[0], args[2], []byte(unit), args[1]})
+}
+
+func memorySetNX(s *memoryStore, args [][]byte) (interface{}, error) {
+	reply, err := memorySet(s, [][]byte{args[0], args[1], []byte("NX")})
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return int64(0), nil
+	}
+	return int64(1), nil
+}
+
+func memoryGetSet(s *memoryStore, args [][]byte) (interface{}, error) {
+	return memorySet(s, [][]byte{args[0], args[1], []byte("GET")})
+}
+
+func memoryGetDel(s *memoryStore, args [][]byte) (interface{}, error) {
+	value, err := memoryGetString(s, string(args[0]))
+	if value == nil {
+		return nil, err
+	}
+	s.del(string(args[0]))
+	return value, nil
+}
+
+func memoryGetEX(s *memoryStore, args [][]byte) (interface{}, error) {
+	key := string(args[0])
+	value, err := memoryGetString(s, key)
+	if value == nil {
+		return nil, err
+	}
+	entry := s.entries[key]
+	for i := 1; i < len(args); i++ {
+		switch flag := memoryUpper(args[i]); flag {
+		case "PERSIST":
+			entry.expireAt = 0
+			s.touch(key)
+		case "EX", "PX", "EXAT", "PXAT":
+			if i+1 >= len(args) {
+				return nil, errMemorySyntax
+			}
+			expireAt, err := memoryExpireAt(s, flag, args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if expireAt <= s.now() {
+				s.del(key)
+			} else {
+				entry.expireAt = expireAt
+				s.touch(key)
+			}
+			i++
+		default:
+			return nil, errMemorySyntax
+		}
+	}
+	return value, nil
+}
+
+func memoryMGet(s *memoryStore, args [][]byte) (interface{}, error) {
+	out := make([]interface{}, len(args))
+	for i, key := range args {
+		if value, _ := memoryGetString(s, string(key)); value != nil {
+			out[i] = value
+		}
+	}
+	return out, nil
+}
+
+func memoryMSet(s *memoryStore, args [][]byte) (interface{}, error) {
+	if len(args)%2 != 0 {
+		return nil, memoryArgsError(s.cmd)
+	}
+	nx := s.cmd == "MSETNX"
+	if nx {
+		for i := 0; i < len(args); i += 2 {
+			if s.get(string(args[i])) != nil {
+				return int64(0), nil
+			}
+		}
+	}
+	for i := 0; i < len(args); i += 2 {
+		s.put(string(args[i]), memoryCopy(args[i+1]))
+	}
+	if nx {
+		return int64(1), nil
+	}
+	return "OK", nil
+}
+
+func memoryIncr(s *memoryStore, args [][]byte) (interface{}, error) {
+	delta := int64(1)
+	if len(args) > 1 {
+		n, err := memoryInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		delta = n
+	}
+	if cmd := s.cmd; cmd == "DECR" || cmd == "DECRBY" {
+		delta = -delta
+	}
+	return memoryIncrBy(s, string(args[0]), delta)
+}
+
+// memoryIncrBy 修改数值时保留原来的过期时间
+func memoryIncrBy(s *memoryStore, key string, delta int64) (interface{}, error) {
+	value, err := memoryGetString(s, key)
+	if err != nil {
+		return nil, err
+	}
+	n := int64(0)
+	if value != nil {
+		if n, err = memoryInt(value); err != nil {
+			return nil, err
+		}
+	}
+	result := n + delta
+	if (delta > 0 && result < n) || (delta < 0 && result > n) {
+		return nil, errors.New("ERR increment or decrement would overflow")
+	}
+	memorySetKeepTTL(s, key, []byte(strconv.FormatInt(result, 10)))
+	return result, nil
+}
+
+func memorySetKeepTTL(s *memoryStore, key string, value []byte) {
+	if entry := s.get(key); entry != nil {
+		entry.value = value
+		s.touch(key)
+	} else {
+		s.put(key, value)
+	}
+}
+
+func memoryIncrByFloat(s *memoryStore, args [][]byte) (interface{}, error) {
+	key := string(args[0])
+	delta, err := memoryFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	value, err := memoryGetString(s, key)
+	if err != nil {
+		return nil, err
+	}
+	f := 0.0
+	if value != nil {
+		if f, err = memoryFloat(value); err != nil {
+			return nil, err
+		}
+	}
+	result := memoryFormatFloat(f + delta)
+	memorySetKeepTTL(s, key, result)
+	return result, nil
+}
+
+func memoryAppend(s *memoryStore, args [][]byte) (interface{}, error) {
+	value, err := memoryGetString(s, string(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	result := append(memoryCopy(value), args[1]...)
+	memorySetKeepTTL(s, string(args[0]), result)
+	return int64(len(result)), nil
+}
+
+func memoryStrLen(s *memoryStore, args [][]byte) (interface{}, error) {
+	value, err := memoryGetString(s, string(args[0]))
+	return int64(len(value)), err
+}
+
+func memorySetBit(s *memoryStore, args [][]byte) (interface{}, error) {
+	offset, err := memoryInt(args[1])
+	if err != nil || offset < 0 {
+		return nil, errors.New("ERR bit offset is not an integer or out of range")
+	}
+	bit := string(args[2])
+	if bit != "0" && bit != "1" {
+		return nil, errors.New("ERR bit is not an integer or out of range")
+	}
+	value, err := memoryGetString(s, string(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	value = memoryCopy(value)
+	for int64(len(value)) <= offset/8 {
+		value = append(value, 0)
+	}
+	mask := byte(0x80) >> uint(offset%8)
+	old := int64(0)
+	if value[offset/8]&mask != 0 {
+		old = 1
+	}
+	if bit == "1" {
+		value[offset/8] |= mask
+	} else {
+		value[offset/8] &^= mask
+	}
+	memorySetKeepTTL(s, string(args[0]), value)
+	return old, nil
+}
+
+func memoryGetBit(s *memoryStore, args [][]byte) (interface{}, error) {
+	offset, err := memoryInt(args[1])
+	if err != nil || offset < 0 {
+		return nil, errors.New("ERR bit offset is not an integer or out of range")
+	}
+	value, err := memoryGetString(s, string(args[0]))
+	if err != nil || int64(len(value)) <= offset/8 {
+		return int64(0), err
+	}
+	if value[offset/8]&(byte(0x80)>>uint(offset%8)) != 0 {
+		return int64(1), nil
+	}
+	return int64(0), nil
+}
+
+// memoryBitCount 只支持按字节指定范围
+func memoryBitCount(s *memoryStore, args [][]byte) (interface{}, error) {
+	value, err := memoryGetString(s, string(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	lo, hi := 0, len(value)
+	if len(args) >= 3 {
+		if len(args) > 3 && memoryUpper(args[3]) != "BYTE" {
+			return nil, errors.New("ERR BIT unit is not supported by memory backend")
+		}
+		start, err1 := memoryInt(args[1])
+		stop, err2 := memoryInt(args[2])
+		if err1 != nil || err2 != nil {
+			return nil, errMemoryNotInteger
+		}
+		lo, hi = memoryRange(start, stop, len(value))
+	} else if len(args) == 2 {
+		return nil, errMemorySyntax
+	}
+	n := 0
+	for _, b := range value[lo:hi] {
+		n += bits.OnesCount8(b)
+	}
+	return int64(n), nil
+}
+
+// memoryBitPos 只支持按字节指定范围
+func memoryBitPos(s *memoryStore, args [][]byte) (interface{}, error) {
+	bit := string(args[1])
+	if bit != "0" && bit != "1" {
+		return nil, errors.New("ERR The bit argument must be 1 or 0.")
+	}
+	value, err := memoryGetString(s, string(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		if bit == "0" {
+			return int64(0), nil
+		}
+		return int64(-1), nil
+	}
+	lo, hi := 0, len(value)
+	if len(args) > 4 && memoryUpper(args[4]) != "BYTE" {
+		return nil, errors.New("ERR BIT unit is not supported by memory backend")
+	}
+	if len(args) > 2 {
+		start, err := memoryInt(args[2])
+		stop := int64(-1)
+		if err == nil && len(args) > 3 {
+			stop, err = memoryInt(args[3])
+		}
+		if err != nil {
+			return nil, errMemoryNotInteger
+		}
+		lo, hi = memoryRange(start, stop, len(value))
+	}
+	for i := lo; i < hi; i++ {
+		for j := 0; j < 8; j++ {
+			if (value[i]&(byte(0x80)>>uint(j)) != 0) == (bit == "1") {
+				return int64(i*8 + j), nil
+			}
+		}
+	}
+	// 查找0且没有指定结束位置时，字符串右侧视为填充了0
+	if bit == "0" && len(args) <= 3 && hi > lo {
+		return int64(hi * 8), nil
+	}
+	return int64(-1), nil
+}
+
+// memoryBitOp BITOP AND|OR|XOR|NOT destkey key [key ...]
+func memoryBitOp(s *memoryStore, args [][]byte) (interface{}, error) {
+	op := memoryUpper(args[0])
+	if op != "AND" && op != "OR" && op != "XOR" && op != "NOT" {
+		return nil, errMemorySyntax
+	}
+	if op == "NOT" && len(args) != 3 {
+		return nil, errors.New("ERR BITOP NOT must be called with a single source key.")
+	}
+	values := make([][]byte, 0, len(args)-2)
+	size := 0
+	for _, key := range args[2:] {
+		value, err := memoryGetString(s, string(key))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if len(value) > size {
+			size = len(value)
+		}
+	}
+	dest := string(args[1])
+	if size == 0 {
+		s.del(dest)
+		return int64(0), nil
+	}
+	result := make([]byte, size)
+	for i := range result {
+		for n, value := range values {
+			b := byte(0)
+			if i < len(value) {
+				b = value[i]
+			}
+			switch {
+			case op == "NOT":
+				result[i] = ^b
+			case n == 0:
+				result[i] = b
+			case op == "AND":
+				result[i] &= b
+			case op == "OR":
+				result[i] |= b
+			case op == "XOR":
+				result[i] ^= b
+			}
+		}
+	}
+	s.put(dest, result)
+	return int64(size), nil
+}
+
+// memoryHLLHeader HyperLogLog 在 memory:// 中保存为带有这个前缀的字符串，记录所有元素，计数是精确的
+var memoryHLLHeader = []byte("HYLL:memory\n")
+
+func memoryHLL(s *memoryStore, key string) (map[string]bool, bool, error) {
+	value, err := memoryGetString(s, key)
+	if err != nil || value == nil {
+		return map[string]bool{}, false, err
+	}
+	if !bytes.HasPrefix(value, memoryHLLHeader) {
+		return nil, false, errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	}
+	set := map[string]bool{}
+	buf := value[len(memoryHLLHeader):]
+	for len(buf) > 0 {
+		n, size := binary.Uvarint(buf)
+		if size <= 0 || uint64(len(buf)-size) < n {
+			return nil, false, errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+		}
+		set[string(buf[size:size+int(n)])] = true
+		buf = buf[size+int(n):]
+	}
+	return set, true, nil
+}
+
+func memoryPutHLL(s *memoryStore, key string, set map[string]bool) {
+	value := append([]byte{}, memoryHLLHeader...)
+	size := make([]byte, binary.MaxVarintLen64)
+	for _, member := range memorySortedMembers(set) {
+		value = append(value, size[:binary.PutUvarint(size, uint64(len(member)))]...)
+		value = append(value, member...)
+	}
+	memorySetKeepTTL(s, key, value)
+}
+
+func memoryPFAdd(s *memoryStore, args [][]byte) (interface{}, error) {
+	key := string(args[0])
+	set, exists, err := memoryHLL(s, key)
+	if err != nil {
+		return nil, err
+	}
+	changed := !exists
+	for _, element := range args[1:] {
+		if !set[string(element)] {
+			set[string(element)] = true
+			changed = true
+		}
+	}
+	if !changed {
+		return int64(0), nil
+	}
+	memoryPutHLL(s, key, set)
+	return int64(1), nil
+}
+
+func memoryPFCount(s *memoryStore, args [][]byte) (interface{}, error) {
+	union := map[string]bool{}
+	for _, key := range args {
+		set, _, err := memoryHLL(s, string(key))
+		if err != nil {
+			return nil, err
+		}
+		for member := range set {
+			union[member] = true
+		}
+	}
+	return int64(len(union)), nil
+}
+
+func memoryPFMerge(s *memoryStore, args [][]byte) (interface{}, error) {
+	union := map[string]bool{}
+	for _, key := range args {
+		set, _, err := memoryHLL(s, string(key))
+		if err != nil {
+			return nil, err
+		}
+		for member := range set {
+			union[member] = true
+		}
+	}
+	memoryPutHLL(s, string(args[0]), union)
+	return "OK", nil
+}
+
+// memoryHash 获取哈希表，create 为 true 时不存在则创建一个空的哈希表
+func memoryHash(s *memoryStore, key string, create bool) (*memoryEntry, map[string][]byte, error) {
+	entry, err := s.getValue(key, "hash")
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry == nil {
+		if !create {
+			return nil, nil, nil
+		}
+		entry = &memoryEntry{value: map[string][]byte{}}
+	}
+	return entry, entry.value.(map[string][]byte), nil
+}
+
+func memoryHGet(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, h, err := memoryHash(s, string(args[0]), false)
+	if err != nil || h[string(args[1])] == nil {
+		return nil, err
+	}
+	return h[string(args[1])], nil
+}
+
+func memoryHSet(s *memoryStore, args [][]byte) (interface{}, error) {
+	if len(args)%2 != 1 {
+		return nil, memoryArgsError(s.cmd)
+	}
+	entry, h, err := memoryHash(s, string(args[0]), true)
+	if err != nil {
+		return nil, err
+	}
+	n := int64(0)
+	for i := 1; i+1 < len(args); i += 2 {
+		if h[string(args[i])] == nil {
+			n++
+		}
+		h[string(args[i])] = memoryCopy(args[i+1])
+	}
+	s.update(string(args[0]), entry)
+	if s.cmd == "HMSET" {
+		return "OK", nil
+	}
+	return n, nil
+}
+
+func memoryHSetNX(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, h, err := memoryHash(s, string(args[0]), true)
+	if err != nil {
+		return nil, err
+	}
+	if h[string(args[1])] != nil {
+		return int64(0), nil
+	}
+	h[string(args[1])] = memoryCopy(args[2])
+	s.update(string(args[0]), entry)
+	return int64(1), nil
+}
+
+func memoryHMGet(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, h, err := memoryHash(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(args)-1)
+	for i, field := range args[1:] {
+		if v := h[string(field)]; v != nil {
+			out[i] = v
+		}
+	}
+	return out, nil
+}
+
+// memoryHGetAll 处理 HGETALL、HKEYS 和 HVALS，按照字段名称排序
+func memoryHGetAll(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, h, err := memoryHash(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(h))
+	for field := range h {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	cmd := s.cmd
+	out := make([]interface{}, 0, len(fields)*2)
+	for _, field := range fields {
+		if cmd != "HVALS" {
+			out = append(out, []byte(field))
+		}
+		if cmd != "HKEYS" {
+			out = append(out, h[field])
+		}
+	}
+	return out, nil
+}
+
+func memoryHLen(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, h, err := memoryHash(s, string(args[0]), false)
+	return int64(len(h)), err
+}
+
+func memoryHDel(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, h, err := memoryHash(s, string(args[0]), false)
+	if err != nil || entry == nil {
+		return int64(0), err
+	}
+	n := int64(0)
+	for _, field := range args[1:] {
+		if h[string(field)] != nil {
+			delete(h, string(field))
+			n++
+		}
+	}
+	if n > 0 {
+		s.update(string(args[0]), entry)
+	}
+	return n, nil
+}
+
+func memoryHExists(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, h, err := memoryHash(s, string(args[0]), false)
+	if err != nil || h[string(args[1])] == nil {
+		return int64(0), err
+	}
+	return int64(1), nil
+}
+
+func memoryHStrLen(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, h, err := memoryHash(s, string(args[0]), false)
+	return int64(len(h[string(args[1])])), err
+}
+
+func memoryHIncrBy(s *memoryStore, args [][]byte) (interface{}, error) {
+	delta, err := memoryInt(args[2])
+	if err != nil {
+		return nil, err
+	}
+	entry, h, err := memoryHash(s, string(args[0]), true)
+	if err != nil {
+		return nil, err
+	}
+	n := int64(0)
+	if v := h[string(args[1])]; v != nil {
+		if n, err = memoryInt(v); err != nil {
+			return nil, errors.New("ERR hash value is not an integer")
+		}
+	}
+	result := n + delta
+	if (delta > 0 && result < n) || (delta < 0 && result > n) {
+		return nil, errors.New("ERR increment or decrement would overflow")
+	}
+	h[string(args[1])] = []byte(strconv.FormatInt(result, 10))
+	s.update(string(args[0]), entry)
+	return result, nil
+}
+
+func memoryHIncrByFloat(s *memoryStore, args [][]byte) (interface{}, error) {
+	delta, err := memoryFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	entry, h, err := memoryHash(s, string(args[0]), true)
+	if err != nil {
+		return nil, err
+	}
+	f := 0.0
+	if v := h[string(args[1])]; v != nil {
+		if f, err = memoryFloat(v); err != nil {
+			return nil, errors.New("ERR hash value is not a float")
+		}
+	}
+	result := memoryFormatFloat(f + delta)
+	h[string(args[1])] = result
+	s.update(string(args[0]), entry)
+	return result, nil
+}
+
+func memoryHScan(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, h, err := memoryHash(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(h))
+	for field := range h {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return memoryScanItems(fields, args[1:], nil, func(field string) []interface{} {
+		return []interface{}{[]byte(field), h[field]}
+	})
+}
+
+// memoryList 获取列表，不存在时返回空的列表
+func memoryList(s *memoryStore, key string) (*memoryEntry, [][]byte, error) {
+	entry, err := s.getValue(key, "list")
+	if err != nil || entry == nil {
+		return nil, nil, err
+	}
+	return entry, entry.value.([][]byte), nil
+}
+
+func memorySetList(s *memoryStore, key string, entry *memoryEntry, list [][]byte) {
+	if entry == nil {
+		entry = &memoryEntry{}
+	}
+	entry.value = list
+	s.update(key, entry)
+}
+
+func memoryPush(s *memoryStore, args [][]byte) (interface{}, error) {
+	key := string(args[0])
+	entry, list, err := memoryList(s, key)
+	if err != nil {
+		return nil, err
+	}
+	cmd := s.cmd
+	if entry == nil && (cmd == "LPUSHX" || cmd == "RPUSHX") {
+		return int64(0), nil
+	}
+	for _, value := range args[1:] {
+		if cmd == "LPUSH" || cmd == "LPUSHX" {
+			list = append([][]byte{memoryCopy(value)}, list...)
+		} else {
+			list = append(list, memoryCopy(value))
+		}
+	}
+	memorySetList(s, key, entry, list)
+	return int64(len(list)), nil
+}
+
+// memoryPopN 从列表的一端移出最多 count 个元素
+func memoryPopN(s *memoryStore, key string, left bool, count int) ([][]byte, error) {
+	entry, list, err := memoryList(s, key)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	if count > len(list) {
+		count = len(list)
+	}
+	out := make([][]byte, count)
+	for i := 0; i < count; i++ {
+		if left {
+			out[i] = list[i]
+		} else {
+			out[i] = list[len(list)-1-i]
+		}
+	}
+	if left {
+		list = list[count:]
+	} else {
+		list = list[:len(list)-count]
+	}
+	memorySetList(s, key, entry, list)
+	return out, nil
+}
+
+func memoryPop(s *memoryStore, args [][]byte) (interface{}, error) {
+	left := s.cmd == "LPOP"
+	if len(args) > 1 {
+		count, err := memoryInt(args[1])
+		if err != nil || count < 0 {
+			return nil, errors.New("ERR value is out of range, must be positive")
+		}
+		values, err := memoryPopN(s, string(args[0]), left, int(count))
+		if values == nil {
+			return nil, err
+		}
+		out := make([]interface{}, len(values))
+		for i, v := range values {
+			out[i] = v
+		}
+		return out, nil
+	}
+	values, err := memoryPopN(s, string(args[0]), left, 1)
+	if len(values) == 0 {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+func memoryLLen(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, list, err := memoryList(s, string(args[0]))
+	return int64(len(list)), err
+}
+
+func memoryLRange(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, list, err := memoryList(s, string(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	start, err1 := memoryInt(args[1])
+	stop, err2 := memoryInt(args[2])
+	if err1 != nil || err2 != nil {
+		return nil, errMemoryNotInteger
+	}
+	lo, hi := memoryRange(start, stop, len(list))
+	out := make([]interface{}, 0, hi-lo)
+	for _, v := range list[lo:hi] {
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func memoryListIndex(list [][]byte, b []byte) (int, error) {
+	index, err := memoryInt(b)
+	if err != nil {
+		return 0, err
+	}
+	if index < 0 {
+		index += int64(len(list))
+	}
+	if index < 0 || index >= int64(len(list)) {
+		return -1, nil
+	}
+	return int(index), nil
+}
+
+func memoryLIndex(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, list, err := memoryList(s, string(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	index, err := memoryListIndex(list, args[1])
+	if err != nil || index < 0 {
+		return nil, err
+	}
+	return list[index], nil
+}
+
+func memoryLSet(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, list, err := memoryList(s, string(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, errMemoryNoKey
+	}
+	index, err := memoryListIndex(list, args[1])
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 {
+		return nil, errors.New("ERR index out of range")
+	}
+	list[index] = memoryCopy(args[2])
+	memorySetList(s, string(args[0]), entry, list)
+	return "OK", nil
+}
+
+func memoryLRem(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, list, err := memoryList(s, string(args[0]))
+	if err != nil || entry == nil {
+		return int64(0), err
+	}
+	count, err := memoryInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	value := string(args[2])
+	removed := int64(0)
+	out := make([][]byte, 0, len(list))
+	if count < 0 {
+		// 从尾部开始移除，先倒序处理再恢复顺序
+		for i := len(list) - 1; i >= 0; i-- {
+			if string(list[i]) == value && removed < -count {
+				removed++
+				continue
+			}
+			out = append([][]byte{list[i]}, out...)
+		}
+	} else {
+		for _, v := range list {
+			if string(v) == value && (count == 0 || removed < count) {
+				removed++
+				continue
+			}
+			out = append(out, v)
+		}
+	}
+	if removed > 0 {
+		memorySetList(s, string(args[0]), entry, out)
+	}
+	return removed, nil
+}
+
+func memoryLTrim(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, list, err := memoryList(s, string(args[0]))
+	if err != nil || entry == nil {
+		if err != nil {
+			return nil, err
+		}
+		return "OK", nil
+	}
+	start, err1 := memoryInt(args[1])
+	stop, err2 := memoryInt(args[2])
+	if err1 != nil || err2 != nil {
+		return nil, errMemoryNotInteger
+	}
+	lo, hi := memoryRange(start, stop, len(list))
+	memorySetList(s, string(args[0]), entry, append([][]byte{}, list[lo:hi]...))
+	return "OK", nil
+}
+
+func memoryLInsert(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, list, err := memoryList(s, string(args[0]))
+	if err != nil || entry == nil {
+		return int64(0), err
+	}
+	where := memoryUpper(args[1])
+	if where != "BEFORE" && where != "AFTER" {
+		return nil, errMemorySyntax
+	}
+	for i, v := range list {
+		if string(v) == string(args[2]) {
+			if where == "AFTER" {
+				i++
+			}
+			list = append(list[:i], append([][]byte{memoryCopy(args[3])}, list[i:]...)...)
+			memorySetList(s, string(args[0]), entry, list)
+			return int64(len(list)), nil
+		}
+	}
+	return int64(-1), nil
+}
+
+// memoryLPos LPOS key element [RANK rank] [COUNT num] [MAXLEN len]
+func memoryLPos(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, list, err := memoryList(s, string(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+	for i := 2; i+1 < len(args); i += 2 {
+		n, err := memoryInt(args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		switch memoryUpper(args[i]) {
+		case "RANK":
+			if n == 0 {
+				return nil, errors.New("ERR RANK can't be zero")
+			}
+			rank = n
+		case "COUNT":
+			count = n
+		case "MAXLEN":
+			maxLen = n
+		default:
+			return nil, errMemorySyntax
+		}
+	}
+	found := make([]interface{}, 0)
+	skip := rank
+	if skip < 0 {
+		skip = -skip
+	}
+	for checked := 0; checked < len(list) && (maxLen == 0 || int64(checked) < maxLen); checked++ {
+		i := checked
+		if rank < 0 {
+			i = len(list) - 1 - checked
+		}
+		if string(list[i]) != string(args[1]) {
+			continue
+		}
+		if skip > 1 {
+			skip--
+			continue
+		}
+		found = append(found, int64(i))
+		if count < 0 || (count > 0 && int64(len(found)) >= count) {
+			break
+		}
+	}
+	if count < 0 {
+		if len(found) == 0 {
+			return nil, nil
+		}
+		return found[0], nil
+	}
+	return found, nil
+}
+
+// memoryLMove 处理 LMOVE source destination LEFT|RIGHT LEFT|RIGHT 和 RPOPLPUSH source destination
+func memoryLMove(s *memoryStore, args [][]byte) (interface{}, error) {
+	from, to := "RIGHT", "LEFT"
+	if len(args) >= 4 {
+		from, to = memoryUpper(args[2]), memoryUpper(args[3])
+	}
+	if (from != "LEFT" && from != "RIGHT") || (to != "LEFT" && to != "RIGHT") {
+		return nil, errMemorySyntax
+	}
+	if _, _, err := memoryList(s, string(args[1])); err != nil {
+		return nil, err
+	}
+	values, err := memoryPopN(s, string(args[0]), from == "LEFT", 1)
+	if len(values) == 0 {
+		return nil, err
+	}
+	pushCmd := "RPUSH"
+	if to == "LEFT" {
+		pushCmd = "LPUSH"
+	}
+	if reply := s.call(pushCmd, string(args[1]), string(values[0])); reply != nil {
+		if err, ok := reply.(redigo.Error); ok {
+			return nil, err
+		}
+	}
+	return values[0], nil
+}
+
+func memoryBPop(s *memoryStore, args [][]byte) (interface{}, bool) {
+	left := s.cmd == "BLPOP"
+	for _, key := range args {
+		values, err := memoryPopN(s, string(key), left, 1)
+		if err != nil {
+			return redigo.Error(err.Error()), true
+		}
+		if len(values) > 0 {
+			return []interface{}{key, values[0]}, true
+		}
+	}
+	return nil, false
+}
+
+func memoryBLMove(s *memoryStore, args [][]byte) (interface{}, bool) {
+	if s.cmd == "BRPOPLPUSH" {
+		args = args[:2]
+	}
+	reply, err := memoryLMove(s, args)
+	if err != nil {
+		return redigo.Error(err.Error()), true
+	}
+	return reply, reply != nil
+}
+
+func memorySetOf(s *memoryStore, key string, create bool) (*memoryEntry, map[string]bool, error) {
+	entry, err := s.getValue(key, "set")
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry == nil {
+		if !create {
+			return nil, nil, nil
+		}
+		entry = &memoryEntry{value: map[string]bool{}}
+	}
+	return entry, entry.value.(map[string]bool), nil
+}
+
+func memorySortedMembers(set map[string]bool) []string {
+	members := make([]string, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return members
+}
+
+func memorySAdd(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, set, err := memorySetOf(s, string(args[0]), true)
+	if err != nil {
+		return nil, err
+	}
+	n := int64(0)
+	for _, member := range args[1:] {
+		if !set[string(member)] {
+			set[string(member)] = true
+			n++
+		}
+	}
+	s.update(string(args[0]), entry)
+	return n, nil
+}
+
+func memorySRem(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, set, err := memorySetOf(s, string(args[0]), false)
+	if err != nil || entry == nil {
+		return int64(0), err
+	}
+	n := int64(0)
+	for _, member := range args[1:] {
+		if set[string(member)] {
+			delete(set, string(member))
+			n++
+		}
+	}
+	if n > 0 {
+		s.update(string(args[0]), entry)
+	}
+	return n, nil
+}
+
+func memorySMembers(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, set, err := memorySetOf(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	return memoryBulks(memorySortedMembers(set)), nil
+}
+
+func memorySIsMember(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, set, err := memorySetOf(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(args)-1)
+	for i, member := range args[1:] {
+		out[i] = int64(0)
+		if set[string(member)] {
+			out[i] = int64(1)
+		}
+	}
+	if s.cmd == "SISMEMBER" {
+		return out[0], nil
+	}
+	return out, nil
+}
+
+func memorySCard(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, set, err := memorySetOf(s, string(args[0]), false)
+	return int64(len(set)), err
+}
+
+// memorySPop 处理 SPOP 和 SRANDMEMBER，SRANDMEMBER 的 count 为负数时允许重复
+func memorySPop(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, set, err := memorySetOf(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	pop := s.cmd == "SPOP"
+	members := memorySortedMembers(set)
+	rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	if len(args) == 1 {
+		if len(members) == 0 {
+			return nil, nil
+		}
+		if pop {
+			delete(set, members[0])
+			s.update(string(args[0]), entry)
+		}
+		return []byte(members[0]), nil
+	}
+	count, err := memoryInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0)
+	if count < 0 && !pop {
+		for i := int64(0); i < -count && len(members) > 0; i++ {
+			out = append(out, members[rand.Intn(len(members))])
+		}
+		return memoryBulks(out), nil
+	}
+	if count < 0 {
+		return nil, errors.New("ERR value is out of range, must be positive")
+	}
+	if count < int64(len(members)) {
+		members = members[:count]
+	}
+	if pop && len(members) > 0 {
+		for _, member := range members {
+			delete(set, member)
+		}
+		s.update(string(args[0]), entry)
+	}
+	return memoryBulks(members), nil
+}
+
+func memorySMove(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, set, err := memorySetOf(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	destEntry, destSet, err := memorySetOf(s, string(args[1]), true)
+	if err != nil {
+		return nil, err
+	}
+	member := string(args[2])
+	if !set[member] {
+		return int64(0), nil
+	}
+	delete(set, member)
+	s.update(string(args[0]), entry)
+	destSet[member] = true
+	s.update(string(args[1]), destEntry)
+	return int64(1), nil
+}
+
+// memorySCombineKeys 计算多个集合的交集、并集或差集
+func memorySCombineKeys(s *memoryStore, op string, keys [][]byte) (map[string]bool, error) {
+	var result map[string]bool
+	for i, key := range keys {
+		_, set, err := memorySetOf(s, string(key), false)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			result = map[string]bool{}
+			for member := range set {
+				result[member] = true
+			}
+			continue
+		}
+		for member := range result {
+			if (op == "INTER" && !set[member]) || (op == "DIFF" && set[member]) {
+				delete(result, member)
+			}
+		}
+		if op == "UNION" {
+			for member := range set {
+				result[member] = true
+			}
+		}
+	}
+	return result, nil
+}
+
+func memorySCombine(s *memoryStore, args [][]byte) (interface{}, error) {
+	cmd := s.cmd
+	op := strings.TrimSuffix(strings.TrimPrefix(cmd, "S"), "STORE")
+	if strings.HasSuffix(cmd, "STORE") {
+		result, err := memorySCombineKeys(s, op, args[1:])
+		if err != nil {
+			return nil, err
+		}
+		s.del(string(args[0]))
+		if len(result) > 0 {
+			s.put(string(args[0]), result)
+		}
+		return int64(len(result)), nil
+	}
+	result, err := memorySCombineKeys(s, op, args)
+	if err != nil {
+		return nil, err
+	}
+	return memoryBulks(memorySortedMembers(result)), nil
+}
+
+// memoryNumKeys 解析 numkeys key [key ...] 格式的参数，返回Key和剩余的参数
+func memoryNumKeys(args [][]byte) ([][]byte, [][]byte, error) {
+	numKeys, err := memoryInt(args[0])
+	if err != nil || numKeys <= 0 {
+		return nil, nil, errors.New("ERR numkeys should be greater than 0")
+	}
+	if int(numKeys) > len(args)-1 {
+		return nil, nil, errMemorySyntax
+	}
+	return args[1 : 1+numKeys], args[1+numKeys:], nil
+}
+
+func memorySInterCard(s *memoryStore, args [][]byte) (interface{}, error) {
+	keys, rest, err := memoryNumKeys(args)
+	if err != nil {
+		return nil, err
+	}
+	limit := int64(0)
+	if len(rest) == 2 && memoryUpper(rest[0]) == "LIMIT" {
+		if limit, err = memoryInt(rest[1]); err != nil {
+			return nil, err
+		}
+	} else if len(rest) > 0 {
+		return nil, errMemorySyntax
+	}
+	result, err := memorySCombineKeys(s, "INTER", keys)
+	if err != nil {
+		return nil, err
+	}
+	n := int64(len(result))
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	return n, nil
+}
+
+func memorySScan(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, set, err := memorySetOf(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	return memoryScanItems(memorySortedMembers(set), args[1:], nil, func(member string) []interface{} {
+		return []interface{}{[]byte(member)}
+	})
+}
+
+func memoryZSet(s *memoryStore, key string, create bool) (*memoryEntry, map[string]float64, error) {
+	entry, err := s.getValue(key, "zset")
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry == nil {
+		if !create {
+			return nil, nil, nil
+		}
+		entry = &memoryEntry{value: map[string]float64{}}
+	}
+	return entry, entry.value.(map[string]float64), nil
+}
+
+// memoryZSorted 按照分数从小到大排序，分数相同时按照成员排序
+func memoryZSorted(z map[string]float64) []memoryZMember {
+	members := make([]memoryZMember, 0, len(z))
+	for member, score := range z {
+		members = append(members, memoryZMember{member: member, score: score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].score != members[j].score {
+			return members[i].score < members[j].score
+		}
+		return members[i].member < members[j].member
+	})
+	return members
+}
+
+func memoryZReply(members []memoryZMember, withScores bool) []interface{} {
+	out := make([]interface{}, 0, len(members)*2)
+	for _, m := range members {
+		out = append(out, []byte(m.member))
+		if withScores {
+			out = append(out, memoryFormatFloat(m.score))
+		}
+	}
+	return out
+}
+
+// memoryZAdd ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+func memoryZAdd(s *memoryStore, args [][]byte) (interface{}, error) {
+	var nx, xx, gt, lt, ch, incr bool
+	i := 1
+flags:
+	for ; i < len(args); i++ {
+		switch memoryUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break flags
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 || (incr && len(pairs) != 2) {
+		return nil, errMemorySyntax
+	}
+	if nx && (xx || gt || lt) {
+		return nil, errors.New("ERR XX and NX options at the same time are not compatible")
+	}
+	scores := make([]float64, len(pairs)/2)
+	for j := range scores {
+		score, err := memoryFloat(pairs[j*2])
+		if err != nil {
+			return nil, err
+		}
+		scores[j] = score
+	}
+	entry, z, err := memoryZSet(s, string(args[0]), true)
+	if err != nil {
+		return nil, err
+	}
+	added, changed := int64(0), int64(0)
+	var result interface{}
+	for j, score := range scores {
+		member := string(pairs[j*2+1])
+		old, exists := z[member]
+		if (nx && exists) || (xx && !exists) {
+			continue
+		}
+		if incr && exists {
+			score += old
+		}
+		if exists && ((gt && score <= old) || (lt && score >= old)) {
+			continue
+		}
+		z[member] = score
+		result = memoryFormatFloat(score)
+		if !exists {
+			added++
+		} else if score != old {
+			changed++
+		}
+	}
+	s.update(string(args[0]), entry)
+	if incr {
+		return result, nil
+	}
+	if ch {
+		return added + changed, nil
+	}
+	return added, nil
+}
+
+func memoryZIncrBy(s *memoryStore, args [][]byte) (interface{}, error) {
+	return memoryZAdd(s, [][]byte{args[0], []byte("INCR"), args[1], args[2]})
+}
+
+func memoryZScore(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, z, err := memoryZSet(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(args)-1)
+	for i, member := range args[1:] {
+		if score, ok := z[string(member)]; ok {
+			out[i] = memoryFormatFloat(score)
+		}
+	}
+	if s.cmd == "ZSCORE" {
+		return out[0], nil
+	}
+	return out, nil
+}
+
+func memoryZRank(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, z, err := memoryZSet(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := z[string(args[1])]; !ok {
+		return nil, nil
+	}
+	members := memoryZSorted(z)
+	for i, m := range members {
+		if m.member == string(args[1]) {
+			if s.cmd == "ZREVRANK" {
+				return int64(len(members) - 1 - i), nil
+			}
+			return int64(i), nil
+		}
+	}
+	return nil, nil
+}
+
+// memoryScoreBound 解析分数区间的边界，例如 "-inf"、"(1.5"、"10"
+func memoryScoreBound(b []byte) (float64, bool, error) {
+	exclusive := len(b) > 0 && b[0] == '('
+	if exclusive {
+		b = b[1:]
+	}
+	f, err := memoryFloat(b)
+	if err != nil {
+		return 0, false, errors.New("ERR min or max is not a float")
+	}
+	return f, exclusive, nil
+}
+
+// memoryLexBound 解析成员区间的边界，"-" 和 "+" 表示无穷小和无穷大
+func memoryLexBound(b []byte) (string, int, error) {
+	switch {
+	case string(b) == "-":
+		return "", -1, nil
+	case string(b) == "+":
+		return "", 1, nil
+	case len(b) > 0 && (b[0] == '[' || b[0] == '('):
+		if b[0] == '(' {
+			return string(b[1:]), 2, nil
+		}
+		return string(b[1:]), 0, nil
+	}
+	return "", 0, errors.New("ERR min or max not valid string range item")
+}
+
+func memoryInScore(score, min float64, minEx bool, max float64, maxEx bool) bool {
+	return (score > min || (!minEx && score == min)) && (score < max || (!maxEx && score == max))
+}
+
+// memoryInLex kind：-1 无穷小，1 无穷大，0 包含，2 不包含
+func memoryInLex(member, min string, minKind int, max string, maxKind int) bool {
+	switch minKind {
+	case 1:
+		return false
+	case 0:
+		if member < min {
+			return false
+		}
+	case 2:
+		if member <= min {
+			return false
+		}
+	}
+	switch maxKind {
+	case -1:
+		return false
+	case 0:
+		return member <= max
+	case 2:
+		return member < max
+	}
+	return true
+}
+
+// memoryZSelect 根据 ZRANGE 的参数选择成员，返回已经按照要求排好序的成员
+func memoryZSelect(z map[string]float64, start, stop []byte, by string, rev bool) ([]memoryZMember, error) {
+	members := memoryZSorted(z)
+	if rev {
+		for i, j := 0, len(members)-1; i < j; i, j = i+1, j-1 {
+			members[i], members[j] = members[j], members[i]
+		}
+	}
+	switch by {
+	case "BYSCORE":
+		if rev {
+			start, stop = stop, start
+		}
+		min, minEx, err := memoryScoreBound(start)
+		if err != nil {
+			return nil, err
+		}
+		max, maxEx, err := memoryScoreBound(stop)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]memoryZMember, 0)
+		for _, m := range members {
+			if memoryInScore(m.score, min, minEx, max, maxEx) {
+				out = append(out, m)
+			}
+		}
+		return out, nil
+	case "BYLEX":
+		if rev {
+			start, stop = stop, start
+		}
+		min, minKind, err := memoryLexBound(start)
+		if err != nil {
+			return nil, err
+		}
+		max, maxKind, err := memoryLexBound(stop)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]memoryZMember, 0)
+		for _, m := range members {
+			if memoryInLex(m.member, min, minKind, max, maxKind) {
+				out = append(out, m)
+			}
+		}
+		return out, nil
+	}
+	lo, err1 := memoryInt(start)
+	hi, err2 := memoryInt(stop)
+	if err1 != nil || err2 != nil {
+		return nil, errMemoryNotInteger
+	}
+	from, to := memoryRange(lo, hi, len(members))
+	return members[from:to], nil
+}
+
+// memoryZRange ZRANGE key start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func memoryZRange(s *memoryStore, args [][]byte) (interface{}, error) {
+	by := ""
+	rev, withScores, limit := false, false, false
+	offset, count := int64(0), int64(-1)
+	for i := 3; i < len(args); i++ {
+		switch flag := memoryUpper(args[i]); flag {
+		case "BYSCORE", "BYLEX":
+			by = flag
+		case "REV":
+			rev = true
+		case "WITHSCORES":
+			withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return nil, errMemorySyntax
+			}
+			var err1, err2 error
+			offset, err1 = memoryInt(args[i+1])
+			count, err2 = memoryInt(args[i+2])
+			if err1 != nil || err2 != nil {
+				return nil, errMemoryNotInteger
+			}
+			limit = true
+			i += 2
+		default:
+			return nil, errMemorySyntax
+		}
+	}
+	if limit && by == "" {
+		return nil, errors.New("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	_, z, err := memoryZSet(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	members, err := memoryZSelect(z, args[1], args[2], by, rev)
+	if err != nil {
+		return nil, err
+	}
+	if limit {
+		if offset < 0 || offset >= int64(len(members)) {
+			members = nil
+		} else {
+			members = members[offset:]
+			if count >= 0 && count < int64(len(members)) {
+				members = members[:count]
+			}
+		}
+	}
+	return memoryZReply(members, withScores), nil
+}
+
+// memoryZRangeAlias 将 ZREVRANGE、ZRANGEBYSCORE 和 ZREVRANGEBYSCORE 转换为 ZRANGE 执行
+func memoryZRangeAlias(s *memoryStore, args [][]byte) (interface{}, error) {
+	extra := map[string][]string{
+		"ZREVRANGE":        {"REV"},
+		"ZRANGEBYSCORE":    {"BYSCORE"},
+		"ZREVRANGEBYSCORE": {"BYSCORE", "REV"},
+	}[s.cmd]
+	rangeArgs := append([][]byte{}, args...)
+	for _, flag := range extra {
+		rangeArgs = append(rangeArgs, []byte(flag))
+	}
+	return memoryZRange(s, rangeArgs)
+}
+
+func memoryZRem(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, z, err := memoryZSet(s, string(args[0]), false)
+	if err != nil || entry == nil {
+		return int64(0), err
+	}
+	n := int64(0)
+	for _, member := range args[1:] {
+		if _, ok := z[string(member)]; ok {
+			delete(z, string(member))
+			n++
+		}
+	}
+	if n > 0 {
+		s.update(string(args[0]), entry)
+	}
+	return n, nil
+}
+
+func memoryZRemRange(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, z, err := memoryZSet(s, string(args[0]), false)
+	if err != nil || entry == nil {
+		return int64(0), err
+	}
+	by := "BYSCORE"
+	if s.cmd == "ZREMRANGEBYRANK" {
+		by = ""
+	}
+	members, err := memoryZSelect(z, args[1], args[2], by, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		delete(z, m.member)
+	}
+	if len(members) > 0 {
+		s.update(string(args[0]), entry)
+	}
+	return int64(len(members)), nil
+}
+
+func memoryZCard(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, z, err := memoryZSet(s, string(args[0]), false)
+	return int64(len(z)), err
+}
+
+func memoryZCount(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, z, err := memoryZSet(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	members, err := memoryZSelect(z, args[1], args[2], "BYSCORE", false)
+	return int64(len(members)), err
+}
+
+func memoryZPop(s *memoryStore, args [][]byte) (interface{}, error) {
+	entry, z, err := memoryZSet(s, string(args[0]), false)
+	if err != nil || entry == nil {
+		return []interface{}{}, err
+	}
+	count := int64(1)
+	if len(args) > 1 {
+		if count, err = memoryInt(args[1]); err != nil || count < 0 {
+			return nil, errors.New("ERR value is out of range, must be positive")
+		}
+	}
+	members := memoryZSorted(z)
+	if s.cmd == "ZPOPMAX" {
+		for i, j := 0, len(members)-1; i < j; i, j = i+1, j-1 {
+			members[i], members[j] = members[j], members[i]
+		}
+	}
+	if count < int64(len(members)) {
+		members = members[:count]
+	}
+	for _, m := range members {
+		delete(z, m.member)
+	}
+	s.update(string(args[0]), entry)
+	return memoryZReply(members, true), nil
+}
+
+// memoryZCombine 处理 ZUNION/ZINTER/ZDIFF 及其 STORE 版本，普通集合中成员的分数为1
+func memoryZCombine(s *memoryStore, args [][]byte) (interface{}, error) {
+	cmd := s.cmd
+	store := strings.HasSuffix(cmd, "STORE")
+	op := strings.TrimSuffix(strings.TrimPrefix(cmd, "Z"), "STORE")
+	dest := ""
+	if store {
+		dest = string(args[0])
+		args = args[1:]
+	}
+	keys, rest, err := memoryNumKeys(args)
+	if err != nil {
+		return nil, err
+	}
+	weights := make([]float64, len(keys))
+	for i := range weights {
+		weights[i] = 1
+	}
+	aggregate := "SUM"
+	withScores := false
+	for i := 0; i < len(rest); i++ {
+		switch memoryUpper(rest[i]) {
+		case "WEIGHTS":
+			if i+len(keys) >= len(rest) {
+				return nil, errMemorySyntax
+			}
+			for j := range keys {
+				if weights[j], err = memoryFloat(rest[i+1+j]); err != nil {
+					return nil, errors.New("ERR weight value is not a float")
+				}
+			}
+			i += len(keys)
+		case "AGGREGATE":
+			if i+1 >= len(rest) {
+				return nil, errMemorySyntax
+			}
+			aggregate = memoryUpper(rest[i+1])
+			if aggregate != "SUM" && aggregate != "MIN" && aggregate != "MAX" {
+				return nil, errMemorySyntax
+			}
+			i++
+		case "WITHSCORES":
+			if store {
+				return nil, errMemorySyntax
+			}
+			withScores = true
+		default:
+			return nil, errMemorySyntax
+		}
+	}
+
+	var result map[string]float64
+	for i, key := range keys {
+		scores := map[string]float64{}
+		entry := s.get(string(key))
+		if entry != nil {
+			switch v := entry.value.(type) {
+			case map[string]float64:
+				for member, score := range v {
+					scores[member] = score * weights[i]
+				}
+			case map[string]bool:
+				for member := range v {
+					scores[member] = weights[i]
+				}
+			default:
+				return nil, errMemoryWrongType
+			}
+		}
+		if i == 0 {
+			result = scores
+			continue
+		}
+		switch op {
+		case "UNION":
+			for member, score := range scores {
+				if old, ok := result[member]; ok {
+					result[member] = memoryAggregate(aggregate, old, score)
+				} else {
+					result[member] = score
+				}
+			}
+		case "INTER":
+			for member, old := range result {
+				if score, ok := scores[member]; ok {
+					result[member] = memoryAggregate(aggregate, old, score)
+				} else {
+					delete(result, member)
+				}
+			}
+		case "DIFF":
+			for member := range scores {
+				delete(result, member)
+			}
+		}
+	}
+	if store {
+		s.del(dest)
+		if len(result) > 0 {
+			s.put(dest, result)
+		}
+		return int64(len(result)), nil
+	}
+	return memoryZReply(memoryZSorted(result), withScores), nil
+}
+
+func memoryAggregate(aggregate string, a, b float64) float64 {
+	switch aggregate {
+	case "MIN":
+		return math.Min(a, b)
+	case "MAX":
+		return math.Max(a, b)
+	}
+	return a + b
+}
+
+func memoryZScan(s *memoryStore, args [][]byte) (interface{}, error) {
+	_, z, err := memoryZSet(s, string(args[0]), false)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, 0, len(z))
+	for _, m := range memoryZSorted(z) {
+		members = append(members, m.member)
+	}
+	return memoryScanItems(members, args[1:], nil, func(member string) []interface{} {
+		return []interface{}{[]byte(member), memoryFormatFloat(z[member])}
+	})
+}
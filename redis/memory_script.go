@@ -0,0 +1,290 @@
+package redis
+
+import (
+	"math"
+	"strconv"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// memoryScript 使用Go模拟的Lua脚本，memory:// 不支持执行任意的Lua脚本
+// 返回值按照Lua脚本的返回值转换：数字为 int64，字符串为 []byte，false 为 nil，table 为 []interface{}
+type memoryScript func(s *memoryStore, keys, argv []string) interface{}
+
+var memoryScripts map[string]memoryScript
+
+// 模拟插件内置的锁、会话、限流和队列脚本，使 Lock、Remember、Session、RateLimit 和 Queue 可以在 memory:// 上使用
+// 修改内置的Lua脚本后需要同步修改这里的实现，memory_script_test.go 会检查脚本的 sha 是否变化
+func init() {
+	memoryScripts = map[string]memoryScript{
+		lockAcquireScript.sha: func(s *memoryStore, keys, argv []string) interface{} {
+			if s.call("SET", keys[0], argv[0], "NX", "PX", argv[1]) == nil {
+				return nil
+			}
+			return s.call("INCR", keys[1])
+		},
+		lockReleaseScript.sha: func(s *memoryStore, keys, argv []string) interface{} {
+			if v, ok := s.call("GET", keys[0]).([]byte); ok && string(v) == argv[0] {
+				return s.call("DEL", keys[0])
+			}
+			return int64(0)
+		},
+		lockExtendScript.sha: func(s *memoryStore, keys, argv []string) interface{} {
+			if v, ok := s.call("GET", keys[0]).([]byte); ok && string(v) == argv[0] {
+				return s.call("PEXPIRE", keys[0], argv[1])
+			}
+			return int64(0)
+		},
+		sessionGetScript.sha: func(s *memoryStore, keys, argv []string) interface{} {
+			var reply interface{}
+			if argv[0] == "" {
+				reply = s.call("HGETALL", keys[0])
+			} else {
+				reply = s.call("HGET", keys[0], argv[0])
+			}
+			if argv[2] == "1" {
+				s.call("PEXPIRE", keys[0], argv[1])
+			}
+			return reply
+		},
+		sessionSetScript.sha: func(s *memoryStore, keys, argv []string) interface{} {
+			if reply, ok := s.call("HSET", append([]string{keys[0]}, argv[2:]...)...).(redigo.Error); ok {
+				return reply
+			}
+			if ttl, _ := s.call("PTTL", keys[0]).(int64); argv[1] == "1" || ttl < 0 {
+				s.call("PEXPIRE", keys[0], argv[0])
+			}
+			return int64(1)
+		},
+		rateLimitFixedScript.sha:       memoryRateLimitFixed,
+		rateLimitSlidingScript.sha:     memoryRateLimitSliding,
+		rateLimitTokenBucketScript.sha: memoryRateLimitTokenBucket,
+		queuePushScript.sha:            memoryQueuePush,
+		queuePopScript.sha:             memoryQueuePop,
+		queueAckScript.sha: func(s *memoryStore, keys, argv []string) interface{} {
+			if !memoryQueueHasReceipt(s, keys[0], keys[1], argv[0], argv[1]) {
+				return int64(0)
+			}
+			s.call("ZREM", keys[0], argv[0])
+			s.call("DEL", keys[1])
+			return int64(1)
+		},
+		queueNackScript.sha:        memoryQueueNack,
+		queueDeadLettersScript.sha: memoryQueueDeadLetters,
+	}
+}
+
+// memoryNumber 按照Lua的 tonumber 转换参数或回复，无法转换时返回false
+func memoryNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case []byte:
+		f, err := strconv.ParseFloat(string(n), 64)
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func memoryNumberOr(v interface{}, defaultValue float64) float64 {
+	if n, ok := memoryNumber(v); ok {
+		return n
+	}
+	return defaultValue
+}
+
+// memoryLuaString 按照Lua的 tostring 格式化数字
+func memoryLuaString(f float64) string {
+	return strconv.FormatFloat(f, 'g', 14, 64)
+}
+
+func memoryInts(values ...float64) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = int64(v)
+	}
+	return out
+}
+
+func memoryReplyArray(reply interface{}) []interface{} {
+	arr, _ := reply.([]interface{})
+	return arr
+}
+
+func memoryRateLimitFixed(s *memoryStore, keys, argv []string) interface{} {
+	limit, window, cost := memoryNumberOr(argv[0], 0), memoryNumberOr(argv[1], 0), memoryNumberOr(argv[2], 0)
+	current := memoryNumberOr(s.call("GET", keys[0]), 0)
+	ttl := memoryNumberOr(s.call("PTTL", keys[0]), -1)
+	if ttl < 0 {
+		ttl = window
+	}
+	if current+cost > limit {
+		retry := ttl
+		if cost > limit {
+			retry = -1
+		}
+		return memoryInts(0, math.Max(limit-current, 0), ttl, retry)
+	}
+	current = memoryNumberOr(s.call("INCRBY", keys[0], argv[2]), 0)
+	if memoryNumberOr(s.call("PTTL", keys[0]), -1) < 0 {
+		s.call("PEXPIRE", keys[0], argv[1])
+	}
+	return memoryInts(1, limit-current, ttl, 0)
+}
+
+func memoryRateLimitSliding(s *memoryStore, keys, argv []string) interface{} {
+	limit, window, cost := memoryNumberOr(argv[0], 0), memoryNumberOr(argv[1], 0), memoryNumberOr(argv[2], 0)
+	now := float64(s.now())
+	// 与脚本相同：最早的请求离开窗口的时间
+	scoreAt := func(index float64) (float64, bool) {
+		e := memoryReplyArray(s.call("ZRANGE", keys[0], memoryLuaString(index), memoryLuaString(index), "WITHSCORES"))
+		if len(e) < 2 {
+			return 0, false
+		}
+		return memoryNumber(e[1])
+	}
+	oldestReset := func() float64 {
+		if oldest, ok := scoreAt(0); ok {
+			return oldest + window - now
+		}
+		return window
+	}
+
+	s.call("ZREMRANGEBYSCORE", keys[0], "-inf", memoryLuaString(now-window))
+	count := memoryNumberOr(s.call("ZCARD", keys[0]), 0)
+	if count+cost > limit {
+		reset := oldestReset()
+		retry := float64(-1)
+		if cost <= limit {
+			if score, ok := scoreAt(count + cost - limit - 1); ok {
+				retry = score + window - now
+			}
+		}
+		return memoryInts(0, math.Max(limit-count, 0), reset, retry)
+	}
+	for i := 1; i <= int(cost); i++ {
+		s.call("ZADD", keys[0], memoryLuaString(now), argv[3]+":"+strconv.Itoa(i))
+	}
+	s.call("PEXPIRE", keys[0], argv[1])
+	return memoryInts(1, limit-count-cost, oldestReset(), 0)
+}
+
+func memoryRateLimitTokenBucket(s *memoryStore, keys, argv []string) interface{} {
+	capacity, window, cost := memoryNumberOr(argv[0], 0), memoryNumberOr(argv[1], 0), memoryNumberOr(argv[2], 0)
+	now := float64(s.now())
+	data := memoryReplyArray(s.call("HMGET", keys[0], "tokens", "ts"))
+	tokens := memoryNumberOr(data[0], capacity)
+	ts := memoryNumberOr(data[1], now)
+	tokens = math.Min(capacity, tokens+math.Max(now-ts, 0)*capacity/window)
+	allowed, retry := float64(0), float64(0)
+	if tokens >= cost {
+		allowed = 1
+		tokens -= cost
+	} else if cost > capacity {
+		retry = -1
+	} else {
+		retry = math.Ceil((cost - tokens) * window / capacity)
+	}
+	s.call("HSET", keys[0], "tokens", memoryLuaString(tokens), "ts", memoryLuaString(now))
+	reset := math.Ceil((capacity - tokens) * window / capacity)
+	s.call("PEXPIRE", keys[0], memoryLuaString(math.Max(reset, 1)))
+	return memoryInts(allowed, math.Floor(tokens), reset, retry)
+}
+
+// memoryQueueMakeReady 对应 queueLuaCommon 中的 makeReady
+func memoryQueueMakeReady(s *memoryStore, readyKey, jobKey, id string) {
+	priority := memoryNumberOr(s.call("HGET", jobKey, "priority"), 0)
+	s.call("ZADD", readyKey, strconv.FormatFloat(-priority*10000000000000+float64(s.now()), 'f', 0, 64), id)
+}
+
+func memoryQueuePush(s *memoryStore, keys, argv []string) interface{} {
+	now := s.now()
+	id := strconv.FormatInt(replyInt(s.call("INCR", keys[0])), 10)
+	jobKey := keys[3] + id
+	delay := memoryNumberOr(argv[1], 0)
+	s.call("HSET", jobKey, "payload", argv[0], "priority", argv[2], "attempts", "0", "maxAttempts", argv[3], "createdAt", strconv.FormatInt(now, 10))
+	if delay > 0 {
+		s.call("ZADD", keys[2], memoryLuaString(float64(now)+delay), id)
+	} else {
+		memoryQueueMakeReady(s, keys[1], jobKey, id)
+	}
+	return []byte(id)
+}
+
+func memoryQueuePop(s *memoryStore, keys, argv []string) interface{} {
+	now := strconv.FormatInt(s.now(), 10)
+	for _, id := range replyStrings(s.call("ZRANGEBYSCORE", keys[1], "-inf", now, "LIMIT", "0", "100")) {
+		s.call("ZREM", keys[1], id)
+		memoryQueueMakeReady(s, keys[0], keys[4]+id, id)
+	}
+	for _, id := range replyStrings(s.call("ZRANGEBYSCORE", keys[2], "-inf", now, "LIMIT", "0", "100")) {
+		s.call("ZREM", keys[2], id)
+		jobKey := keys[4] + id
+		s.call("HDEL", jobKey, "receipt")
+		job := memoryReplyArray(s.call("HMGET", jobKey, "attempts", "maxAttempts"))
+		if memoryNumberOr(job[0], 0) >= memoryNumberOr(job[1], 1) {
+			s.call("HSET", jobKey, "lastError", "visibility timeout")
+			s.call("ZADD", keys[3], now, id)
+		} else {
+			memoryQueueMakeReady(s, keys[0], jobKey, id)
+		}
+	}
+	for {
+		ids := replyStrings(s.call("ZRANGE", keys[0], "0", "0"))
+		if len(ids) == 0 {
+			return nil
+		}
+		id := ids[0]
+		jobKey := keys[4] + id
+		s.call("ZREM", keys[0], id)
+		if s.call("EXISTS", jobKey) == int64(1) {
+			s.call("HINCRBY", jobKey, "attempts", "1")
+			s.call("HSET", jobKey, "receipt", argv[1])
+			s.call("ZADD", keys[2], memoryLuaString(float64(s.now())+memoryNumberOr(argv[0], 0)), id)
+			job := memoryReplyArray(s.call("HMGET", jobKey, "payload", "attempts", "maxAttempts", "priority", "createdAt", "lastError"))
+			return append(append([]interface{}{[]byte(id)}, job...), []byte(argv[1]))
+		}
+	}
+}
+
+// memoryQueueHasReceipt 任务是否在处理中并且凭证与本次投递的凭证相同
+func memoryQueueHasReceipt(s *memoryStore, inflightKey, jobKey, id, receipt string) bool {
+	if s.call("ZSCORE", inflightKey, id) == nil {
+		return false
+	}
+	v, ok := s.call("HGET", jobKey, "receipt").([]byte)
+	return ok && string(v) == receipt
+}
+
+func memoryQueueNack(s *memoryStore, keys, argv []string) interface{} {
+	if !memoryQueueHasReceipt(s, keys[0], keys[4], argv[0], argv[1]) {
+		return []byte("")
+	}
+	s.call("ZREM", keys[0], argv[0])
+	s.call("HDEL", keys[4], "receipt")
+	job := memoryReplyArray(s.call("HMGET", keys[4], "attempts", "maxAttempts"))
+	if memoryNumberOr(job[0], 0) >= memoryNumberOr(job[1], 1) {
+		s.call("HSET", keys[4], "lastError", "max attempts exceeded")
+		s.call("ZADD", keys[3], strconv.FormatInt(s.now(), 10), argv[0])
+		return []byte("dead")
+	}
+	if delay := memoryNumberOr(argv[2], 0); delay > 0 {
+		s.call("ZADD", keys[2], memoryLuaString(float64(s.now())+delay), argv[0])
+	} else {
+		memoryQueueMakeReady(s, keys[1], keys[4], argv[0])
+	}
+	return []byte("retry")
+}
+
+func memoryQueueDeadLetters(s *memoryStore, keys, argv []string) interface{} {
+	out := make([]interface{}, 0)
+	stop := memoryLuaString(memoryNumberOr(argv[0], 0) - 1)
+	for _, id := range replyStrings(s.call("ZRANGE", keys[0], "0", stop)) {
+		job := memoryReplyArray(s.call("HMGET", keys[1]+id, "payload", "attempts", "maxAttempts", "priority", "createdAt", "lastError"))
+		out = append(out, append([]interface{}{[]byte(id)}, job...))
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 内置脚本的 sha，修改了Lua脚本后这里会失败，需要同步修改 memory_script.go 中的Go实现并通过 TestMemoryScriptsMatchRedis 验证后再更新
+func TestMemoryScriptsPinned(t *testing.T) {
+	tests := []struct {
+		name   string
+		script *luaScript
+		sha    string
+	}{
+		{"lockAcquire", lockAcquireScript, "8929050d432359cdf5948611da924ea250f6ad81"},
+		{"lockRelease", lockReleaseScript, "389ba8690d05be5323607d53b7f5b9d243d6bfb4"},
+		{"lockExtend", lockExtendScript, "df6e7d13ebcee06bf37db1a8aa5d1c1016bfb298"},
+		{"rateLimitFixed", rateLimitFixedScript, "d7842a5075dfe4c4527fe239950ace830f7acf5d"},
+		{"rateLimitSliding", rateLimitSlidingScript, "7a8edebfe27952df7add190394280dbc5953cff9"},
+		{"rateLimitTokenBucket", rateLimitTokenBucketScript, "f7d96c0f30b480cc5adeb83a0f883de5da06a86a"},
+		{"queuePush", queuePushScript, "101ef8340d9a19c497b0e3ec00d52e7a7966cd24"},
+		{"queuePop", queuePopScript, "5a8f0fcc9e67a5ec91f471d41c5be8423aede42a"},
+		{"queueAck", queueAckScript, "5a90ecf206e2fee456e96abf1550c82331d0022c"},
+		{"queueNack", queueNackScript, "cc8c735d61afb5009ac8e9d074994d1c8929931d"},
+		{"queueDeadLetters", queueDeadLettersScript, "302cec8a5b17388a76d4159cc9616c82b8309f6b"},
+		{"sessionGet", sessionGetScript, "26e7435060bd6cec3660a3b4f67969b83e2fcc06"},
+		{"sessionSet", sessionSetScript, "7a995b15f4ead5d5c980d58bee60560bb669b18e"},
+	}
+	if len(tests) != len(memoryScripts) {
+		t.Errorf("%d scripts emulated by memory backend, %d pinned", len(memoryScripts), len(tests))
+	}
+	for _, test := range tests {
+		if test.script.sha != test.sha {
+			t.Errorf("%s script changed, update its memory backend copy and the pinned sha %s", test.name, test.script.sha)
+		}
+		if memoryScripts[test.script.sha] == nil {
+			t.Errorf("%s script is not emulated by memory backend", test.name)
+		}
+	}
+}
+
+// scriptTrace 依次执行使用内置脚本的操作，记录与时钟无关的结果
+func scriptTrace(rd *Redis) []interface{} {
+	trace := make([]interface{}, 0)
+	add := func(values ...interface{}) {
+		trace = append(trace, values)
+	}
+
+	l1, err1 := rd.Lock(nil, "job", 10000, nil)
+	l2, err2 := rd.Lock(nil, "job", 10000, nil)
+	add(l1 != nil, err1, l2 == nil, err2, l1.Fence(), l1.Extend(20000), l1.Unlock(), l1.Unlock())
+	l3, _ := rd.Lock(nil, "job", 10000, nil)
+	add(l3.Fence(), l3.Unlock())
+
+	for _, algorithm := range []string{"fixed", "sliding", "tokenBucket"} {
+		for _, cost := range []int64{1, 1, 2, 1, 5} {
+			r, err := rd.RateLimit("rate:"+algorithm, map[string]interface{}{"limit": 3, "windowMs": 10000, "algorithm": algorithm, "cost": cost})
+			retry := r.RetryAfterMs
+			if retry > 0 {
+				retry = 1
+			}
+			add(algorithm, r.Allowed, r.Remaining, r.ResetAfterMs > 0, retry, err)
+		}
+	}
+
+	q := rd.Queue("task")
+	for _, priority := range []int{0, 5, -1} {
+		id, err := q.Push(map[string]interface{}{"priority": priority}, &map[string]interface{}{"priority": priority, "maxAttempts": 2})
+		add(id, err)
+	}
+	delayedId, _ := q.Push("later", &map[string]interface{}{"delayMs": 60000})
+	stats, _ := q.Stats()
+	add(delayedId, stats)
+	for i := 0; i < 3; i++ {
+		job, err := q.Pop(60000)
+		add(job.Id, job.Payload, job.Attempts, job.MaxAttempts, job.Priority, job.Receipt != "", err)
+		switch i {
+		case 0:
+			add(q.Ack(job.Id, "wrong"), q.Ack(job.Id, job.Receipt), q.Ack(job.Id, job.Receipt))
+		case 1:
+			add(q.Nack(job.Id, job.Receipt, 0), q.Nack(job.Id, job.Receipt, 0))
+			job, _ = q.Pop(60000)
+			add(job.Id, job.Attempts, q.Nack(job.Id, job.Receipt, 0))
+		}
+	}
+	job, err := q.Pop(60000)
+	add(job == nil, err)
+	stats, _ = q.Stats()
+	dead := q.DeadLetters(nil)
+	add(stats, len(dead))
+	for _, d := range dead {
+		add(d.Id, d.Payload, d.Attempts, d.LastError)
+	}
+
+	session, _ := rd.Session("s1", &map[string]interface{}{"ttl": 10, "sliding": true})
+	add(session.Get("a"), session.All(), session.Set("a", 1), session.Set("b", []int{1, 2}))
+	add(session.Get("a"), session.Get("none"), session.All(), rd.PTTL("session:s1") > 9000)
+	fixed, _ := rd.Session("s2", &map[string]interface{}{"ttl": 10})
+	add(fixed.Set("a", 1), rd.PExpire("session:s2", 5000), fixed.Set("b", 2), fixed.Get("a"), rd.PTTL("session:s2") <= 5000)
+	return trace
+}
+
+// 在Redis服务器和 memory:// 上执行相同的操作，Go实现的脚本需要得到与Lua脚本相同的结果
+func TestMemoryScriptsMatchRedis(t *testing.T) {
+	rd := newTestRedis(t, "")
+	expect := scriptTrace(rd)
+	got := scriptTrace(newMemoryRedis(t, ""))
+	if len(got) != len(expect) {
+		t.Fatalf("trace length %d, expect %d", len(got), len(expect))
+	}
+	for i := range expect {
+		if !reflect.DeepEqual(got[i], expect[i]) {
+			t.Errorf("step %d: memory %v, redis %v", i, got[i], expect[i])
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package redis
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/api-go/plugin"
+	"github.com/api-go/plugins/runtime"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// newMemoryRedis 为每个测试创建独立的 memory:// 连接，query 为连接地址中的参数，例如 "?prefix=app:"
+func newMemoryRedis(t *testing.T, query string) *Redis {
+	redisUrl := "memory://" + strings.ReplaceAll(t.Name(), "/", "_") + "/0" + query
+	return newRedis(makeRedisPool(redisUrl), parseConnOption(redisUrl))
+}
+
+func TestMemoryCommands(t *testing.T) {
+	s := newMemoryStore("0")
+	bulks := func(values ...string) []interface{} { return memoryBulks(values) }
+	tests := []struct {
+		cmd    string
+		args   []string
+		expect interface{}
+	}{
+		{"SET", []string{"s", "abc"}, "OK"},
+		{"APPEND", []string{"s", "d"}, int64(4)},
+		{"GET", []string{"s"}, []byte("abcd")},
+		{"INCR", []string{"s"}, redigo.Error("ERR value is not an integer or out of range")},
+		{"INCRBY", []string{"n", "5"}, int64(5)},
+		{"MGET", []string{"s", "n", "none"}, []interface{}{[]byte("abcd"), []byte("5"), nil}},
+		{"HSET", []string{"h", "a", "1", "b", "2"}, int64(2)},
+		{"HGETALL", []string{"h"}, bulks("a", "1", "b", "2")},
+		{"GET", []string{"h"}, redigo.Error("WRONGTYPE Operation against a key holding the wrong kind of value")},
+		{"RPUSH", []string{"l", "a", "b", "c"}, int64(3)},
+		{"LRANGE", []string{"l", "1", "-1"}, bulks("b", "c")},
+		{"LPOP", []string{"l"}, []byte("a")},
+		{"SADD", []string{"set", "x", "y", "x"}, int64(2)},
+		{"SMEMBERS", []string{"set"}, bulks("x", "y")},
+		{"ZADD", []string{"z", "2", "b", "1", "a"}, int64(2)},
+		{"ZRANGE", []string{"z", "0", "-1", "WITHSCORES"}, bulks("a", "1", "b", "2")},
+		{"ZRANGEBYSCORE", []string{"z", "(1", "+inf"}, bulks("b")},
+		{"SETBIT", []string{"bits", "9", "1"}, int64(0)},
+		{"BITCOUNT", []string{"bits"}, int64(1)},
+		{"BITPOS", []string{"bits", "1"}, int64(9)},
+		{"BITPOS", []string{"bits", "0", "1"}, int64(8)},
+		{"BITPOS", []string{"none", "1"}, int64(-1)},
+		{"SET", []string{"b1", "\xf0"}, "OK"},
+		{"SET", []string{"b2", "\x3c\xff"}, "OK"},
+		{"BITOP", []string{"AND", "b3", "b1", "b2"}, int64(2)},
+		{"GET", []string{"b3"}, []byte("\x30\x00")},
+		{"BITOP", []string{"OR", "b3", "b1", "b2"}, int64(2)},
+		{"GET", []string{"b3"}, []byte("\xfc\xff")},
+		{"BITOP", []string{"NOT", "b3", "b1"}, int64(1)},
+		{"GET", []string{"b3"}, []byte("\x0f")},
+		{"PFADD", []string{"hll1", "a", "b", "c"}, int64(1)},
+		{"PFADD", []string{"hll1", "a"}, int64(0)},
+		{"PFADD", []string{"hll2", "c", "d"}, int64(1)},
+		{"PFCOUNT", []string{"hll1", "hll2"}, int64(4)},
+		{"PFMERGE", []string{"hll3", "hll1", "hll2"}, "OK"},
+		{"PFCOUNT", []string{"hll3"}, int64(4)},
+		{"PFCOUNT", []string{"s"}, redigo.Error("WRONGTYPE Key is not a valid HyperLogLog string value.")},
+		{"GEOADD", []string{"geo", "13.36", "38.11", "Palermo"}, redigo.Error("ERR command 'geoadd' is not supported by memory backend, use a redis server")},
+		{"XADD", []string{"stream", "*", "a", "1"}, redigo.Error("ERR command 'xadd' is not supported by memory backend, use a redis server")},
+		{"BITFIELD", []string{"bits", "GET", "u8", "0"}, redigo.Error("ERR command 'bitfield' is not supported by memory backend, use a redis server")},
+		{"EVAL", []string{"return 1", "0"}, redigo.Error("ERR memory backend only runs the built-in scripts of this plugin, use a redis server for other lua scripts")},
+		{"DEL", []string{"s", "n", "none"}, int64(2)},
+		{"EXISTS", []string{"s"}, int64(0)},
+	}
+	for _, test := range tests {
+		s.lock.Lock()
+		reply := s.call(test.cmd, test.args...)
+		s.lock.Unlock()
+		if !reflect.DeepEqual(reply, test.expect) {
+			t.Errorf("%s %v: %#v, expect %#v", test.cmd, test.args, reply, test.expect)
+		}
+	}
+}
+
+func TestMemoryClock(t *testing.T) {
+	rd := newMemoryRedis(t, "")
+	rd.SetClock(1700000000000)
+	rd.SetEX("k", 10, "v")
+	if rd.PTTL("k") != 10000 {
+		t.Fatal("bad ttl", rd.PTTL("k"))
+	}
+	rd.AdvanceClock(9999)
+	if rd.Get("k") != "v" {
+		t.Fatal("key expired too early")
+	}
+	rd.AdvanceClock(1)
+	if rd.Exists("k") {
+		t.Fatal("key not expired")
+	}
+	if rd.SetClock(0) != true || rd.AdvanceClock(0) < 1700000000000 {
+		t.Fatal("clock not restored")
+	}
+}
+
+func TestMemoryTransaction(t *testing.T) {
+	rd := newMemoryRedis(t, "")
+	other := newMemoryRedis(t, "")
+	rd.Set("balance", 10)
+
+	calls := 0
+	results, err := rd.Transaction([]string{"balance"}, func(tx *Transaction) {
+		calls++
+		v, _ := tx.Get("balance")
+		if calls == 1 {
+			// 其他连接修改了监视的Key，第一次提交失败后重试
+			other.Set("balance", 20)
+		}
+		tx.Set("balance", v.(float64)+1)
+	}, nil)
+	if err != nil || calls != 2 || len(results) != 1 || rd.Get("balance") != float64(21) {
+		t.Fatal("bad transaction", calls, results, err, rd.Get("balance"))
+	}
+}
+
+func TestMemoryBlocking(t *testing.T) {
+	rd := newMemoryRedis(t, "")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var result *ListPopResult
+	go func() {
+		defer wg.Done()
+		result = rd.BLPop([]string{"jobs"}, 2)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	rd.RPush("jobs", "job1")
+	wg.Wait()
+	if result == nil || result.Key != "jobs" || result.Value != "job1" {
+		t.Fatal("bad blocking pop", result)
+	}
+	if rd.BLPop([]string{"jobs"}, 0.1) != nil {
+		t.Fatal("blocking pop should time out")
+	}
+}
+
+func TestMemoryPublish(t *testing.T) {
+	rd := newMemoryRedis(t, "")
+	ctx := plugin.NewContext(nil)
+	callback, messages := receiveMessages()
+	if err := rd.PSubscribe(ctx, []string{"news.*"}, callback); err != nil {
+		t.Fatal(err)
+	}
+	defer runtime.RunDestructors(ctx)
+	rd.Publish("news.sport", "goal")
+	rd.Publish("weather", "rain")
+	waitMessage(t, messages, &testMessage{"news.sport", "goal"})
+	waitMessage(t, messages, nil)
+}
+
+func TestMemoryShared(t *testing.T) {
+	rd1 := newMemoryRedis(t, "")
+	rd2 := newMemoryRedis(t, "?prefix=app:")
+	rd2.Set("k", "v")
+	if rd1.Get("app:k") != "v" {
+		t.Fatal("same name should share data")
+	}
+	redisUrl := "memory://" + t.Name() + "/1"
+	rd3 := newRedis(makeRedisPool(redisUrl), parseConnOption(redisUrl))
+	if rd3.Exists("app:k") || !strings.HasPrefix(rd3.pool.Config.Host, "memory-") {
+		t.Fatal("different db should not share data")
+	}
+}
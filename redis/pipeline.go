@@ -0,0 +1,311 @@
+package redis
+
+import (
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/ssgo/u"
+)
+
+type queuedCommand struct {
+	cmd    string
+	args   []interface{}
+	decode func(interface{}) interface{}
+}
+
+// commandQueue 缓存待执行的命令，供 Pipeline 和 Transaction 共用
+type commandQueue struct {
+	keyPrefix
+	valueCodec
+	cmds []*queuedCommand
+}
+
+type Pipeline struct {
+	commandQueue
+	rd *Redis
+}
+
+type PipelineResult struct {
+	Result interface{}
+	Error  string
+}
+
+// Pipeline 创建管道，将多个命令一次性发送到服务器以减少网络往返
+// Pipeline return 管道对象，在管道对象上调用 set、hset、incr 等方法将命令加入队列，最后调用 exec 执行
+func (rd *Redis) Pipeline() *Pipeline {
+	return &Pipeline{rd: rd, commandQueue: commandQueue{keyPrefix: rd.keyPrefix, valueCodec: rd.valueCodec}}
+}
+
+// Exec 执行管道中的所有命令并清空队列
+// Exec return 按照加入顺序返回每个命令的结果，每个结果包含 result 和 error
+func (p *Pipeline) Exec() ([]PipelineResult, error) {
+	cmds := p.cmds
+	p.cmds = nil
+	out := make([]PipelineResult, len(cmds))
+	if len(cmds) == 0 {
+		return out, nil
+	}
+
+	conn, err := p.rd.getConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	startTime := time.Now()
+	for _, c := range cmds {
+		if err := conn.Send(c.cmd, makeRedisArgs(c.args)...); err != nil {
+			p.rd.logCommand("PIPELINE", []interface{}{len(cmds)}, startTime, err)
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		p.rd.logCommand("PIPELINE", []interface{}{len(cmds)}, startTime, err)
+		return nil, err
+	}
+	for i, c := range cmds {
+		reply, err := conn.Receive()
+		if err != nil {
+			if _, ok := err.(redigo.Error); !ok {
+				// 网络错误，后续的回复都无法读取
+				p.rd.logCommand("PIPELINE", []interface{}{len(cmds)}, startTime, err)
+				return nil, err
+			}
+			p.rd.pool.LogQueryError(err.Error(), c.cmd, c.args, 0)
+			out[i].Error = err.Error()
+			continue
+		}
+		out[i].Result = c.decode(reply)
+	}
+	p.rd.logCommand("PIPELINE", []interface{}{len(cmds)}, startTime, nil)
+	return out, nil
+}
+
+// Discard 清空管道中尚未执行的命令
+func (p *Pipeline) Discard() {
+	p.cmds = nil
+}
+
+// Len 获取队列中命令的数量
+func (q *commandQueue) Len() int {
+	return len(q.cmds)
+}
+
+func (q *commandQueue) add(decode func(interface{}) interface{}, cmd string, args ...interface{}) int {
+	q.cmds = append(q.cmds, &queuedCommand{cmd: cmd, args: args, decode: decode})
+	return len(q.cmds) - 1
+}
+
+func decodeBool(reply interface{}) interface{}    { return replyBool(reply) }
+func decodeInt(reply interface{}) interface{}     { return replyInt(reply) }
+func decodeStrings(reply interface{}) interface{} { return replyStrings(reply) }
+func decodeRaw(reply interface{}) interface{}     { return reply }
+
+// decodeKeys 转换 KEYS 的回复并去掉Key的前缀
+func (p keyPrefix) decodeKeys(reply interface{}) interface{} { return p.trimKeys(replyStrings(reply)) }
+
+// Do 将任意命令加入队列，参数原样发送，不会为其中的Key添加前缀
+// Do return 命令在结果中的位置
+func (q *commandQueue) Do(cmd string, values ...interface{}) int {
+	cmd, values = splitCommand(cmd, values)
+	return q.add(q.decodeValue, cmd, values...)
+}
+
+// Del 将 DEL 加入队列
+func (q *commandQueue) Del(keys ...string) int {
+	return q.add(decodeInt, "DEL", q.makeKeys(keys)...)
+}
+
+// Exists 将 EXISTS 加入队列
+func (q *commandQueue) Exists(key string) int {
+	return q.add(decodeBool, "EXISTS", q.makeKey(key))
+}
+
+// Expire 将 EXPIRE 加入队列
+func (q *commandQueue) Expire(key string, seconds int) int {
+	return q.add(decodeBool, "EXPIRE", q.makeKey(key), seconds)
+}
+
+// ExpireAt 将 EXPIREAT 加入队列
+func (q *commandQueue) ExpireAt(key string, time int) int {
+	return q.add(decodeBool, "EXPIREAT", q.makeKey(key), time)
+}
+
+// Keys 将 KEYS 加入队列
+func (q *commandQueue) Keys(patten string) int {
+	return q.add(q.decodeKeys, "KEYS", q.makeKey(patten))
+}
+
+// Get 将 GET 加入队列
+func (q *commandQueue) Get(key string) int {
+	return q.add(q.decodeValue, "GET", q.makeKey(key))
+}
+
+// GetEX 将 GETEX 加入队列，需要 Redis 6.2 及以上的版本
+func (q *commandQueue) GetEX(key string, seconds int) int {
+	return q.add(q.decodeValue, "GETEX", q.makeKey(key), "EX", seconds)
+}
+
+// Set 将 SET 加入队列
+func (q *commandQueue) Set(key string, value interface{}) int {
+	return q.add(decodeBool, "SET", q.makeKey(key), q.encodeValue(value))
+}
+
+// SetEX 将 SETEX 加入队列
+func (q *commandQueue) SetEX(key string, seconds int, value interface{}) int {
+	return q.add(decodeBool, "SETEX", q.makeKey(key), seconds, q.encodeValue(value))
+}
+
+// SetNX 将 SETNX 加入队列
+func (q *commandQueue) SetNX(key string, value interface{}) int {
+	return q.add(decodeBool, "SETNX", q.makeKey(key), q.encodeValue(value))
+}
+
+// GetSet 将 GETSET 加入队列
+func (q *commandQueue) GetSet(key string, value interface{}) int {
+	return q.add(q.decodeValue, "GETSET", q.makeKey(key), q.encodeValue(value))
+}
+
+// Incr 将 INCR 加入队列
+func (q *commandQueue) Incr(key string) int {
+	return q.add(decodeInt, "INCR", q.makeKey(key))
+}
+
+// Decr 将 DECR 加入队列
+func (q *commandQueue) Decr(key string) int {
+	return q.add(decodeInt, "DECR", q.makeKey(key))
+}
+
+// IncrBy 将 INCRBY 加入队列
+func (q *commandQueue) IncrBy(key string, increment int64) int {
+	return q.add(decodeInt, "INCRBY", q.makeKey(key), increment)
+}
+
+// DecrBy 将 DECRBY 加入队列
+func (q *commandQueue) DecrBy(key string, increment int64) int {
+	return q.add(decodeInt, "DECRBY", q.makeKey(key), increment)
+}
+
+// MGet 将 MGET 加入队列
+func (q *commandQueue) MGet(keys ...string) int {
+	return q.add(q.decodeValues, "MGET", q.makeKeys(keys)...)
+}
+
+// MSet 将 MSET 加入队列
+func (q *commandQueue) MSet(keyAndValues ...interface{}) int {
+	args := make([]interface{}, len(keyAndValues))
+	for i, v := range keyAndValues {
+		if i%2 == 0 {
+			v = q.makeKey(u.String(v))
+		} else {
+			v = q.encodeValue(v)
+		}
+		args[i] = v
+	}
+	return q.add(decodeBool, "MSET", args...)
+}
+
+// HGet 将 HGET 加入队列
+func (q *commandQueue) HGet(key, field string) int {
+	return q.add(q.decodeValue, "HGET", q.makeKey(key), field)
+}
+
+// HSet 将 HSET 加入队列
+func (q *commandQueue) HSet(key, field string, value interface{}) int {
+	return q.add(decodeBool, "HSET", q.makeKey(key), field, q.encodeValue(value))
+}
+
+// HSetNX 将 HSETNX 加入队列
+func (q *commandQueue) HSetNX(key, field string, value interface{}) int {
+	return q.add(decodeBool, "HSETNX", q.makeKey(key), field, q.encodeValue(value))
+}
+
+// HMGet 将 HMGET 加入队列
+func (q *commandQueue) HMGet(key string, fields ...string) int {
+	return q.add(q.decodeValues, "HMGET", append([]interface{}{q.makeKey(key)}, u.ToInterfaceArray(fields)...)...)
+}
+
+// HGetAll 将 HGETALL 加入队列
+func (q *commandQueue) HGetAll(key string) int {
+	return q.add(q.decodeMap, "HGETALL", q.makeKey(key))
+}
+
+// HMSet 将 HMSET 加入队列
+func (q *commandQueue) HMSet(key string, fieldAndValues ...interface{}) int {
+	return q.add(decodeBool, "HMSET", append([]interface{}{q.makeKey(key)}, q.encodePairs(fieldAndValues)...)...)
+}
+
+// HKeys 将 HKEYS 加入队列
+func (q *commandQueue) HKeys(key string) int {
+	return q.add(decodeStrings, "HKEYS", q.makeKey(key))
+}
+
+// HLen 将 HLEN 加入队列
+func (q *commandQueue) HLen(key string) int {
+	return q.add(decodeInt, "HLEN", q.makeKey(key))
+}
+
+// HDel 将 HDEL 加入队列
+func (q *commandQueue) HDel(key string, fields ...string) int {
+	return q.add(decodeInt, "HDEL", append([]interface{}{q.makeKey(key)}, u.ToInterfaceArray(fields)...)...)
+}
+
+// HIncr 将 HINCRBY 1 加入队列
+func (q *commandQueue) HIncr(key, field string) int {
+	return q.add(decodeInt, "HINCRBY", q.makeKey(key), field, 1)
+}
+
+// HExists 将 HEXISTS 加入队列
+func (q *commandQueue) HExists(key, field string) int {
+	return q.add(decodeBool, "HEXISTS", q.makeKey(key), field)
+}
+
+// HDecr 将 HINCRBY -1 加入队列
+func (q *commandQueue) HDecr(key, field string) int {
+	return q.add(decodeInt, "HINCRBY", q.makeKey(key), field, -1)
+}
+
+// HIncrBy 将 HINCRBY 加入队列
+func (q *commandQueue) HIncrBy(key, field string, increment int64) int {
+	return q.add(decodeInt, "HINCRBY", q.makeKey(key), field, increment)
+}
+
+// HDecrBy 将 HINCRBY -increment 加入队列
+func (q *commandQueue) HDecrBy(key, field string, increment int64) int {
+	return q.add(decodeInt, "HINCRBY", q.makeKey(key), field, -increment)
+}
+
+// LPush 将 LPUSH 加入队列
+func (q *commandQueue) LPush(key string, values ...string) int {
+	return q.add(decodeInt, "LPUSH", append([]interface{}{q.makeKey(key)}, q.encodeValues(u.ToInterfaceArray(values))...)...)
+}
+
+// RPush 将 RPUSH 加入队列
+func (q *commandQueue) RPush(key string, values ...string) int {
+	return q.add(decodeInt, "RPUSH", append([]interface{}{q.makeKey(key)}, q.encodeValues(u.ToInterfaceArray(values))...)...)
+}
+
+// LPop 将 LPOP 加入队列
+func (q *commandQueue) LPop(key string) int {
+	return q.add(q.decodeValue, "LPOP", q.makeKey(key))
+}
+
+// RPop 将 RPOP 加入队列
+func (q *commandQueue) RPop(key string) int {
+	return q.add(q.decodeValue, "RPOP", q.makeKey(key))
+}
+
+// LLen 将 LLEN 加入队列
+func (q *commandQueue) LLen(key string) int {
+	return q.add(decodeInt, "LLEN", q.makeKey(key))
+}
+
+// LRange 将 LRANGE 加入队列
+func (q *commandQueue) LRange(key string, start, stop int) int {
+	return q.add(q.decodeValues, "LRANGE", q.makeKey(key), start, stop)
+}
+
+// Publish 将 PUBLISH 加入队列
+func (q *commandQueue) Publish(channel, data string) int {
+	return q.add(decodeInt, "PUBLISH", q.makeKey(channel), data)
+}
@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	rd := newTestRedis(t, "")
+	rd.Set("name", "abc")
+
+	p := rd.Pipeline()
+	p.Set("user", map[string]interface{}{"id": 1})
+	p.Get("user")
+	p.Incr("name")
+	p.HMSet("h", "a", 1, "b", 2)
+	p.HDecrBy("h", "b", 5)
+	p.HKeys("h")
+	p.HLen("h")
+	p.HExists("h", "a")
+	p.RPush("l", "x", "y", "z")
+	p.LRange("l", 1, -1)
+	p.LLen("l")
+	p.GetSet("name", "def")
+	p.GetEX("name", 100)
+	p.Do("TTL name")
+	p.Keys("na*")
+	if p.Len() != 15 {
+		t.Fatal("bad queue length", p.Len())
+	}
+
+	results, err := p.Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expects := []PipelineResult{
+		{Result: true},
+		{Result: map[string]interface{}{"id": float64(1)}},
+		{Error: "ERR value is not an integer or out of range"},
+		{Result: true},
+		{Result: int64(-3)},
+		{Result: []string{"a", "b"}},
+		{Result: int64(2)},
+		{Result: true},
+		{Result: int64(3)},
+		{Result: []interface{}{"y", "z"}},
+		{Result: int64(3)},
+		{Result: "abc"},
+		{Result: "def"},
+		{Result: int64(100)},
+		{Result: []string{"name"}},
+	}
+	if !reflect.DeepEqual(results, expects) {
+		t.Fatalf("bad results\n%#v\nexpect\n%#v", results, expects)
+	}
+	if p.Len() != 0 {
+		t.Fatal("queue not cleared after exec")
+	}
+
+	p.Set("discarded", 1)
+	p.Discard()
+	if results, err = p.Exec(); err != nil || len(results) != 0 || rd.Exists("discarded") {
+		t.Fatal("discarded commands executed", results, err)
+	}
+}
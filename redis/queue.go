@@ -0,0 +1,259 @@
+package redis
+
+import (
+	"encoding/hex"
+
+	"github.com/ssgo/u"
+)
+
+// 任务在 ready 中按照优先级从高到低、进入队列的时间从早到晚排列
+// 分数为 -priority * 10^13 + 毫秒时间戳，使用 %.0f 格式化避免Lua转换为字符串时丢失精度
+const queueLuaCommon = `local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local function makeReady(readyKey, jobKey, id)
+	local priority = tonumber(redis.call('HGET', jobKey, 'priority')) or 0
+	redis.call('ZADD', readyKey, string.format('%.0f', -priority * 10000000000000 + now), id)
+end
+`
+
+// KEYS: seq, ready, delayed, jobPrefix
+// ARGV: payload, delayMs, priority, maxAttempts
+var queuePushScript = newLuaScript(queueLuaCommon + `local id = tostring(redis.call('INCR', KEYS[1]))
+local jobKey = KEYS[4] .. id
+local delay = tonumber(ARGV[2])
+redis.call('HSET', jobKey, 'payload', ARGV[1], 'priority', ARGV[3], 'attempts', 0, 'maxAttempts', ARGV[4], 'createdAt', now)
+if delay > 0 then
+	redis.call('ZADD', KEYS[3], now + delay, id)
+else
+	makeReady(KEYS[2], jobKey, id)
+end
+return id`)
+
+// KEYS: ready, delayed, inflight, dead, jobPrefix
+// ARGV: visibilityTimeoutMs, receipt
+var queuePopScript = newLuaScript(queueLuaCommon + `for _, id in ipairs(redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', now, 'LIMIT', 0, 100)) do
+	redis.call('ZREM', KEYS[2], id)
+	makeReady(KEYS[1], KEYS[5] .. id, id)
+end
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', now, 'LIMIT', 0, 100)) do
+	redis.call('ZREM', KEYS[3], id)
+	local jobKey = KEYS[5] .. id
+	redis.call('HDEL', jobKey, 'receipt')
+	local job = redis.call('HMGET', jobKey, 'attempts', 'maxAttempts')
+	if tonumber(job[1] or '0') >= tonumber(job[2] or '1') then
+		redis.call('HSET', jobKey, 'lastError', 'visibility timeout')
+		redis.call('ZADD', KEYS[4], now, id)
+	else
+		makeReady(KEYS[1], jobKey, id)
+	end
+end
+while true do
+	local ids = redis.call('ZRANGE', KEYS[1], 0, 0)
+	if #ids == 0 then
+		return false
+	end
+	local id = ids[1]
+	local jobKey = KEYS[5] .. id
+	redis.call('ZREM', KEYS[1], id)
+	if redis.call('EXISTS', jobKey) == 1 then
+		redis.call('HINCRBY', jobKey, 'attempts', 1)
+		redis.call('HSET', jobKey, 'receipt', ARGV[2])
+		redis.call('ZADD', KEYS[3], now + tonumber(ARGV[1]), id)
+		local job = redis.call('HMGET', jobKey, 'payload', 'attempts', 'maxAttempts', 'priority', 'createdAt', 'lastError')
+		return {id, job[1], job[2], job[3], job[4], job[5], job[6], ARGV[2]}
+	end
+end`)
+
+// 只有持有本次投递凭证的消费者才能确认或放回任务，超时后被重新投递的任务会更换凭证
+// KEYS: inflight, jobKey
+// ARGV: id, receipt
+var queueAckScript = newLuaScript(`if redis.call('ZSCORE', KEYS[1], ARGV[1]) == false or redis.call('HGET', KEYS[2], 'receipt') ~= ARGV[2] then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('DEL', KEYS[2])
+return 1`)
+
+// KEYS: inflight, ready, delayed, dead, jobKey
+// ARGV: id, receipt, retryDelayMs
+var queueNackScript = newLuaScript(queueLuaCommon + `if redis.call('ZSCORE', KEYS[1], ARGV[1]) == false or redis.call('HGET', KEYS[5], 'receipt') ~= ARGV[2] then
+	return ''
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[5], 'receipt')
+local job = redis.call('HMGET', KEYS[5], 'attempts', 'maxAttempts')
+if tonumber(job[1] or '0') >= tonumber(job[2] or '1') then
+	redis.call('HSET', KEYS[5], 'lastError', 'max attempts exceeded')
+	redis.call('ZADD', KEYS[4], now, ARGV[1])
+	return 'dead'
+end
+local delay = tonumber(ARGV[3])
+if delay > 0 then
+	redis.call('ZADD', KEYS[3], now + delay, ARGV[1])
+else
+	makeReady(KEYS[2], KEYS[5], ARGV[1])
+end
+return 'retry'`)
+
+// KEYS: dead, jobPrefix
+// ARGV: count
+var queueDeadLettersScript = newLuaScript(`local out = {}
+for _, id in ipairs(redis.call('ZRANGE', KEYS[1], 0, tonumber(ARGV[1]) - 1)) do
+	local job = redis.call('HMGET', KEYS[2] .. id, 'payload', 'attempts', 'maxAttempts', 'priority', 'createdAt', 'lastError')
+	table.insert(out, {id, job[1], job[2], job[3], job[4], job[5], job[6]})
+end
+return out`)
+
+type Queue struct {
+	rd   *Redis
+	name string
+}
+
+type QueueJob struct {
+	Id          string
+	Payload     interface{}
+	Attempts    int64
+	MaxAttempts int64
+	Priority    int64
+	CreatedAt   int64
+	LastError   string
+	Receipt     string
+}
+
+type QueueStats struct {
+	Ready    int64
+	Delayed  int64
+	Inflight int64
+	Dead     int64
+}
+
+type queuePushOption struct {
+	DelayMs     int64
+	Priority    int64
+	MaxAttempts int64
+}
+
+// Queue 获取任务队列，使用有序集合、哈希表和Lua脚本保证每一步操作都是原子的
+// Queue name 队列名称，队列的所有Key使用 {name} 作为 hash tag，在集群中位于同一个节点
+// Queue return 队列对象
+func (rd *Redis) Queue(name string) *Queue {
+	return &Queue{rd: rd, name: name}
+}
+
+func (q *Queue) key(name string) string {
+	return q.rd.makeKey("queue:{" + q.name + "}:" + name)
+}
+
+func (q *Queue) jobKey(id string) string {
+	return q.key("job:" + id)
+}
+
+// Push 添加任务
+// Push payload 任务数据，对象会以JSON格式存储
+// Push options 选项 {delayMs, priority, maxAttempts}，delayMs 为延迟执行的毫秒数，priority 越大越先执行（-900到900），maxAttempts 为最多投递的次数（默认3），超过后进入死信
+// Push return 任务ID
+func (q *Queue) Push(payload interface{}, options *map[string]interface{}) (string, error) {
+	opt := queuePushOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	if opt.MaxAttempts <= 0 {
+		opt.MaxAttempts = 3
+	}
+	if opt.Priority > 900 {
+		opt.Priority = 900
+	} else if opt.Priority < -900 {
+		opt.Priority = -900
+	}
+	reply, err := q.rd.evalScript(queuePushScript, []string{q.key("seq"), q.key("ready"), q.key("delayed"), q.key("job:")},
+		q.rd.encodeValue(payload), opt.DelayMs, opt.Priority, opt.MaxAttempts)
+	return replyString(reply), err
+}
+
+// Pop 取出一个任务，任务需要在可见时间内使用返回的 receipt 调用 ack 确认，否则会被重新投递
+// Pop 没有后台进程，到期的延迟任务和超过可见时间的任务只会在调用 pop 时才被移回 ready（或死信），在此之前 stats 仍然把它们计入 delayed 和 inflight
+// Pop visibilityTimeoutMs 可见时间的毫秒数（默认30000）
+// Pop return {id, payload, attempts, maxAttempts, priority, createdAt, lastError, receipt}，receipt 为本次投递的凭证，队列为空时返回null
+func (q *Queue) Pop(visibilityTimeoutMs int64) (*QueueJob, error) {
+	if visibilityTimeoutMs <= 0 {
+		visibilityTimeoutMs = 30000
+	}
+	receipt := hex.EncodeToString(u.MakeToken(16))
+	reply, err := q.rd.evalScript(queuePopScript, []string{q.key("ready"), q.key("delayed"), q.key("inflight"), q.key("dead"), q.key("job:")}, visibilityTimeoutMs, receipt)
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	return q.makeJob(reply), nil
+}
+
+// Ack 确认任务已经处理完成并删除任务
+// Ack receipt pop 返回的投递凭证
+// Ack return 是否成功，凭证不匹配（任务已经超过可见时间被重新投递）时返回false
+func (q *Queue) Ack(jobId, receipt string) bool {
+	reply, _ := q.rd.evalScript(queueAckScript, []string{q.key("inflight"), q.jobKey(jobId)}, jobId, receipt)
+	return replyInt(reply) == 1
+}
+
+// Nack 任务处理失败，重新放回队列，超过最多投递次数时进入死信
+// Nack receipt pop 返回的投递凭证
+// Nack retryDelayMs 重新投递前等待的毫秒数
+// Nack return retry 表示会重新投递，dead 表示进入了死信，任务不在处理中或凭证不匹配时返回空字符串
+func (q *Queue) Nack(jobId, receipt string, retryDelayMs int64) string {
+	reply, _ := q.rd.evalScript(queueNackScript, []string{q.key("inflight"), q.key("ready"), q.key("delayed"), q.key("dead"), q.jobKey(jobId)}, jobId, receipt, retryDelayMs)
+	return replyString(reply)
+}
+
+// DeadLetters 获取死信中的任务
+// DeadLetters count 最多返回的数量（默认100）
+// DeadLetters return [{id, payload, attempts, maxAttempts, priority, createdAt, lastError}]
+func (q *Queue) DeadLetters(count *int) []QueueJob {
+	limit := 100
+	if count != nil && *count > 0 {
+		limit = *count
+	}
+	reply, _ := q.rd.evalScript(queueDeadLettersScript, []string{q.key("dead"), q.key("job:")}, limit)
+	arr, _ := reply.([]interface{})
+	out := make([]QueueJob, 0, len(arr))
+	for _, v := range arr {
+		out = append(out, *q.makeJob(v))
+	}
+	return out
+}
+
+// Stats 获取队列中各个状态的任务数
+// Stats return {ready, delayed, inflight, dead}，inflight 为已经取出还没有确认的任务数
+func (q *Queue) Stats() (QueueStats, error) {
+	p := q.rd.Pipeline()
+	for _, name := range []string{"ready", "delayed", "inflight", "dead"} {
+		p.Do("ZCARD", q.key(name))
+	}
+	results, err := p.Exec()
+	if err != nil || len(results) != 4 {
+		return QueueStats{}, err
+	}
+	return QueueStats{
+		Ready:    u.Int64(results[0].Result),
+		Delayed:  u.Int64(results[1].Result),
+		Inflight: u.Int64(results[2].Result),
+		Dead:     u.Int64(results[3].Result),
+	}, nil
+}
+
+func (q *Queue) makeJob(reply interface{}) *QueueJob {
+	arr, _ := reply.([]interface{})
+	job := &QueueJob{}
+	if len(arr) < 7 {
+		return job
+	}
+	job.Id = replyString(arr[0])
+	job.Payload = q.rd.replyValue(arr[1])
+	job.Attempts = replyInt(arr[2])
+	job.MaxAttempts = replyInt(arr[3])
+	job.Priority = replyInt(arr[4])
+	job.CreatedAt = replyInt(arr[5])
+	job.LastError = replyString(arr[6])
+	if len(arr) > 7 {
+		job.Receipt = replyString(arr[7])
+	}
+	return job
+}
@@ -0,0 +1,108 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	rd := newTestRedis(t, "")
+	q := rd.Queue("mail")
+
+	low, _ := q.Push("low", &map[string]interface{}{"priority": -1})
+	high, _ := q.Push(map[string]interface{}{"to": "a"}, &map[string]interface{}{"priority": 5})
+	delayed, _ := q.Push("later", &map[string]interface{}{"delayMs": 200})
+	if stats, _ := q.Stats(); stats != (QueueStats{Ready: 2, Delayed: 1}) {
+		t.Fatal("bad stats", stats)
+	}
+
+	// 优先级高的先取出
+	job, err := q.Pop(5000)
+	if err != nil || job.Id != high || !reflect.DeepEqual(job.Payload, map[string]interface{}{"to": "a"}) || job.Attempts != 1 || job.Priority != 5 || job.CreatedAt == 0 || job.Receipt == "" {
+		t.Fatal("bad job", job, err)
+	}
+	if q.Ack(job.Id, "wrong") {
+		t.Fatal("ack with wrong receipt should fail")
+	}
+	if !q.Ack(job.Id, job.Receipt) || q.Ack(job.Id, job.Receipt) {
+		t.Fatal("ack should succeed once")
+	}
+	if job, _ = q.Pop(5000); job == nil || job.Id != low {
+		t.Fatal("expect low priority job", job)
+	}
+	if job, _ = q.Pop(5000); job != nil {
+		t.Fatal("delayed job should not be ready", job)
+	}
+	time.Sleep(250 * time.Millisecond)
+	// 到期的延迟任务在 pop 之前仍然计入 delayed
+	if stats, _ := q.Stats(); stats.Delayed != 1 {
+		t.Fatal("delayed job should be moved only by pop", stats)
+	}
+	if job, _ = q.Pop(5000); job == nil || job.Id != delayed || job.Payload != "later" {
+		t.Fatal("delayed job should be ready", job)
+	}
+}
+
+func TestQueueReceipt(t *testing.T) {
+	rd := newTestRedis(t, "")
+	q := rd.Queue("task")
+	id, _ := q.Push("work", nil)
+
+	first, _ := q.Pop(100)
+	time.Sleep(150 * time.Millisecond)
+	// 超过可见时间后重新投递，旧的凭证失效
+	second, _ := q.Pop(5000)
+	if second == nil || second.Id != id || second.Attempts != 2 || second.Receipt == first.Receipt {
+		t.Fatal("job should be redelivered with a new receipt", first, second)
+	}
+	if q.Ack(id, first.Receipt) || q.Nack(id, first.Receipt, 0) != "" {
+		t.Fatal("stale receipt should be rejected")
+	}
+	if !q.Ack(id, second.Receipt) {
+		t.Fatal("current receipt should be accepted")
+	}
+}
+
+func TestQueueRetry(t *testing.T) {
+	rd := newTestRedis(t, "")
+	q := rd.Queue("task")
+	id, _ := q.Push("work", &map[string]interface{}{"maxAttempts": 2})
+
+	job, _ := q.Pop(100)
+	if q.Nack(job.Id, job.Receipt, 100) != "retry" || q.Nack(job.Id, job.Receipt, 0) != "" {
+		t.Fatal("bad nack")
+	}
+	time.Sleep(150 * time.Millisecond)
+	// 第二次投递后超过可见时间没有确认，达到最多投递次数进入死信
+	if job, _ = q.Pop(100); job == nil || job.Attempts != 2 {
+		t.Fatal("job should be retried", job)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if stats, _ := q.Stats(); stats != (QueueStats{Inflight: 1}) {
+		t.Fatal("expired job should stay inflight until pop", stats)
+	}
+	if job, _ = q.Pop(100); job != nil {
+		t.Fatal("job should be dead", job)
+	}
+	dead := q.DeadLetters(nil)
+	if len(dead) != 1 || dead[0].Id != id || dead[0].LastError != "visibility timeout" {
+		t.Fatal("bad dead letters", dead)
+	}
+	if stats, _ := q.Stats(); stats != (QueueStats{Dead: 1}) {
+		t.Fatal("bad stats", stats)
+	}
+}
+
+func TestQueueNackDead(t *testing.T) {
+	rd := newTestRedis(t, "")
+	q := rd.Queue("task")
+	_, _ = q.Push("work", &map[string]interface{}{"maxAttempts": 1})
+	job, _ := q.Pop(0)
+	if q.Nack(job.Id, job.Receipt, 0) != "dead" {
+		t.Fatal("job should be dead")
+	}
+	if dead := q.DeadLetters(nil); len(dead) != 1 || dead[0].LastError != "max attempts exceeded" || dead[0].Payload != "work" {
+		t.Fatal("bad dead letters", dead)
+	}
+}
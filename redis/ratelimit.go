@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/ssgo/u"
+)
+
+// 固定窗口：计数器在窗口开始时创建并设置过期时间
+var rateLimitFixedScript = newLuaScript(`local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	ttl = window
+end
+if current + cost > limit then
+	local retry = ttl
+	if cost > limit then
+		retry = -1
+	end
+	return {0, math.max(limit - current, 0), ttl, retry}
+end
+current = redis.call('INCRBY', KEYS[1], cost)
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], window)
+end
+return {1, limit - current, ttl, 0}`)
+
+// 滑动窗口：使用有序集合记录窗口内每一次请求的时间
+var rateLimitSlidingScript = newLuaScript(`local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count + cost > limit then
+	local reset = window
+	local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+	if oldest[2] then
+		reset = tonumber(oldest[2]) + window - now
+	end
+	local retry = -1
+	if cost <= limit then
+		local e = redis.call('ZRANGE', KEYS[1], count + cost - limit - 1, count + cost - limit - 1, 'WITHSCORES')
+		if e[2] then
+			retry = tonumber(e[2]) + window - now
+		end
+	end
+	return {0, math.max(limit - count, 0), reset, retry}
+end
+for i = 1, cost do
+	redis.call('ZADD', KEYS[1], now, ARGV[4] .. ':' .. i)
+end
+redis.call('PEXPIRE', KEYS[1], window)
+local reset = window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {1, limit - count - cost, reset, 0}`)
+
+// 令牌桶：桶容量为 limit，每个窗口时间匀速补充 limit 个令牌
+var rateLimitTokenBucketScript = newLuaScript(`local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or capacity
+local ts = tonumber(data[2]) or now
+tokens = math.min(capacity, tokens + math.max(now - ts, 0) * capacity / window)
+local allowed = 0
+local retry = 0
+if tokens >= cost then
+	allowed = 1
+	tokens = tokens - cost
+elseif cost > capacity then
+	retry = -1
+else
+	retry = math.ceil((cost - tokens) * window / capacity)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+local reset = math.ceil((capacity - tokens) * window / capacity)
+redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+return {allowed, math.floor(tokens), reset, retry}`)
+
+type RateLimitResult struct {
+	Allowed      bool
+	Remaining    int64
+	ResetAfterMs int64
+	RetryAfterMs int64
+}
+
+type rateLimitOption struct {
+	Limit     int64
+	WindowMs  int64
+	Algorithm string
+	Cost      int64
+}
+
+// RateLimit 限流，使用一个原子的Lua脚本完成检查和计数
+// RateLimit key 限流的Key，例如按照调用方区分："rate:partner1"
+// RateLimit options 选项 {limit, windowMs, algorithm, cost}，algorithm 可以是 fixed（默认）、sliding、tokenBucket，cost 为本次请求消耗的数量（默认1）
+// RateLimit return {allowed, remaining, resetAfterMs, retryAfterMs}，retryAfterMs 为 -1 时表示cost超过limit永远无法通过
+func (rd *Redis) RateLimit(key string, options map[string]interface{}) (RateLimitResult, error) {
+	opt := rateLimitOption{}
+	u.Convert(options, &opt)
+	if opt.Limit <= 0 || opt.WindowMs <= 0 {
+		return RateLimitResult{}, errors.New("rate limit requires limit and windowMs")
+	}
+	if opt.Cost <= 0 {
+		opt.Cost = 1
+	}
+
+	key = rd.makeKey(key)
+	var reply interface{}
+	var err error
+	switch opt.Algorithm {
+	case "", "fixed":
+		reply, err = rd.evalScript(rateLimitFixedScript, []string{key}, opt.Limit, opt.WindowMs, opt.Cost)
+	case "sliding":
+		// 每次请求使用随机的成员名称，避免同一毫秒内的请求互相覆盖
+		reply, err = rd.evalScript(rateLimitSlidingScript, []string{key}, opt.Limit, opt.WindowMs, opt.Cost, hex.EncodeToString(u.MakeToken(8)))
+	case "tokenBucket":
+		reply, err = rd.evalScript(rateLimitTokenBucketScript, []string{key}, opt.Limit, opt.WindowMs, opt.Cost)
+	default:
+		return RateLimitResult{}, errors.New("unknown rate limit algorithm: " + opt.Algorithm)
+	}
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	arr, _ := reply.([]interface{})
+	if len(arr) != 4 {
+		return RateLimitResult{}, errors.New("bad rate limit reply")
+	}
+	return RateLimitResult{
+		Allowed:      replyInt(arr[0]) == 1,
+		Remaining:    replyInt(arr[1]),
+		ResetAfterMs: replyInt(arr[2]),
+		RetryAfterMs: replyInt(arr[3]),
+	}, nil
+}
@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	type step struct {
+		sleepMs   int
+		cost      int64
+		allowed   bool
+		remaining int64
+		// resetAfterMs 和 retryAfterMs 与执行时间有关，检查范围 [min, max]
+		reset [2]int64
+		retry [2]int64
+	}
+	tests := []struct {
+		algorithm string
+		steps     []step
+	}{
+		{"fixed", []step{
+			{0, 1, true, 2, [2]int64{290, 300}, [2]int64{0, 0}},
+			{0, 2, true, 0, [2]int64{1, 300}, [2]int64{0, 0}},
+			{0, 1, false, 0, [2]int64{1, 300}, [2]int64{1, 300}},
+			{0, 5, false, 0, [2]int64{1, 300}, [2]int64{-1, -1}},
+			{350, 1, true, 2, [2]int64{290, 300}, [2]int64{0, 0}},
+		}},
+		{"sliding", []step{
+			{0, 1, true, 2, [2]int64{290, 300}, [2]int64{0, 0}},
+			{100, 2, true, 0, [2]int64{100, 200}, [2]int64{0, 0}},
+			{0, 1, false, 0, [2]int64{100, 200}, [2]int64{100, 200}},
+			{0, 3, false, 0, [2]int64{100, 200}, [2]int64{200, 300}},
+			{250, 1, true, 0, [2]int64{1, 100}, [2]int64{0, 0}},
+		}},
+		{"tokenBucket", []step{
+			{0, 3, true, 0, [2]int64{290, 300}, [2]int64{0, 0}},
+			{0, 1, false, 0, [2]int64{250, 300}, [2]int64{60, 100}},
+			{0, 4, false, 0, [2]int64{250, 300}, [2]int64{-1, -1}},
+			{150, 1, true, 0, [2]int64{150, 250}, [2]int64{0, 0}},
+		}},
+	}
+	for _, test := range tests {
+		rd := newTestRedis(t, "")
+		for i, s := range test.steps {
+			time.Sleep(time.Duration(s.sleepMs) * time.Millisecond)
+			result, err := rd.RateLimit("rate:"+test.algorithm, map[string]interface{}{"limit": 3, "windowMs": 300, "algorithm": test.algorithm, "cost": s.cost})
+			if err != nil || result.Allowed != s.allowed || result.Remaining != s.remaining ||
+				result.ResetAfterMs < s.reset[0] || result.ResetAfterMs > s.reset[1] ||
+				result.RetryAfterMs < s.retry[0] || result.RetryAfterMs > s.retry[1] {
+				t.Errorf("%s step %d: %+v %v, expect %+v", test.algorithm, i, result, err, s)
+			}
+		}
+	}
+}
+
+func TestRateLimitOptions(t *testing.T) {
+	rd := newTestRedis(t, "")
+	if _, err := rd.RateLimit("rate", map[string]interface{}{"limit": 3}); err == nil {
+		t.Error("missing windowMs should fail")
+	}
+	if _, err := rd.RateLimit("rate", map[string]interface{}{"limit": 3, "windowMs": 1000, "algorithm": "leaky"}); err == nil {
+		t.Error("unknown algorithm should fail")
+	}
+}
@@ -1,19 +1,28 @@
 package redis
 
 import (
-	"encoding/json"
 	"github.com/api-go/plugin"
 	"github.com/ssgo/log"
 	"github.com/ssgo/redis"
 	"github.com/ssgo/u"
+	"net/url"
+	"strings"
+	"sync"
 )
 
 type Redis struct {
-	pool *redis.Redis
+	keyPrefix
+	valueCodec
+	pool     *redis.Redis
+	subs     []*subscription
+	subsLock sync.Mutex
 }
 
 var redisPool = map[string]*redis.Redis{}
+var redisOptions = map[string]connOption{}
 var defaultRedis *redis.Redis
+var defaultOption = connOption{valueCodec: codecJsonAuto}
+var keysUseScan = false
 
 func init() {
 	plugin.Register(plugin.Plugin{
@@ -22,26 +31,37 @@ func init() {
 		ConfigSample: `default: redis://:<**encrypted_password**>@127.0.0.1:6379/1?timeout=10s&logSlow=100ms # set default redis connection pool, used by redis.xxx
 configs:
   conn1: redis://127.0.0.1:6379/12 # set a named connection pool, used by redis.get('conn1').xxx
+  conn2: redis://127.0.0.1:6379/12?prefix=app2: # set a key prefix, all keys and channels used by redis.get('conn2').xxx will be prefixed
+  conn3: redis://127.0.0.1:6379/12?codec=json-strict # set the value codec: json-auto (default), raw, json-strict or msgpack
+  conn4: redis-sentinel://:<**encrypted_password**>@127.0.0.1:26379,127.0.0.1:26380/mymaster/1?sentinelPassword= # find the master by sentinels and follow failovers
+  conn5: redis-cluster://:<**encrypted_password**>@127.0.0.1:7000,127.0.0.1:7001 # use redis cluster with seed nodes, keys, scan and dbsize cover all masters, multi-key commands need a {hash tag}, key events are not supported
+  conn6: memory://test/0 # use an in-process implementation for tests, same name shares data, supports strings, hashes, lists, sets, sorted sets, bitmaps, hyperloglog (exact count), expiry, transactions and pub/sub, lua only for the built-in lock, session, rateLimit and queue scripts, streams, geo and bitField return an error
+keysUseScan: false # use SCAN instead of KEYS in redis.keys to avoid blocking the server on large keyspaces
 `,
 		Init: func(conf map[string]interface{}) {
+			keysUseScan = u.Bool(conf["keysUseScan"])
 			if conf["default"] != nil {
-				defaultRedis = redis.GetRedis(u.String(conf["default"]), nil)
+				defaultRedis = makeRedisPool(u.String(conf["default"]))
+				defaultOption = parseConnOption(u.String(conf["default"]))
 			}
 			if conf["configs"] != nil {
 				confs := map[string]string{}
 				u.Convert(conf["configs"], &confs)
 				for name, url := range confs {
-					redisPool[name] = redis.GetRedis(url, nil)
+					redisPool[name] = makeRedisPool(url)
+					redisOptions[name] = parseConnOption(url)
 				}
 			}
 		},
 		Objects: map[string]interface{}{
 			"fetch": GetRedis,
+			"stats": Stats,
 		},
 		// 实现直接使用redis.xxx操作默认的Redis
 		JsCode: `let _redis = redis
 redis = _redis.fetch()
 redis.fetch = _redis.fetch
+redis.stats = _redis.stats
 `,
 	})
 }
@@ -52,42 +72,91 @@ redis.fetch = _redis.fetch
 func GetRedis(name *string, logger *log.Logger) *Redis {
 	if name == nil || *name == "" {
 		if defaultRedis != nil {
-			return &Redis{pool: defaultRedis.CopyByLogger(logger)}
+			return newRedis(defaultRedis.CopyByLogger(logger), defaultOption)
 		}
 	} else {
 		if redisPool[*name] != nil {
-			return &Redis{pool: redisPool[*name].CopyByLogger(logger)}
+			return newRedis(redisPool[*name].CopyByLogger(logger), redisOptions[*name])
 		} else if defaultRedis != nil {
-			return &Redis{pool: defaultRedis.CopyByLogger(logger)}
+			return newRedis(defaultRedis.CopyByLogger(logger), defaultOption)
 		}
 	}
-	return &Redis{
-		pool: redis.GetRedis("", logger),
+	return newRedis(redis.GetRedis("", logger), connOption{valueCodec: codecJsonAuto})
+}
+
+// connOption 连接地址中 redis 连接池以外的配置，例如：?prefix=app2:&codec=raw
+type connOption struct {
+	keyPrefix
+	valueCodec
+}
+
+func parseConnOption(redisUrl string) connOption {
+	opt := connOption{valueCodec: codecJsonAuto}
+	if urlInfo, err := url.Parse(redisUrl); err == nil {
+		query := urlInfo.Query()
+		opt.keyPrefix = keyPrefix(query.Get("prefix"))
+		opt.valueCodec = makeValueCodec(query.Get("codec"))
 	}
+	return opt
 }
 
-func makeRedisResult(r *redis.Result) interface{} {
-	var v interface{}
-	buf := r.Bytes()
-	if json.Unmarshal(buf, &v) == nil {
-		return v
-	} else {
-		return string(buf)
+func newRedis(pool *redis.Redis, opt connOption) *Redis {
+	return &Redis{pool: pool, keyPrefix: opt.keyPrefix, valueCodec: opt.valueCodec}
+}
+
+// keyPrefix 连接配置中的Key前缀，多个应用共用一个Redis数据库时用来隔离Key和频道
+type keyPrefix string
+
+func (p keyPrefix) makeKey(key string) string {
+	return string(p) + key
+}
+
+func (p keyPrefix) makeKeys(keys []string) []interface{} {
+	return u.ToInterfaceArray(p.prefixKeys(keys))
+}
+
+func (p keyPrefix) prefixKeys(keys []string) []string {
+	if p == "" {
+		return keys
 	}
+	out := make([]string, len(keys))
+	for i, key := range keys {
+		out[i] = string(p) + key
+	}
+	return out
 }
 
-func makeRedisResults(rr *redis.Result) []interface{} {
+func (p keyPrefix) trimKey(key string) string {
+	return strings.TrimPrefix(key, string(p))
+}
+
+func (p keyPrefix) trimKeys(keys []string) []string {
+	if p == "" {
+		return keys
+	}
+	out := make([]string, len(keys))
+	for i, key := range keys {
+		out[i] = strings.TrimPrefix(key, string(p))
+	}
+	return out
+}
+
+func (c valueCodec) makeRedisResult(r *redis.Result) interface{} {
+	return c.decodeBytes(r.Bytes())
+}
+
+func (c valueCodec) makeRedisResults(rr *redis.Result) []interface{} {
 	out := make([]interface{}, 0)
 	for _, r := range rr.Results() {
-		out = append(out, makeRedisResult(&r))
+		out = append(out, c.makeRedisResult(&r))
 	}
 	return out
 }
 
-func makeRedisResultMap(rr *redis.Result) map[string]interface{} {
+func (c valueCodec) makeRedisResultMap(rr *redis.Result) map[string]interface{} {
 	out := map[string]interface{}{}
 	for k, r := range rr.ResultMap() {
-		out[k] = makeRedisResult(r)
+		out[k] = c.makeRedisResult(r)
 	}
 	return out
 }
@@ -104,206 +173,229 @@ func (rd *Redis) Do(cmd string, values ...interface{}) string {
 // Del keys 传入一个或多个Key
 // Del return 成功删除的个数
 func (rd *Redis) Del(keys ...string) int {
-	return rd.pool.Do("DEL", u.ToInterfaceArray(keys)...).Int()
+	return rd.pool.Do("DEL", rd.makeKeys(keys)...).Int()
 }
 
 // Exists 判断是否Key存在
 // * key 指定一个Key
 // Exists return 是否存在
 func (rd *Redis) Exists(key string) bool {
-	return rd.pool.Do("EXISTS " + key).Bool()
+	return rd.pool.Do("EXISTS " + rd.makeKey(key)).Bool()
 }
 
 // Expire 设置Key的过期时间
 // * seconds 过期时间的秒数
 // Expire return 是否成功
 func (rd *Redis) Expire(key string, seconds int) bool {
-	return rd.pool.Do("EXPIRE "+key, seconds).Bool()
+	return rd.pool.Do("EXPIRE "+rd.makeKey(key), seconds).Bool()
 }
 
 // ExpireAt 设置Key的过期时间（指定具体时间）
 // ExpireAt time 过期时间的时间戳，单位秒
 // ExpireAt return 是否成功
 func (rd *Redis) ExpireAt(key string, time int) bool {
-	return rd.pool.Do("EXPIREAT "+key, time).Bool()
+	return rd.pool.Do("EXPIREAT "+rd.makeKey(key), time).Bool()
 }
 
-// Keys 查询Key
+// Keys 查询Key，配置了 keysUseScan 时使用 SCAN 迭代查询
 // * patten 查询条件，例如："SESS_*"
 // * return []string 查询到的Key列表
 func (rd *Redis) Keys(patten string) []string {
-	return rd.pool.Do("KEYS " + patten).Strings()
+	if keysUseScan {
+		return rd.ScanAll(patten, nil)
+	}
+	return rd.trimKeys(rd.pool.Do("KEYS " + rd.makeKey(patten)).Strings())
 }
 
 // Get 读取Key的内容
 // * return any 如果是一个对象则返回反序列化后的对象，否则返回字符串
 func (rd *Redis) Get(key string) interface{} {
-	return makeRedisResult(rd.pool.Do("GET " + key))
+	return rd.makeRedisResult(rd.pool.Do("GET " + rd.makeKey(key)))
 }
 
-// GetEX 读取Key的内容并更新过期时间
-func (rd *Redis) GetEX(key string, seconds int) interface{} {
-	r := rd.pool.Do("GET " + key)
-	if r.String() != "" {
-		rd.pool.EXPIRE(key, seconds)
-	}
-	return makeRedisResult(r)
+// GetString 读取Key的原始内容，不按照编码方式反序列化
+// GetString return 字符串，Key不存在时返回空字符串
+func (rd *Redis) GetString(key string) string {
+	return rd.pool.Do("GET " + rd.makeKey(key)).String()
+}
+
+// GetBytes 读取Key的原始二进制内容，不按照编码方式反序列化
+func (rd *Redis) GetBytes(key string) []byte {
+	return rd.pool.Do("GET " + rd.makeKey(key)).Bytes()
 }
 
 // Set 存储内容到Key
 // * value 对象或字符串
 // * return bool 是否成功
 func (rd *Redis) Set(key string, value interface{}) bool {
-	return rd.pool.Do("SET "+key, value).Bool()
+	return rd.pool.Do("SET "+rd.makeKey(key), rd.encodeValue(value)).Bool()
 }
 // SetEX 存储内容到Key并设置过期时间
 func (rd *Redis) SetEX(key string, seconds int, value interface{}) bool {
-	return rd.pool.Do("SETEX "+key, seconds, value).Bool()
+	return rd.pool.Do("SETEX "+rd.makeKey(key), seconds, rd.encodeValue(value)).Bool()
 }
 // SetNX 存储内容到一个不存在的Key，如果Key已经存在则设置失败
 func (rd *Redis) SetNX(key string, value interface{}) bool {
-	return rd.pool.Do("SETNX "+key, value).Bool()
+	return rd.pool.Do("SETNX "+rd.makeKey(key), rd.encodeValue(value)).Bool()
 }
 // GetSet 将给定 key 的值设为 value ，并返回 key 的旧值(old value)
 func (rd *Redis) GetSet(key string, value interface{}) interface{} {
-	return makeRedisResult(rd.pool.Do("GETSET "+key, value))
+	return rd.makeRedisResult(rd.pool.Do("GETSET "+rd.makeKey(key), rd.encodeValue(value)))
 }
 
 // Incr 将 key 中储存的数值增一
 // Incr * int64 最新的计数
 func (rd *Redis) Incr(key string) int64 {
-	return rd.pool.Do("INCR " + key).Int64()
+	return rd.pool.Do("INCR " + rd.makeKey(key)).Int64()
 }
 // Decr 将 key 中储存的数值减一
 func (rd *Redis) Decr(key string) int64 {
-	return rd.pool.Do("DECR " + key).Int64()
+	return rd.pool.Do("DECR " + rd.makeKey(key)).Int64()
 }
 // IncrBy 将 key 中储存的数值加上增量 increment
 func (rd *Redis) IncrBy(key string, increment int64) int64 {
-	return rd.pool.Do("INCRBY " + key, increment).Int64()
+	return rd.pool.Do("INCRBY "+rd.makeKey(key), increment).Int64()
 }
 // DecrBy 将 key 中储存的数值减去加上增量 increment
 func (rd *Redis) DecrBy(key string, increment int64) int64 {
-	return rd.pool.Do("DECRBY " + key, increment).Int64()
+	return rd.pool.Do("DECRBY "+rd.makeKey(key), increment).Int64()
 }
 
 // MGet 获取所有(一个或多个)给定 key 的值
 // MGet return []any 按照查询key的顺序返回结果，如果结果是一个对象则返回反序列化后的对象，否则返回字符串
 func (rd *Redis) MGet(keys ...string) []interface{} {
-	return makeRedisResults(rd.pool.Do("MGET", u.ToInterfaceArray(keys)...))
+	return rd.makeRedisResults(rd.pool.Do("MGET", rd.makeKeys(keys)...))
+}
+// MGetString 获取所有(一个或多个)给定 key 的原始内容，不按照编码方式反序列化
+func (rd *Redis) MGetString(keys ...string) []string {
+	return rd.pool.Do("MGET", rd.makeKeys(keys)...).Strings()
 }
 // MSet 同时设置一个或多个 key-value 对
 // MSet keyAndValues 按照key-value的顺序依次传入一个或多个数据
 func (rd *Redis) MSet(keyAndValues ...interface{}) bool {
-	return rd.pool.Do("MSET", keyAndValues...).Bool()
+	args := make([]interface{}, len(keyAndValues))
+	for i, v := range keyAndValues {
+		if i%2 == 0 {
+			v = rd.makeKey(u.String(v))
+		} else {
+			v = rd.encodeValue(v)
+		}
+		args[i] = v
+	}
+	return rd.pool.Do("MSET", args...).Bool()
 }
 
 // HGet 获取存储在哈希表中指定字段的值
 // * field 字段
 func (rd *Redis) HGet(key, field string) interface{} {
-	return makeRedisResult(rd.pool.Do("HGET "+key, field))
+	return rd.makeRedisResult(rd.pool.Do("HGET "+rd.makeKey(key), field))
+}
+// HGetString 获取存储在哈希表中指定字段的原始内容，不按照编码方式反序列化
+func (rd *Redis) HGetString(key, field string) string {
+	return rd.pool.Do("HGET "+rd.makeKey(key), field).String()
+}
+// HGetBytes 获取存储在哈希表中指定字段的原始二进制内容，不按照编码方式反序列化
+func (rd *Redis) HGetBytes(key, field string) []byte {
+	return rd.pool.Do("HGET "+rd.makeKey(key), field).Bytes()
 }
 // HSet 将哈希表 key 中的字段 field 的值设为 value
 func (rd *Redis) HSet(key, field string, value interface{}) bool {
-	return rd.pool.Do("HSET "+key, field, value).Bool()
+	return rd.pool.Do("HSET "+rd.makeKey(key), field, rd.encodeValue(value)).Bool()
 }
 
 // HSetNX 只有在字段 field 不存在时，设置哈希表字段的值
 func (rd *Redis) HSetNX(key, field string, value interface{}) bool {
-	return rd.pool.Do("HSETNX "+key, field, value).Bool()
+	return rd.pool.Do("HSETNX "+rd.makeKey(key), field, rd.encodeValue(value)).Bool()
 }
 // HMGet 获取所有给定字段的值
 // * fields 字段列表
 func (rd *Redis) HMGet(key string, fields ...string) []interface{} {
-	return makeRedisResults(rd.pool.Do("HMGET", append(append([]interface{}{}, key), u.ToInterfaceArray(fields)...)...))
+	return rd.makeRedisResults(rd.pool.Do("HMGET", append(append([]interface{}{}, rd.makeKey(key)), u.ToInterfaceArray(fields)...)...))
 }
 // HGetAll 获取在哈希表中指定 key 的所有字段和值
 // HGetAll return 返回所有字段的值，如果值是一个对象则返回反序列化后的对象，否则返回字符串
 func (rd *Redis) HGetAll(key string) map[string]interface{} {
-	return makeRedisResultMap(rd.pool.Do("HGETALL " + key))
+	return rd.makeRedisResultMap(rd.pool.Do("HGETALL " + rd.makeKey(key)))
+}
+// HGetAllString 获取在哈希表中指定 key 的所有字段和原始内容，不按照编码方式反序列化
+func (rd *Redis) HGetAllString(key string) map[string]string {
+	return rd.pool.Do("HGETALL " + rd.makeKey(key)).StringMap()
 }
 // HMSet 将哈希表 key 中的字段 field 的值设为 value
 func (rd *Redis) HMSet(key string, fieldAndValues ...interface{}) bool {
-	return rd.pool.Do("HMSET", append(append([]interface{}{}, key), fieldAndValues...)...).Bool()
+	return rd.pool.Do("HMSET", append(append([]interface{}{}, rd.makeKey(key)), rd.encodePairs(fieldAndValues)...)...).Bool()
 }
 // HKeys 获取所有哈希表中的字段
-func (rd *Redis) HKeys(patten string) []string {
-	return rd.pool.Do("HKEYS " + patten).Strings()
+func (rd *Redis) HKeys(key string) []string {
+	return rd.pool.Do("HKEYS " + rd.makeKey(key)).Strings()
 }
 // HLen 获取哈希表中字段的数量
 // HLen return 字段数量
 func (rd *Redis) HLen(key string) int {
-	return rd.pool.Do("HLEN " + key).Int()
+	return rd.pool.Do("HLEN " + rd.makeKey(key)).Int()
 }
 // HDel 删除一个或多个哈希表字段
 // HDel return 成功删除的个数
 func (rd *Redis) HDel(key string, fields ...string) int {
-	return rd.pool.Do("HDEL", append(append([]interface{}{}, key), u.ToInterfaceArray(fields)...)...).Int()
+	return rd.pool.Do("HDEL", append(append([]interface{}{}, rd.makeKey(key)), u.ToInterfaceArray(fields)...)...).Int()
 }
 // HExists 查看哈希表 key 中，指定的字段是否存在
 func (rd *Redis) HExists(key, field string) bool {
-	return rd.pool.Do("HEXISTS "+key, field).Bool()
+	return rd.pool.Do("HEXISTS "+rd.makeKey(key), field).Bool()
 }
 // HIncr 为哈希表 key 中的指定字段的整数值加上增量1
 func (rd *Redis) HIncr(key, field string) int64 {
-	return rd.pool.Do("HINCRBY "+key, field, 1).Int64()
+	return rd.pool.Do("HINCRBY "+rd.makeKey(key), field, 1).Int64()
 }
 // HDecr 为哈希表 key 中的指定字段的整数值减去增量1
 func (rd *Redis) HDecr(key, field string) int64 {
-	return rd.pool.Do("HDECRBY "+key, field, 1).Int64()
+	return rd.pool.Do("HDECRBY "+rd.makeKey(key), field, 1).Int64()
 }
 // HIncrBy 为哈希表 key 中的指定字段的整数值加上增量 increment
 func (rd *Redis) HIncrBy(key, field string, increment int64) int64 {
-	return rd.pool.Do("HINCRBY "+key, field, increment).Int64()
+	return rd.pool.Do("HINCRBY "+rd.makeKey(key), field, increment).Int64()
 }
 // HDecrBy 为哈希表 key 中的指定字段的整数值减去增量 increment
 func (rd *Redis) HDecrBy(key, field string, increment int64) int64 {
-	return rd.pool.Do("HDECRBY "+key, field, increment).Int64()
+	return rd.pool.Do("HDECRBY "+rd.makeKey(key), field, increment).Int64()
 }
 
 // LPush 将一个或多个值插入到列表头部
 // LPush return 成功添加的个数
 func (rd *Redis) LPush(key string, values ...string) int {
-	return rd.pool.Do("LPUSH", append(append([]interface{}{}, key), u.ToInterfaceArray(values)...)...).Int()
+	return rd.pool.Do("LPUSH", append(append([]interface{}{}, rd.makeKey(key)), rd.encodeValues(u.ToInterfaceArray(values))...)...).Int()
 }
 // RPush 在列表中添加一个或多个值
 // RPush return 成功添加的个数
 func (rd *Redis) RPush(key string, values ...string) int {
-	return rd.pool.Do("RPUSH", append(append([]interface{}{}, key), u.ToInterfaceArray(values)...)...).Int()
+	return rd.pool.Do("RPUSH", append(append([]interface{}{}, rd.makeKey(key)), rd.encodeValues(u.ToInterfaceArray(values))...)...).Int()
 }
 // LPop 移出并获取列表的第一个元素
 func (rd *Redis) LPop(key string) interface{} {
-	return makeRedisResult(rd.pool.Do("LPOP " + key))
+	return rd.makeRedisResult(rd.pool.Do("LPOP " + rd.makeKey(key)))
 }
 // RPop 移除并获取列表最后一个元素
 func (rd *Redis) RPop(key string) interface{} {
-	return makeRedisResult(rd.pool.Do("RPOP " + key))
+	return rd.makeRedisResult(rd.pool.Do("RPOP " + rd.makeKey(key)))
 }
 // LLen 获取列表长度
 // LLen 列表的长度
 func (rd *Redis) LLen(key string) int {
-	return rd.pool.Do("LLEN " + key).Int()
+	return rd.pool.Do("LLEN " + rd.makeKey(key)).Int()
 }
 // LRange 获取列表指定范围内的元素
 // LRange return []any 列表数据，如果值是一个对象则返回反序列化后的对象，否则返回字符串
 func (rd *Redis) LRange(key string, start, stop int) []interface{} {
-	return makeRedisResults(rd.pool.Do("LRANGE "+key, start, stop))
+	return rd.makeRedisResults(rd.pool.Do("LRANGE "+rd.makeKey(key), start, stop))
+}
+// LRangeString 获取列表指定范围内元素的原始内容，不按照编码方式反序列化
+func (rd *Redis) LRangeString(key string, start, stop int) []string {
+	return rd.pool.Do("LRANGE "+rd.makeKey(key), start, stop).Strings()
 }
-
-// TODO 支持订阅，还需要支持 Start、Stop、向Context注册析构函数确保Stop被执行，需要支持传入Function转化为func
-// Subscribe
-//func (rd *Redis) Subscribe(channel string, onReceived func([]byte)) bool {
-//	return rd.pool.Subscribe(channel, nil, onReceived)
-//}
-//
-// Unsubscribe
-//func (rd *Redis) Unsubscribe(channel string) bool {
-//	return rd.pool.Unsubscribe(channel)
-//}
 
 // Publish 将信息发送到指定的频道
 // Publish channel 渠道名称
-// Publish data 数据，字符串格式
+// Publish data 数据，字符串格式，原样发送不经过值编码，订阅者按照各自连接的编码方式读取
 func (rd *Redis) Publish(channel, data string) bool {
-	return rd.pool.Do("PUBLISH "+channel, data).Bool()
+	return rd.pool.Do("PUBLISH "+rd.makeKey(channel), data).Bool()
 }
@@ -0,0 +1,153 @@
+package redis
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ssgo/redis"
+)
+
+var testServer struct {
+	once sync.Once
+	url  string
+	cmds []*exec.Cmd
+	lock sync.Mutex
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	testServer.lock.Lock()
+	for _, cmd := range testServer.cmds {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}
+	testServer.lock.Unlock()
+	os.Exit(code)
+}
+
+// startRedisServer 在随机端口上启动一个 redis-server，测试结束时关闭，没有安装 redis-server 时返回错误
+func startRedisServer(args ...string) (string, error) {
+	bin, err := exec.LookPath("redis-server")
+	if err != nil {
+		return "", err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	dir, err := os.MkdirTemp("", "redis-test")
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command(bin, append([]string{"--port", strconv.Itoa(port), "--dir", dir, "--save", "", "--appendonly", "no"}, args...)...)
+	if err = cmd.Start(); err != nil {
+		return "", err
+	}
+	testServer.lock.Lock()
+	testServer.cmds = append(testServer.cmds, cmd)
+	testServer.lock.Unlock()
+
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			_ = conn.Close()
+			return addr, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return "", os.ErrDeadlineExceeded
+}
+
+// testServerUrl 测试使用的服务器，设置了 REDIS_TEST_URL 时使用它（测试会清空其中的数据），否则启动一个临时的 redis-server，都没有时返回空字符串
+func testServerUrl() string {
+	testServer.once.Do(func() {
+		if testServer.url = os.Getenv("REDIS_TEST_URL"); testServer.url == "" {
+			if addr, err := startRedisServer(); err == nil {
+				testServer.url = "redis://" + addr + "/15"
+			}
+		}
+	})
+	return testServer.url
+}
+
+// newTestRedis 连接测试服务器并清空数据，没有可用的服务器时跳过测试，query 为连接地址中的参数，例如 "?prefix=app:"
+func newTestRedis(t *testing.T, query string) *Redis {
+	redisUrl := testServerUrl()
+	if redisUrl == "" {
+		t.Skip("no redis server, set REDIS_TEST_URL or install redis-server")
+	}
+	rd := newRedis(redis.GetRedis(redisUrl, nil), parseConnOption(redisUrl+query))
+	if r := rd.pool.Do("FLUSHDB"); r.Error != nil {
+		t.Fatal(r.Error)
+	}
+	return rd
+}
+
+func TestKeyPrefix(t *testing.T) {
+	plain := newTestRedis(t, "")
+	rd := newTestRedis(t, "?prefix=app:")
+	plain.Set("name", "other")
+
+	rd.Set("name", "abc")
+	rd.MSet("a", 1, "b", 2)
+	rd.HSet("h", "f", "v")
+	rd.RPush("l", "x")
+	if plain.Get("app:name") != "abc" || plain.Get("name") != "other" || rd.Get("name") != "abc" {
+		t.Fatal("key not prefixed")
+	}
+	if out := rd.MGet("a", "b"); !reflect.DeepEqual(out, []interface{}{float64(1), float64(2)}) {
+		t.Error("bad mget", out)
+	}
+	keys := rd.Keys("*")
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"a", "b", "h", "l", "name"}) {
+		t.Error("bad keys", keys)
+	}
+	keys = rd.ScanAll("", nil)
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"a", "b", "h", "l", "name"}) {
+		t.Error("bad scan", keys)
+	}
+	if r := rd.BLPop([]string{"l"}, 1); r == nil || r.Key != "l" || r.Value != "x" {
+		t.Error("bad blpop", r)
+	}
+
+	p := rd.Pipeline()
+	p.Incr("a")
+	p.Get("name")
+	p.HLen("h")
+	p.Keys("na*")
+	if results, err := p.Exec(); err != nil || results[0].Result != int64(2) || results[1].Result != "abc" ||
+		results[2].Result != int64(1) || !reflect.DeepEqual(results[3].Result, []string{"name"}) {
+		t.Error("bad pipeline", results, err)
+	}
+	if out, err := rd.Eval("return redis.call('GET', KEYS[1])", []string{"name"}); err != nil || out != "abc" {
+		t.Error("bad eval", out, err)
+	}
+
+	// 频道同样添加前缀，回调收到的频道名称去掉了前缀
+	callback, messages := receiveMessages()
+	if err := rd.Subscribe(nil, []string{"news"}, callback); err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Unsubscribe()
+	for i := 0; i < 100 && numSub(plain, "app:news") != 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if numSub(plain, "app:news") != 1 {
+		t.Fatal("channel not prefixed")
+	}
+	plain.Publish("news", "ignored")
+	rd.Publish("news", `{"a":1}`)
+	waitMessage(t, messages, &testMessage{"news", map[string]interface{}{"a": float64(1)}})
+}
@@ -0,0 +1,172 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ssgo/u"
+)
+
+// rememberNilValue 缓存 loader 返回空值时写入的标记，用来区分空结果和缓存未命中
+var rememberNilValue = []byte("\x00nil")
+
+var rememberCalls = map[string]*rememberCall{}
+var rememberCallsLock sync.Mutex
+
+// rememberCall 进程内正在执行的一次加载，同一个Key的并发调用等待它完成
+type rememberCall struct {
+	done  chan bool
+	value interface{}
+	err   interface{}
+}
+
+type rememberOption struct {
+	LockTtl     int
+	StaleTtl    int
+	NegativeTtl *int
+}
+
+// Remember 读取缓存，缓存不存在时调用 loader 加载并写入缓存
+// Remember 进程内同一个Key的并发加载只会执行一次，多个进程之间使用一个短时间的Redis锁保证只有一个调用者加载
+// Remember ttl 缓存的过期秒数
+// Remember loader 加载数据的函数，返回null时作为空结果缓存
+// Remember options 选项 {lockTtl, staleTtl, negativeTtl}，lockTtl 为加载锁的毫秒数（默认5000），staleTtl 为过期后仍然可以返回旧值的秒数，在此期间只有一个调用者重新加载，其他调用者直接返回旧值，negativeTtl 为空结果的缓存秒数（默认为ttl的1/10）
+// Remember return 缓存或加载的数据
+func (rd *Redis) Remember(key string, ttl int, loader func() interface{}, options *map[string]interface{}) interface{} {
+	opt := rememberOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	if opt.LockTtl <= 0 {
+		opt.LockTtl = 5000
+	}
+	if opt.NegativeTtl == nil {
+		negativeTtl := ttl / 10
+		if negativeTtl < 1 {
+			negativeTtl = 1
+		}
+		opt.NegativeTtl = &negativeTtl
+	}
+
+	value, found, fresh := rd.readRemembered(key, opt)
+	if found && fresh {
+		return value
+	}
+	if found {
+		// 旧值仍然可用，只有获得锁的调用者重新加载，其他调用者直接返回旧值
+		if token := rd.lockRemember(key, opt); token != "" {
+			defer rd.unlockRemember(key, token)
+			return rd.loadRemember(key, ttl, loader, opt)
+		}
+		return value
+	}
+
+	callKey := fmt.Sprint(rd.pool.Config.Host, "/", rd.pool.Config.DB, "/", rd.makeKey(key))
+	rememberCallsLock.Lock()
+	if call := rememberCalls[callKey]; call != nil {
+		rememberCallsLock.Unlock()
+		<-call.done
+		if call.err != nil {
+			panic(call.err)
+		}
+		// 重新从缓存读取，避免多个调用者共用同一个对象
+		if value, found, _ := rd.readRemembered(key, opt); found {
+			return value
+		}
+		return call.value
+	}
+	call := &rememberCall{done: make(chan bool)}
+	rememberCalls[callKey] = call
+	rememberCallsLock.Unlock()
+
+	defer func() {
+		if err := recover(); err != nil {
+			call.err = err
+		}
+		rememberCallsLock.Lock()
+		delete(rememberCalls, callKey)
+		rememberCallsLock.Unlock()
+		close(call.done)
+		if call.err != nil {
+			panic(call.err)
+		}
+	}()
+	call.value = rd.rememberWithLock(key, ttl, loader, opt)
+	return call.value
+}
+
+// rememberWithLock 获取Redis锁后加载，其他进程持有锁时等待它写入缓存，锁过期后仍然没有结果则自行加载
+func (rd *Redis) rememberWithLock(key string, ttl int, loader func() interface{}, opt rememberOption) interface{} {
+	deadline := time.Now().Add(time.Duration(opt.LockTtl) * time.Millisecond)
+	for {
+		if token := rd.lockRemember(key, opt); token != "" {
+			defer rd.unlockRemember(key, token)
+			// 等待锁的过程中其他进程可能已经写入了缓存
+			if value, found, _ := rd.readRemembered(key, opt); found {
+				return value
+			}
+			return rd.loadRemember(key, ttl, loader, opt)
+		}
+		if !time.Now().Before(deadline) {
+			return rd.loadRemember(key, ttl, loader, opt)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if value, found, _ := rd.readRemembered(key, opt); found {
+			return value
+		}
+	}
+}
+
+// readRemembered 读取缓存的值，返回值、是否存在、是否在有效期内
+// 缓存的过期时间为 ttl+staleTtl，剩余时间不超过 staleTtl 时说明已经过了有效期，只使用一个Key避免在集群中跨槽位
+func (rd *Redis) readRemembered(key string, opt rememberOption) (interface{}, bool, bool) {
+	var reply interface{}
+	fresh := true
+	if opt.StaleTtl > 0 {
+		p := rd.Pipeline()
+		p.add(decodeRaw, "GET", rd.makeKey(key))
+		p.add(decodeInt, "PTTL", rd.makeKey(key))
+		results, err := p.Exec()
+		if err != nil || results[0].Error != "" {
+			return nil, false, false
+		}
+		reply = results[0].Result
+		fresh = u.Int64(results[1].Result) > int64(opt.StaleTtl)*1000
+	} else {
+		reply, _ = rd.doRaw("GET", rd.makeKey(key))
+	}
+	buf, ok := reply.([]byte)
+	if !ok {
+		return nil, false, false
+	}
+	if bytes.Equal(buf, rememberNilValue) {
+		return nil, true, fresh
+	}
+	return rd.decodeBytes(buf), true, fresh
+}
+
+func (rd *Redis) loadRemember(key string, ttl int, loader func() interface{}, opt rememberOption) interface{} {
+	value := loader()
+	if value == nil {
+		rd.SetEX(key, *opt.NegativeTtl+opt.StaleTtl, rememberNilValue)
+	} else {
+		rd.SetEX(key, ttl+opt.StaleTtl, value)
+	}
+	return value
+}
+
+func (rd *Redis) lockRemember(key string, opt rememberOption) string {
+	token := hex.EncodeToString(u.MakeToken(16))
+	reply, err := rd.doRaw("SET", rd.makeKey(key+":lock"), token, "NX", "PX", opt.LockTtl)
+	if err != nil || reply == nil {
+		return ""
+	}
+	return token
+}
+
+func (rd *Redis) unlockRemember(key, token string) {
+	_, _ = rd.evalScript(lockReleaseScript, []string{rd.makeKey(key + ":lock")}, token)
+}
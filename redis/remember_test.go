@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// expireNow 使Key立即过期，模拟缓存到期
+func expireNow(rd *Redis, key string) {
+	rd.PExpire(key, 1)
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestRemember(t *testing.T) {
+	rd := newTestRedis(t, "?prefix=app:")
+	var calls int32
+	loader := func() interface{} {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return map[string]interface{}{"n": float64(atomic.LoadInt32(&calls))}
+	}
+
+	// 并发调用只加载一次
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rd.Remember("user:1", 10, loader, nil)
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatal("loader called", calls)
+	}
+	if v, _ := rd.Remember("user:1", 10, loader, nil).(map[string]interface{}); v["n"] != float64(1) || calls != 1 {
+		t.Fatal("cache miss", v)
+	}
+	if !ttlBetween(rd, "user:1", 9000, 10000) {
+		t.Fatal("bad ttl", rd.PTTL("user:1"))
+	}
+
+	expireNow(rd, "user:1")
+	if v, _ := rd.Remember("user:1", 10, loader, nil).(map[string]interface{}); v["n"] != float64(2) {
+		t.Fatal("expired value returned", v)
+	}
+}
+
+func TestRememberNil(t *testing.T) {
+	rd := newTestRedis(t, "")
+	calls := 0
+	loader := func() interface{} {
+		calls++
+		return nil
+	}
+	options := &map[string]interface{}{"negativeTtl": 2}
+	if rd.Remember("none", 60, loader, options) != nil || rd.Remember("none", 60, loader, options) != nil || calls != 1 {
+		t.Fatal("nil result not cached", calls)
+	}
+	if !ttlBetween(rd, "none", 1000, 2000) {
+		t.Fatal("bad negative ttl", rd.PTTL("none"))
+	}
+	expireNow(rd, "none")
+	rd.Remember("none", 60, loader, options)
+	if calls != 2 {
+		t.Fatal("nil result cached too long", calls)
+	}
+}
+
+func TestRememberStale(t *testing.T) {
+	rd := newTestRedis(t, "?prefix=app:")
+	value := "v1"
+	loader := func() interface{} { return value }
+	options := &map[string]interface{}{"staleTtl": 5}
+
+	rd.Remember("k", 10, loader, options)
+	if !ttlBetween(rd, "k", 14000, 15000) {
+		t.Fatal("bad ttl", rd.PTTL("k"))
+	}
+
+	// 剩余时间不超过 staleTtl 时过了有效期，其他调用者正在加载时直接返回旧值
+	value = "v2"
+	rd.PExpire("k", 4000)
+	rd.Set("k:lock", "other")
+	if v := rd.Remember("k", 10, loader, options); v != "v1" {
+		t.Fatal("stale value not returned", v)
+	}
+	rd.Del("k:lock")
+	if v := rd.Remember("k", 10, loader, options); v != "v2" || !ttlBetween(rd, "k", 14000, 15000) {
+		t.Fatal("stale value not reloaded", v)
+	}
+	value = "v3"
+	if v := rd.Remember("k", 10, loader, options); v != "v2" {
+		t.Fatal("fresh value reloaded", v)
+	}
+
+	// 超过 staleTtl 后缓存不存在
+	expireNow(rd, "k")
+	if v := rd.Remember("k", 10, loader, options); v != "v3" {
+		t.Fatal("expired value returned", v)
+	}
+}
+
+func TestRememberPanic(t *testing.T) {
+	rd := newTestRedis(t, "")
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("loader panic not propagated")
+			}
+		}()
+		rd.Remember("k", 10, func() interface{} { panic("failed") }, nil)
+	}()
+	// 失败的加载不会写入缓存，也不会留下加载锁
+	if rd.Exists("k") || rd.Exists("k:lock") {
+		t.Fatal("failed load left keys")
+	}
+	if v := rd.Remember("k", 10, func() interface{} { return "ok" }, nil); v != "ok" {
+		t.Fatal("bad value after panic", v)
+	}
+}
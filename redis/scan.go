@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"github.com/ssgo/u"
+)
+
+type scanOption struct {
+	Count int
+	Type  string
+}
+
+// Scan 使用游标迭代匹配的Key，不会像 KEYS 一样阻塞服务器
+// Scan pattern 查询条件，例如："SESS_*"
+// Scan options 选项 {count, type}，count 为每次迭代的建议数量，type 为Key的类型（string、hash、list、set、zset、stream）
+// Scan callback 每次迭代得到一批Key时的回调函数，返回false时停止迭代
+func (rd *Redis) Scan(pattern string, options *map[string]interface{}, callback func([]string) interface{}) {
+	rd.scan("SCAN", nil, pattern, options, func(reply interface{}) bool {
+		return callback(rd.trimKeys(replyStrings(reply))) != false
+	})
+}
+
+// ScanAll 使用游标迭代获取所有匹配的Key
+// ScanAll return 查询到的Key列表
+func (rd *Redis) ScanAll(pattern string, options *map[string]interface{}) []string {
+	out := make([]string, 0)
+	rd.scan("SCAN", nil, pattern, options, func(reply interface{}) bool {
+		out = append(out, rd.trimKeys(replyStrings(reply))...)
+		return true
+	})
+	return out
+}
+
+// HScan 使用游标迭代哈希表中的字段
+// HScan pattern 字段的查询条件
+// HScan options 选项 {count}
+// HScan callback 每次迭代得到一批字段和值时的回调函数，返回false时停止迭代
+func (rd *Redis) HScan(key, pattern string, options *map[string]interface{}, callback func(map[string]interface{}) interface{}) {
+	rd.scan("HSCAN", &key, pattern, options, func(reply interface{}) bool {
+		return callback(rd.replyMap(reply)) != false
+	})
+}
+
+// SScan 使用游标迭代集合中的成员
+// SScan callback 每次迭代得到一批成员时的回调函数，返回false时停止迭代
+func (rd *Redis) SScan(key, pattern string, options *map[string]interface{}, callback func([]interface{}) interface{}) {
+	rd.scan("SSCAN", &key, pattern, options, func(reply interface{}) bool {
+		return callback(rd.replyValues(reply)) != false
+	})
+}
+
+// ZScan 使用游标迭代有序集合中的成员
+// ZScan callback 每次迭代得到一批 {member, score} 时的回调函数，返回false时停止迭代
+func (rd *Redis) ZScan(key, pattern string, options *map[string]interface{}, callback func([]ZMember) interface{}) {
+	rd.scan("ZSCAN", &key, pattern, options, func(reply interface{}) bool {
+		return callback(rd.makeZMembers(reply)) != false
+	})
+}
+
+func (rd *Redis) scan(cmd string, key *string, pattern string, options *map[string]interface{}, onBatch func(interface{}) bool) {
+	opt := scanOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	if key == nil {
+		// 有前缀时需要通过 MATCH 只返回当前前缀下的Key
+		if pattern == "" {
+			pattern = "*"
+		}
+		pattern = rd.makeKey(pattern)
+	} else {
+		prefixedKey := rd.makeKey(*key)
+		key = &prefixedKey
+	}
+	cursor := "0"
+	for {
+		args := make([]interface{}, 0, 7)
+		if key != nil {
+			args = append(args, *key)
+		}
+		args = append(args, cursor)
+		if pattern != "" && pattern != "*" {
+			args = append(args, "MATCH", pattern)
+		}
+		if opt.Count > 0 {
+			args = append(args, "COUNT", opt.Count)
+		}
+		if opt.Type != "" && key == nil {
+			args = append(args, "TYPE", opt.Type)
+		}
+		reply, err := rd.doRaw(cmd, args...)
+		arr, _ := reply.([]interface{})
+		if err != nil || len(arr) != 2 {
+			return
+		}
+		cursor = replyString(arr[0])
+		if batch, _ := arr[1].([]interface{}); len(batch) > 0 && !onBatch(batch) {
+			return
+		}
+		if cursor == "0" {
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	rd := newTestRedis(t, "")
+	for i := 0; i < 30; i++ {
+		rd.Set("user:"+strconv.Itoa(i), i)
+	}
+	rd.HSet("user:h", "a", 1)
+	rd.Set("other", 1)
+
+	keys := rd.ScanAll("user:*", &map[string]interface{}{"count": 5})
+	if len(keys) != 31 {
+		t.Fatal("bad scan all", len(keys))
+	}
+	if keys = rd.ScanAll("user:*", &map[string]interface{}{"type": "hash"}); !reflect.DeepEqual(keys, []string{"user:h"}) {
+		t.Fatal("bad scan by type", keys)
+	}
+
+	// 回调返回false时停止迭代
+	batches := 0
+	rd.Scan("*", &map[string]interface{}{"count": 2}, func(keys []string) interface{} {
+		batches++
+		return false
+	})
+	if batches != 1 {
+		t.Fatal("scan not stopped", batches)
+	}
+
+	keysUseScan = true
+	keys = rd.Keys("user:1*")
+	keysUseScan = false
+	expect := rd.Keys("user:1*")
+	sort.Strings(keys)
+	sort.Strings(expect)
+	if !reflect.DeepEqual(keys, expect) || len(keys) != 11 {
+		t.Fatal("keys with scan differs", keys, expect)
+	}
+}
+
+func TestScanMembers(t *testing.T) {
+	rd := newTestRedis(t, "")
+	rd.HMSet("h", "a1", 1, "a2", `{"x":1}`, "b", 3)
+	rd.SAdd("s", "a1", "a2", "b")
+	rd.ZAdd("z", map[string]float64{"a1": 1, "a2": 2, "b": 3}, nil)
+
+	fields := map[string]interface{}{}
+	rd.HScan("h", "a*", nil, func(batch map[string]interface{}) interface{} {
+		for k, v := range batch {
+			fields[k] = v
+		}
+		return nil
+	})
+	if !reflect.DeepEqual(fields, map[string]interface{}{"a1": float64(1), "a2": map[string]interface{}{"x": float64(1)}}) {
+		t.Error("bad hscan", fields)
+	}
+
+	members := make([]interface{}, 0)
+	rd.SScan("s", "a*", nil, func(batch []interface{}) interface{} {
+		members = append(members, batch...)
+		return true
+	})
+	if out := sortedValues(members); !reflect.DeepEqual(out, []string{"a1", "a2"}) {
+		t.Error("bad sscan", out)
+	}
+
+	zmembers := make([]ZMember, 0)
+	rd.ZScan("z", "", nil, func(batch []ZMember) interface{} {
+		zmembers = append(zmembers, batch...)
+		return nil
+	})
+	sort.Slice(zmembers, func(i, j int) bool { return zmembers[i].Score < zmembers[j].Score })
+	if !reflect.DeepEqual(zmembers, []ZMember{{"a1", 1}, {"a2", 2}, {"b", 3}}) {
+		t.Error("bad zscan", zmembers)
+	}
+}
@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+
+	"github.com/ssgo/u"
+)
+
+type luaScript struct {
+	source string
+	sha    string
+}
+
+type Script struct {
+	rd     *Redis
+	script *luaScript
+}
+
+func newLuaScript(source string) *luaScript {
+	sum := sha1.Sum([]byte(source))
+	return &luaScript{source: source, sha: hex.EncodeToString(sum[:])}
+}
+
+// Eval 执行Lua脚本
+// Eval script Lua脚本
+// Eval keys 脚本中通过 KEYS 访问的Key
+// Eval args 脚本中通过 ARGV 访问的参数
+// Eval return 脚本的返回值，Lua的table转换为数组，nil转换为null，如果是一个对象则返回反序列化后的对象
+func (rd *Redis) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	reply, err := rd.doRaw("EVAL", makeEvalArgs(script, rd.prefixKeys(keys), args)...)
+	return rd.replyValue(reply), err
+}
+
+// Script 创建可以重复执行的Lua脚本，执行时使用 EVALSHA 只发送脚本的SHA1，服务器没有缓存时自动加载
+// Script source Lua脚本
+// Script return 脚本对象，使用 run 执行
+func (rd *Redis) Script(source string) *Script {
+	return &Script{rd: rd, script: newLuaScript(source)}
+}
+
+// Run 执行脚本
+// Run keys 脚本中通过 KEYS 访问的Key
+// Run args 脚本中通过 ARGV 访问的参数
+// Run return 脚本的返回值
+func (s *Script) Run(keys []string, args ...interface{}) (interface{}, error) {
+	reply, err := s.rd.evalScript(s.script, s.rd.prefixKeys(keys), args...)
+	return s.rd.replyValue(reply), err
+}
+
+// Sha 获取脚本的SHA1
+func (s *Script) Sha() string {
+	return s.script.sha
+}
+
+// Load 将脚本预先加载到服务器
+func (s *Script) Load() error {
+	_, err := s.rd.doRaw("SCRIPT", "LOAD", s.script.source)
+	return err
+}
+
+// evalScript 使用 EVALSHA 执行脚本，服务器返回 NOSCRIPT 时加载脚本后重试，keys 需要已经添加了前缀
+func (rd *Redis) evalScript(script *luaScript, keys []string, args ...interface{}) (interface{}, error) {
+	reply, err := rd.doRaw("EVALSHA", makeEvalArgs(script.sha, keys, args)...)
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		if _, err = rd.doRaw("SCRIPT", "LOAD", script.source); err == nil {
+			reply, err = rd.doRaw("EVALSHA", makeEvalArgs(script.sha, keys, args)...)
+		}
+	}
+	return reply, err
+}
+
+func makeEvalArgs(script string, keys []string, args []interface{}) []interface{} {
+	out := make([]interface{}, 0, len(keys)+len(args)+2)
+	out = append(out, script, len(keys))
+	out = append(out, u.ToInterfaceArray(keys)...)
+	return append(out, args...)
+}
@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	rd := newTestRedis(t, "")
+	rd.Set("user", map[string]interface{}{"id": 1})
+	tests := []struct {
+		script string
+		keys   []string
+		args   []interface{}
+		expect interface{}
+	}{
+		{"return 1", nil, nil, int64(1)},
+		{"return nil", nil, nil, nil},
+		{"return ARGV[1]", nil, []interface{}{"abc"}, "abc"},
+		{"return {1, 'a', {2}}", nil, nil, []interface{}{int64(1), "a", []interface{}{int64(2)}}},
+		{"return redis.call('GET', KEYS[1])", []string{"user"}, nil, map[string]interface{}{"id": float64(1)}},
+		{"return redis.call('INCRBY', KEYS[1], ARGV[1])", []string{"n"}, []interface{}{5}, int64(5)},
+	}
+	for _, test := range tests {
+		if out, err := rd.Eval(test.script, test.keys, test.args...); err != nil || !reflect.DeepEqual(out, test.expect) {
+			t.Error(test.script, out, err)
+		}
+	}
+	if _, err := rd.Eval("return redis.call('NOCOMMAND')", nil); err == nil {
+		t.Error("script error not returned")
+	}
+}
+
+func TestScript(t *testing.T) {
+	rd := newTestRedis(t, "")
+	source := "return redis.call('INCRBY', KEYS[1], ARGV[1])"
+	s := rd.Script(source)
+	if sum := sha1.Sum([]byte(source)); s.Sha() != hex.EncodeToString(sum[:]) {
+		t.Fatal("bad sha", s.Sha())
+	}
+
+	// 服务器没有缓存脚本时自动加载
+	rd.doRaw("SCRIPT", "FLUSH")
+	if out, err := s.Run([]string{"n"}, 2); err != nil || out != int64(2) {
+		t.Fatal("run after flush failed", out, err)
+	}
+	reply, _ := rd.doRaw("SCRIPT", "EXISTS", s.Sha())
+	if exists := rd.replyValues(reply); len(exists) != 1 || exists[0] != int64(1) {
+		t.Fatal("script not loaded", exists)
+	}
+
+	rd.doRaw("SCRIPT", "FLUSH")
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := s.Run([]string{"n"}, 3); err != nil || out != int64(5) {
+		t.Fatal("run after load failed", out, err)
+	}
+}
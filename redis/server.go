@@ -0,0 +1,178 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ssgo/redis"
+)
+
+type PingResult struct {
+	Ok      bool
+	Latency float64
+	Error   string
+}
+
+type SlowlogEntry struct {
+	Id         int64
+	Time       int64
+	Duration   int64
+	Args       []string
+	Client     string
+	ClientName string
+}
+
+type PoolStats struct {
+	Active       int
+	Idle         int
+	InUse        int
+	WaitCount    int64
+	WaitDuration float64
+}
+
+// Ping 检查与服务器的连接
+// Ping return {ok, latency, error}，latency 为往返的毫秒数（包括从连接池获取连接的时间）
+func (rd *Redis) Ping() PingResult {
+	startTime := time.Now()
+	reply, err := rd.doRaw("PING")
+	result := PingResult{Latency: float64(time.Since(startTime)) / float64(time.Millisecond)}
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Ok = replyString(reply) == "PONG"
+	}
+	return result
+}
+
+// Info 获取服务器信息
+// Info section 信息分组，例如 server、memory、clients、keyspace，不指定时返回默认的分组
+// Info return 按照分组返回 {section: {name: value}}，分组名称为小写，数字会转换为数值，形如 keys=1,expires=0 的值转换为对象
+func (rd *Redis) Info(section *string) map[string]interface{} {
+	args := make([]interface{}, 0, 1)
+	if section != nil && *section != "" {
+		args = append(args, *section)
+	}
+	reply, _ := rd.doRaw("INFO", args...)
+	return parseInfo(replyString(reply))
+}
+
+func parseInfo(info string) map[string]interface{} {
+	out := map[string]interface{}{}
+	var current map[string]interface{}
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			current = map[string]interface{}{}
+			out[strings.ToLower(strings.TrimSpace(line[1:]))] = current
+			continue
+		}
+		pos := strings.IndexByte(line, ':')
+		if pos <= 0 {
+			continue
+		}
+		if current == nil {
+			current = map[string]interface{}{}
+			out["default"] = current
+		}
+		current[line[:pos]] = parseInfoValue(line[pos+1:])
+	}
+	return out
+}
+
+// parseInfoValue 转换 INFO 中的值，例如 db0 的 keys=1,expires=0,avg_ttl=0 和 cmdstat_get 的 calls=2,usec=15
+func parseInfoValue(value string) interface{} {
+	if strings.Contains(value, "=") && !strings.ContainsAny(value, " ") {
+		fields := map[string]interface{}{}
+		for _, item := range strings.Split(value, ",") {
+			if pos := strings.IndexByte(item, '='); pos > 0 {
+				fields[item[:pos]] = parseInfoNumber(item[pos+1:])
+			} else {
+				return value
+			}
+		}
+		return fields
+	}
+	return parseInfoNumber(value)
+}
+
+func parseInfoNumber(value string) interface{} {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && strings.Trim(value, "0123456789.-") == "" {
+		return f
+	}
+	return value
+}
+
+// DBSize 获取当前数据库中Key的数量，集群中为所有主节点的Key数量之和
+func (rd *Redis) DBSize() int64 {
+	reply, _ := rd.doRaw("DBSIZE")
+	return replyInt(reply)
+}
+
+// SlowlogGet 获取慢查询日志
+// SlowlogGet count 最多返回的条数，默认为10
+// SlowlogGet return [{id, time, duration, args, client, clientName}]，time 为秒级时间戳，duration 为微秒
+func (rd *Redis) SlowlogGet(count *int) []SlowlogEntry {
+	args := []interface{}{"GET"}
+	if count != nil {
+		args = append(args, *count)
+	}
+	reply, _ := rd.doRaw("SLOWLOG", args...)
+	arr, _ := reply.([]interface{})
+	out := make([]SlowlogEntry, 0, len(arr))
+	for _, v := range arr {
+		item, ok := v.([]interface{})
+		if !ok || len(item) < 4 {
+			continue
+		}
+		entry := SlowlogEntry{
+			Id:       replyInt(item[0]),
+			Time:     replyInt(item[1]),
+			Duration: replyInt(item[2]),
+			Args:     replyStrings(item[3]),
+		}
+		if len(item) >= 6 {
+			entry.Client = replyString(item[4])
+			entry.ClientName = replyString(item[5])
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// MemoryUsage 获取Key及其值占用的内存字节数
+// MemoryUsage return 字节数，Key不存在时返回0
+func (rd *Redis) MemoryUsage(key string) int64 {
+	reply, _ := rd.doRaw("MEMORY", "USAGE", rd.makeKey(key))
+	return replyInt(reply)
+}
+
+// Stats 获取所有连接池的状态，使用 redis.stats() 调用
+// Stats return {name: {active, idle, inUse, waitCount, waitDuration}}，默认连接池的名称为 default，active 包括空闲和使用中的连接，waitDuration 为等待连接的总毫秒数
+func Stats() map[string]PoolStats {
+	out := map[string]PoolStats{}
+	if defaultRedis != nil {
+		out["default"] = makePoolStats(defaultRedis)
+	}
+	for name, pool := range redisPool {
+		out[name] = makePoolStats(pool)
+	}
+	return out
+}
+
+func makePoolStats(pool *redis.Redis) PoolStats {
+	stats := pool.GetPool().Stats()
+	return PoolStats{
+		Active:       stats.ActiveCount,
+		Idle:         stats.IdleCount,
+		InUse:        stats.ActiveCount - stats.IdleCount,
+		WaitCount:    stats.WaitCount,
+		WaitDuration: float64(stats.WaitDuration) / float64(time.Millisecond),
+	}
+}
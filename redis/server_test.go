@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInfo(t *testing.T) {
+	info := "# Server\r\nredis_version:7.2.4\r\nuptime_in_seconds:10\r\n\r\n# Stats\r\ninstantaneous_input_kbps:0.12\r\n\r\n# Keyspace\r\ndb0:keys=2,expires=1,avg_ttl=0\r\n\r\n# Commandstats\r\ncmdstat_get:calls=2,usec=15,usec_per_call=7.50\r\n"
+	expect := map[string]interface{}{
+		"server":       map[string]interface{}{"redis_version": "7.2.4", "uptime_in_seconds": int64(10)},
+		"stats":        map[string]interface{}{"instantaneous_input_kbps": 0.12},
+		"keyspace":     map[string]interface{}{"db0": map[string]interface{}{"keys": int64(2), "expires": int64(1), "avg_ttl": int64(0)}},
+		"commandstats": map[string]interface{}{"cmdstat_get": map[string]interface{}{"calls": int64(2), "usec": int64(15), "usec_per_call": 7.5}},
+	}
+	if out := parseInfo(info); !reflect.DeepEqual(out, expect) {
+		t.Fatal("bad info", out)
+	}
+	if out := parseInfo("a:1\nb:x y=1\n"); !reflect.DeepEqual(out, map[string]interface{}{"default": map[string]interface{}{"a": int64(1), "b": "x y=1"}}) {
+		t.Fatal("bad info without section", out)
+	}
+}
+
+func TestServerCommands(t *testing.T) {
+	for name, makeRedis := range map[string]func(*testing.T, string) *Redis{"redis": newTestRedis, "memory": newMemoryRedis} {
+		t.Run(name, func(t *testing.T) {
+			rd := makeRedis(t, "?prefix=app:")
+			if r := rd.Ping(); !r.Ok || r.Error != "" || r.Latency <= 0 {
+				t.Error("bad ping", r)
+			}
+			rd.Set("a", "hello")
+			rd.SetEX("b", 10, 1)
+			if n := rd.DBSize(); n != 2 {
+				t.Error("bad dbsize", n)
+			}
+			if rd.MemoryUsage("a") <= 0 || rd.MemoryUsage("none") != 0 {
+				t.Error("bad memory usage", rd.MemoryUsage("a"), rd.MemoryUsage("none"))
+			}
+			if rd.SlowlogGet(nil) == nil {
+				t.Error("slowlog should be an empty list")
+			}
+		})
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	rd := newMemoryRedis(t, "")
+	rd.Set("a", 1)
+	rd.SetEX("b", 10, 2)
+	section := "keyspace"
+	info := rd.Info(&section)
+	if !reflect.DeepEqual(info, map[string]interface{}{"keyspace": map[string]interface{}{"db0": map[string]interface{}{"keys": int64(2), "expires": int64(1), "avg_ttl": int64(0)}}}) {
+		t.Fatal("bad keyspace info", info)
+	}
+	if info = rd.Info(nil); info["server"] == nil || info["memory"] == nil {
+		t.Fatal("bad default info", info)
+	}
+}
+
+func TestStats(t *testing.T) {
+	rd := newMemoryRedis(t, "")
+	redisPool["statsTest"] = rd.pool
+	defer delete(redisPool, "statsTest")
+	rd.Set("a", 1)
+	stats := Stats()["statsTest"]
+	if stats.Active != 1 || stats.Idle != 1 || stats.InUse != 0 {
+		t.Fatal("bad pool stats", stats)
+	}
+}
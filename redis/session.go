@@ -0,0 +1,220 @@
+package redis
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/api-go/plugins/crypto/crypt"
+	"github.com/ssgo/u"
+)
+
+// KEYS: session
+// ARGV: field（空字符串表示读取全部）, ttlMs, sliding
+var sessionGetScript = newLuaScript(`local v
+if ARGV[1] == '' then
+	v = redis.call('HGETALL', KEYS[1])
+else
+	v = redis.call('HGET', KEYS[1], ARGV[1])
+end
+if ARGV[3] == '1' then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return v`)
+
+// KEYS: session
+// ARGV: ttlMs, sliding, field, value[, field, value...]
+// 非滑动过期时只在第一次写入时设置过期时间
+var sessionSetScript = newLuaScript(`redis.call('HSET', KEYS[1], unpack(ARGV, 3))
+if ARGV[2] == '1' or redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return 1`)
+
+// 加密时每个值使用的随机向量的长度，AES 和 SM4 的分组长度都是16字节
+const sessionIvSize = 16
+
+type Session struct {
+	rd      *Redis
+	id      string
+	ttl     int
+	sliding bool
+	crypt   crypt.Crypt
+	key     []byte
+}
+
+type sessionOption struct {
+	Ttl        int
+	Sliding    bool
+	EncryptKey string
+	Cipher     string
+}
+
+// Session 获取会话，数据保存在哈希表 session:<id> 中，每个字段单独使用JSON编码
+// Session id 会话ID，空字符串时生成一个新的随机ID
+// Session options 选项 {ttl, sliding, encryptKey, cipher}，ttl 为过期的秒数（默认1800），sliding 为true时每次访问都原子地刷新过期时间
+// Session options encryptKey 为hex编码的密钥，指定后使用 crypto 插件的对称加密算法加密每个字段，每次写入使用随机的向量并保存在密文前面，cipher 为 aes（默认）或 sm4
+// Session return 会话对象
+func (rd *Redis) Session(id string, options *map[string]interface{}) (*Session, error) {
+	opt := sessionOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	if opt.Ttl <= 0 {
+		opt.Ttl = 1800
+	}
+	if id == "" {
+		id = makeSessionId()
+	}
+	s := &Session{rd: rd, id: id, ttl: opt.Ttl, sliding: opt.Sliding}
+	if opt.EncryptKey != "" {
+		var err error
+		s.key, err = hex.DecodeString(opt.EncryptKey)
+		if err != nil || len(s.key) < 16 {
+			return nil, errors.New("invalid session encryptKey")
+		}
+		if strings.ToLower(opt.Cipher) == "sm4" {
+			s.crypt = &crypt.GMCrypt{}
+		} else {
+			s.crypt = &crypt.CMCrypt{}
+		}
+	}
+	return s, nil
+}
+
+func makeSessionId() string {
+	return hex.EncodeToString(u.MakeToken(16))
+}
+
+// Id 获取会话ID
+func (s *Session) Id() string {
+	return s.id
+}
+
+func (s *Session) redisKey() string {
+	return s.rd.makeKey("session:" + s.id)
+}
+
+func (s *Session) ttlMs() int64 {
+	return int64(s.ttl) * 1000
+}
+
+func (s *Session) slidingArg() string {
+	if s.sliding {
+		return "1"
+	}
+	return "0"
+}
+
+// Get 获取会话中的字段
+// Get return 字段的值，不存在或无法解密时返回null
+func (s *Session) Get(field string) interface{} {
+	reply, _ := s.rd.evalScript(sessionGetScript, []string{s.redisKey()}, field, s.ttlMs(), s.slidingArg())
+	return s.decode(reply)
+}
+
+// Set 设置会话中的字段
+// Set value 字段的值，使用JSON编码后保存
+func (s *Session) Set(field string, value interface{}) error {
+	data, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+	_, err = s.rd.evalScript(sessionSetScript, []string{s.redisKey()}, s.ttlMs(), s.slidingArg(), field, data)
+	return err
+}
+
+// All 获取会话中的所有字段
+// All return {field: value}，会话不存在时返回空对象
+func (s *Session) All() map[string]interface{} {
+	reply, _ := s.rd.evalScript(sessionGetScript, []string{s.redisKey()}, "", s.ttlMs(), s.slidingArg())
+	arr, _ := reply.([]interface{})
+	out := map[string]interface{}{}
+	for i := 0; i+1 < len(arr); i += 2 {
+		out[replyString(arr[i])] = s.decode(arr[i+1])
+	}
+	return out
+}
+
+// Touch 刷新会话的过期时间
+// Touch return 会话是否存在
+func (s *Session) Touch() bool {
+	reply, _ := s.rd.doRaw("PEXPIRE", s.redisKey(), s.ttlMs())
+	return replyBool(reply)
+}
+
+// Regenerate 更换会话ID并保留数据，用于登录后防止会话固定攻击
+// Regenerate 新旧会话在集群中位于不同的槽位，数据复制到新的会话后再删除旧的会话，复制期间对旧会话的写入会丢失
+// Regenerate return 新的会话ID
+func (s *Session) Regenerate() (string, error) {
+	oldKey := s.redisKey()
+	newId := makeSessionId()
+	newKey := s.rd.makeKey("session:" + newId)
+	reply, err := s.rd.doRaw("HGETALL", oldKey)
+	if err != nil {
+		return s.id, err
+	}
+	fields, _ := reply.([]interface{})
+	if len(fields) > 0 {
+		ttl := s.ttlMs()
+		if !s.sliding {
+			// 非滑动过期时保留剩余的过期时间
+			if pttl, _ := s.rd.doRaw("PTTL", oldKey); replyInt(pttl) > 0 {
+				ttl = replyInt(pttl)
+			}
+		}
+		if _, err = s.rd.doRaw("HSET", append([]interface{}{newKey}, fields...)...); err != nil {
+			return s.id, err
+		}
+		_, _ = s.rd.doRaw("PEXPIRE", newKey, ttl)
+		_, _ = s.rd.doRaw("DEL", oldKey)
+	}
+	s.id = newId
+	return s.id, nil
+}
+
+// Destroy 删除会话
+// Destroy return 会话是否存在
+func (s *Session) Destroy() bool {
+	reply, _ := s.rd.doRaw("DEL", s.redisKey())
+	return replyInt(reply) > 0
+}
+
+func (s *Session) encode(value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil || s.crypt == nil {
+		return data, err
+	}
+	iv := make([]byte, sessionIvSize)
+	if _, err = rand.Read(iv); err != nil {
+		return nil, err
+	}
+	enData, err := s.crypt.Encrypt(data, s.key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return append(iv, enData...), nil
+}
+
+func (s *Session) decode(reply interface{}) interface{} {
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil
+	}
+	if s.crypt != nil {
+		var err error
+		if len(data) <= sessionIvSize {
+			return nil
+		}
+		if data, err = s.crypt.Decrypt(data[sessionIvSize:], s.key, data[:sessionIvSize]); err != nil {
+			return nil
+		}
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return string(data)
+	}
+	return value
+}
@@ -0,0 +1,128 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	key := hex.EncodeToString([]byte("0123456789abcdef"))
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+	}{
+		{"plain", map[string]interface{}{}},
+		{"aes", map[string]interface{}{"encryptKey": key}},
+		{"sm4", map[string]interface{}{"encryptKey": key, "cipher": "sm4"}},
+	}
+	for _, test := range tests {
+		rd := newMemoryRedis(t, "")
+		s, err := rd.Session("", &test.options)
+		if err != nil {
+			t.Fatal(test.name, err)
+		}
+		if err = s.Set("user", map[string]interface{}{"id": 1}); err != nil {
+			t.Fatal(test.name, err)
+		}
+		_ = s.Set("role", "admin")
+		if !reflect.DeepEqual(s.Get("user"), map[string]interface{}{"id": float64(1)}) || s.Get("role") != "admin" || s.Get("none") != nil {
+			t.Error(test.name, "bad get", s.Get("user"), s.Get("role"))
+		}
+		if all := s.All(); len(all) != 2 || all["role"] != "admin" {
+			t.Error(test.name, "bad all", all)
+		}
+
+		// 使用相同的ID读取已经保存的会话
+		s2, _ := rd.Session(s.Id(), &test.options)
+		if s2.Get("role") != "admin" {
+			t.Error(test.name, "session not shared by id")
+		}
+		stored := []byte(rd.HGetString("session:"+s.Id(), "role"))
+		if test.name != "plain" {
+			// 相同的值每次加密的结果都不同
+			_ = s.Set("role2", "admin")
+			stored2 := []byte(rd.HGetString("session:"+s.Id(), "role2"))
+			if bytes.Contains(stored, []byte("admin")) || bytes.Equal(stored[:sessionIvSize], stored2[:sessionIvSize]) || bytes.Equal(stored, stored2) {
+				t.Error(test.name, "value should be encrypted with random iv")
+			}
+		}
+	}
+}
+
+func TestSessionEncryptKey(t *testing.T) {
+	rd := newMemoryRedis(t, "")
+	if _, err := rd.Session("", &map[string]interface{}{"encryptKey": "abcd"}); err == nil {
+		t.Error("short key should fail")
+	}
+	if _, err := rd.Session("", &map[string]interface{}{"encryptKey": "not hex"}); err == nil {
+		t.Error("bad hex key should fail")
+	}
+
+	s, _ := rd.Session("", &map[string]interface{}{"encryptKey": hex.EncodeToString([]byte("0123456789abcdef"))})
+	_ = s.Set("a", "b")
+	other, _ := rd.Session(s.Id(), &map[string]interface{}{"encryptKey": hex.EncodeToString([]byte("fedcba9876543210"))})
+	if v := other.Get("a"); v == "b" {
+		t.Error("wrong key should not decrypt", v)
+	}
+	// 没有随机向量的数据无法解密
+	rd.HSet("session:"+s.Id(), "short", "x")
+	if s.Get("short") != nil {
+		t.Error("short data should not decrypt")
+	}
+}
+
+func TestSessionTtl(t *testing.T) {
+	rd := newMemoryRedis(t, "")
+	rd.SetClock(1700000000000)
+	s, _ := rd.Session("", &map[string]interface{}{"ttl": 10, "sliding": true})
+	_ = s.Set("a", 1)
+	rd.AdvanceClock(8000)
+	if s.Get("a") != float64(1) || rd.PTTL("session:"+s.Id()) != 10000 {
+		t.Fatal("sliding session should be refreshed", rd.PTTL("session:"+s.Id()))
+	}
+
+	fixed, _ := rd.Session("", &map[string]interface{}{"ttl": 10})
+	_ = fixed.Set("a", 1)
+	rd.AdvanceClock(8000)
+	_ = fixed.Set("b", 2)
+	if rd.PTTL("session:"+fixed.Id()) != 2000 {
+		t.Fatal("fixed session should not be refreshed", rd.PTTL("session:"+fixed.Id()))
+	}
+
+	// 更换ID后滑动过期的会话重新计时，非滑动过期的会话保留剩余时间
+	oldId := s.Id()
+	newId, err := s.Regenerate()
+	if err != nil || newId == oldId || rd.Exists("session:"+oldId) || s.Get("a") != float64(1) || rd.PTTL("session:"+newId) != 10000 {
+		t.Fatal("bad regenerate", err)
+	}
+	if _, err = fixed.Regenerate(); err != nil || fixed.Get("b") != float64(2) || rd.PTTL("session:"+fixed.Id()) != 2000 {
+		t.Fatal("bad regenerate of fixed session", err, rd.PTTL("session:"+fixed.Id()))
+	}
+	if !s.Destroy() || s.Get("a") != nil {
+		t.Fatal("bad destroy")
+	}
+}
+
+func TestSessionRegenerate(t *testing.T) {
+	rd := newTestRedis(t, "?prefix=app:")
+	s, _ := rd.Session("", &map[string]interface{}{"ttl": 10, "encryptKey": hex.EncodeToString([]byte("0123456789abcdef"))})
+	_ = s.Set("user", map[string]interface{}{"id": 1})
+	_ = s.Set("role", "admin")
+	oldId := s.Id()
+	newId, err := s.Regenerate()
+	if err != nil || newId == oldId || rd.Exists("session:"+oldId) || !ttlBetween(rd, "session:"+newId, 9000, 10000) {
+		t.Fatal("bad regenerate", err)
+	}
+	if all := s.All(); !reflect.DeepEqual(all, map[string]interface{}{"user": map[string]interface{}{"id": float64(1)}, "role": "admin"}) {
+		t.Fatal("data not kept", all)
+	}
+
+	// 不存在的会话只更换ID
+	empty, _ := rd.Session("", nil)
+	emptyId := empty.Id()
+	if newId, err = empty.Regenerate(); err != nil || newId == emptyId || rd.Exists("session:"+newId) {
+		t.Fatal("bad regenerate of empty session", err)
+	}
+}
@@ -0,0 +1,117 @@
+package redis
+
+// SAdd 向集合添加一个或多个成员
+// SAdd members 成员，对象会以JSON格式存储
+// SAdd return 新添加的成员数
+func (rd *Redis) SAdd(key string, members ...interface{}) int64 {
+	reply, _ := rd.doRaw("SADD", append([]interface{}{rd.makeKey(key)}, rd.encodeValues(members)...)...)
+	return replyInt(reply)
+}
+
+// SRem 移除集合中的一个或多个成员
+// SRem return 成功移除的成员数
+func (rd *Redis) SRem(key string, members ...interface{}) int64 {
+	reply, _ := rd.doRaw("SREM", append([]interface{}{rd.makeKey(key)}, rd.encodeValues(members)...)...)
+	return replyInt(reply)
+}
+
+// SMembers 获取集合中的所有成员
+// SMembers return []any 成员列表，如果成员是一个对象则返回反序列化后的对象，否则返回字符串
+func (rd *Redis) SMembers(key string) []interface{} {
+	reply, _ := rd.doRaw("SMEMBERS", rd.makeKey(key))
+	return rd.replyValues(reply)
+}
+
+// SIsMember 判断成员是否在集合中
+func (rd *Redis) SIsMember(key string, member interface{}) bool {
+	reply, _ := rd.doRaw("SISMEMBER", rd.makeKey(key), rd.encodeValue(member))
+	return replyBool(reply)
+}
+
+// SMIsMember 判断多个成员是否在集合中
+// SMIsMember return []bool 按照传入成员的顺序返回是否存在
+func (rd *Redis) SMIsMember(key string, members ...interface{}) []bool {
+	reply, _ := rd.doRaw("SMISMEMBER", append([]interface{}{rd.makeKey(key)}, rd.encodeValues(members)...)...)
+	arr, _ := reply.([]interface{})
+	out := make([]bool, len(arr))
+	for i, v := range arr {
+		out[i] = replyBool(v)
+	}
+	return out
+}
+
+// SCard 获取集合的成员数
+func (rd *Redis) SCard(key string) int64 {
+	reply, _ := rd.doRaw("SCARD", rd.makeKey(key))
+	return replyInt(reply)
+}
+
+// SPop 移除并返回集合中的随机成员
+// SPop count 数量，不指定时返回一个成员，指定时返回成员数组
+func (rd *Redis) SPop(key string, count *int) interface{} {
+	args := []interface{}{rd.makeKey(key)}
+	if count != nil {
+		args = append(args, *count)
+	}
+	reply, _ := rd.doRaw("SPOP", args...)
+	if count != nil {
+		return rd.replyValues(reply)
+	}
+	return rd.replyValue(reply)
+}
+
+// SRandMember 返回集合中的随机成员（不移除）
+// SRandMember count 数量，不指定时返回一个成员，指定时返回成员数组，负数表示允许重复
+func (rd *Redis) SRandMember(key string, count *int) interface{} {
+	args := []interface{}{rd.makeKey(key)}
+	if count != nil {
+		args = append(args, *count)
+	}
+	reply, _ := rd.doRaw("SRANDMEMBER", args...)
+	if count != nil {
+		return rd.replyValues(reply)
+	}
+	return rd.replyValue(reply)
+}
+
+// SInter 获取多个集合的交集
+// SInter return []any 成员列表
+func (rd *Redis) SInter(keys ...string) []interface{} {
+	reply, _ := rd.doRaw("SINTER", rd.makeKeys(keys)...)
+	return rd.replyValues(reply)
+}
+
+// SUnion 获取多个集合的并集
+// SUnion return []any 成员列表
+func (rd *Redis) SUnion(keys ...string) []interface{} {
+	reply, _ := rd.doRaw("SUNION", rd.makeKeys(keys)...)
+	return rd.replyValues(reply)
+}
+
+// SDiff 获取第一个集合与其他集合的差集
+// SDiff return []any 成员列表
+func (rd *Redis) SDiff(keys ...string) []interface{} {
+	reply, _ := rd.doRaw("SDIFF", rd.makeKeys(keys)...)
+	return rd.replyValues(reply)
+}
+
+// SInterStore 计算多个集合的交集并存储到 destination
+// SInterStore return 结果集合的成员数
+func (rd *Redis) SInterStore(destination string, keys ...string) int64 {
+	reply, _ := rd.doRaw("SINTERSTORE", append([]interface{}{rd.makeKey(destination)}, rd.makeKeys(keys)...)...)
+	return replyInt(reply)
+}
+
+// SUnionStore 计算多个集合的并集并存储到 destination
+// SUnionStore return 结果集合的成员数
+func (rd *Redis) SUnionStore(destination string, keys ...string) int64 {
+	reply, _ := rd.doRaw("SUNIONSTORE", append([]interface{}{rd.makeKey(destination)}, rd.makeKeys(keys)...)...)
+	return replyInt(reply)
+}
+
+// SDiffStore 计算第一个集合与其他集合的差集并存储到 destination
+// SDiffStore return 结果集合的成员数
+func (rd *Redis) SDiffStore(destination string, keys ...string) int64 {
+	reply, _ := rd.doRaw("SDIFFSTORE", append([]interface{}{rd.makeKey(destination)}, rd.makeKeys(keys)...)...)
+	return replyInt(reply)
+}
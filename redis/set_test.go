@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// sortedValues 集合的成员没有顺序，按照字符串格式排序后比较
+func sortedValues(values interface{}) []string {
+	arr, _ := values.([]interface{})
+	out := make([]string, len(arr))
+	for i, v := range arr {
+		out[i] = fmt.Sprint(v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestSet(t *testing.T) {
+	rd := newTestRedis(t, "")
+	if n := rd.SAdd("s1", "a", "b", "c", "a"); n != 3 {
+		t.Fatal("bad sadd", n)
+	}
+	rd.SAdd("s2", "b", "c", "d")
+	rd.SAdd("obj", map[string]interface{}{"id": 1})
+
+	if out := rd.SMembers("obj"); !reflect.DeepEqual(out, []interface{}{map[string]interface{}{"id": float64(1)}}) {
+		t.Error("object member not decoded", out)
+	}
+	if !rd.SIsMember("s1", "a") || rd.SIsMember("s1", "d") {
+		t.Error("bad sismember")
+	}
+	if out := rd.SMIsMember("s1", "a", "d", "c"); !reflect.DeepEqual(out, []bool{true, false, true}) {
+		t.Error("bad smismember", out)
+	}
+	if rd.SCard("s1") != 3 || rd.SCard("none") != 0 {
+		t.Error("bad scard")
+	}
+
+	tests := []struct {
+		name   string
+		out    []interface{}
+		expect []string
+	}{
+		{"sinter", rd.SInter("s1", "s2"), []string{"b", "c"}},
+		{"sunion", rd.SUnion("s1", "s2"), []string{"a", "b", "c", "d"}},
+		{"sdiff", rd.SDiff("s1", "s2"), []string{"a"}},
+	}
+	for _, test := range tests {
+		if out := sortedValues(test.out); !reflect.DeepEqual(out, test.expect) {
+			t.Error("bad", test.name, out)
+		}
+	}
+	if rd.SInterStore("i", "s1", "s2") != 2 || rd.SUnionStore("u", "s1", "s2") != 4 || rd.SDiffStore("d", "s2", "s1") != 1 {
+		t.Error("bad store commands")
+	}
+	if out := sortedValues(rd.SMembers("d")); !reflect.DeepEqual(out, []string{"d"}) {
+		t.Error("bad sdiffstore", out)
+	}
+
+	if v := rd.SRandMember("s1", nil); !rd.SIsMember("s1", v) {
+		t.Error("bad srandmember", v)
+	}
+	count := -5
+	if out := rd.SRandMember("s1", &count).([]interface{}); len(out) != 5 {
+		t.Error("negative count should allow repeats", out)
+	}
+	count = 2
+	popped := rd.SPop("s1", &count).([]interface{})
+	if len(popped) != 2 || rd.SCard("s1") != 1 {
+		t.Error("bad spop", popped)
+	}
+	last := rd.SPop("s1", nil)
+	if rd.SRem("s2", "b", "none") != 1 || rd.SCard("s1") != 0 || last == nil {
+		t.Error("bad spop or srem", last)
+	}
+}
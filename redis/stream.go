@@ -0,0 +1,354 @@
+package redis
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ssgo/u"
+)
+
+type StreamEntry struct {
+	Id     string
+	Fields map[string]interface{}
+}
+
+type StreamPending struct {
+	Id         string
+	Consumer   string
+	Idle       int64
+	Deliveries int64
+}
+
+type StreamPendingSummary struct {
+	Count     int64
+	MinId     string
+	MaxId     string
+	Consumers map[string]int64
+}
+
+type StreamAutoClaimResult struct {
+	Next    string
+	Entries []StreamEntry
+	Deleted []string
+}
+
+type xAddOption struct {
+	Id         string
+	MaxLen     int64
+	MinId      string
+	Approx     bool
+	Limit      int64
+	NoMkStream bool
+}
+
+type xReadOption struct {
+	Count int
+	Block *int
+	NoAck bool
+}
+
+type xPendingOption struct {
+	Start    string
+	End      string
+	Count    int
+	Consumer string
+	Idle     int64
+}
+
+func (c valueCodec) makeStreamEntry(reply interface{}) StreamEntry {
+	arr, _ := reply.([]interface{})
+	entry := StreamEntry{Fields: map[string]interface{}{}}
+	if len(arr) > 0 {
+		entry.Id = replyString(arr[0])
+	}
+	if len(arr) > 1 {
+		entry.Fields = c.replyMap(arr[1])
+	}
+	return entry
+}
+
+func (c valueCodec) makeStreamEntries(reply interface{}) []StreamEntry {
+	arr, _ := reply.([]interface{})
+	out := make([]StreamEntry, 0, len(arr))
+	for _, v := range arr {
+		if v != nil {
+			out = append(out, c.makeStreamEntry(v))
+		}
+	}
+	return out
+}
+
+// makeStreamsResult 转换 XREAD/XREADGROUP 的回复，格式为 {stream: [entry, ...]}
+func (rd *Redis) makeStreamsResult(reply interface{}) map[string][]StreamEntry {
+	out := map[string][]StreamEntry{}
+	arr, _ := reply.([]interface{})
+	for _, v := range arr {
+		if stream, ok := v.([]interface{}); ok && len(stream) == 2 {
+			out[rd.trimKey(replyString(stream[0]))] = rd.makeStreamEntries(stream[1])
+		}
+	}
+	return out
+}
+
+func (c valueCodec) makeFieldArgs(fields map[string]interface{}) []interface{} {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := make([]interface{}, 0, len(fields)*2)
+	for _, name := range names {
+		args = append(args, name, c.encodeValue(fields[name]))
+	}
+	return args
+}
+
+// XAdd 向Stream中添加一条消息
+// XAdd fields 消息内容，对象类型的值会以JSON格式存储
+// XAdd options 选项 {id, maxLen, minId, approx, limit, noMkStream}，id 默认为 "*" 自动生成，maxLen/minId 用于裁剪Stream，approx 使用 "~" 近似裁剪
+// XAdd return 消息ID
+func (rd *Redis) XAdd(key string, fields map[string]interface{}, options *map[string]interface{}) string {
+	opt := xAddOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	args := []interface{}{rd.makeKey(key)}
+	if opt.NoMkStream {
+		args = append(args, "NOMKSTREAM")
+	}
+	if opt.MaxLen > 0 || opt.MinId != "" {
+		if opt.MaxLen > 0 {
+			args = append(args, "MAXLEN")
+		} else {
+			args = append(args, "MINID")
+		}
+		if opt.Approx {
+			args = append(args, "~")
+		}
+		if opt.MaxLen > 0 {
+			args = append(args, opt.MaxLen)
+		} else {
+			args = append(args, opt.MinId)
+		}
+		if opt.Approx && opt.Limit > 0 {
+			args = append(args, "LIMIT", opt.Limit)
+		}
+	}
+	if opt.Id == "" {
+		opt.Id = "*"
+	}
+	args = append(args, opt.Id)
+	args = append(args, rd.makeFieldArgs(fields)...)
+	reply, _ := rd.doRaw("XADD", args...)
+	return replyString(reply)
+}
+
+// XRange 获取Stream中指定ID范围内的消息
+// XRange start 开始ID，"-" 表示最小
+// XRange end 结束ID，"+" 表示最大
+// XRange count 最多返回的数量
+// XRange return [{id, fields}]
+func (rd *Redis) XRange(key, start, end string, count *int) []StreamEntry {
+	args := []interface{}{rd.makeKey(key), start, end}
+	if count != nil {
+		args = append(args, "COUNT", *count)
+	}
+	reply, _ := rd.doRaw("XRANGE", args...)
+	return rd.makeStreamEntries(reply)
+}
+
+// XRevRange 按照ID从大到小获取Stream中的消息
+// XRevRange end 结束ID，"+" 表示最大
+// XRevRange start 开始ID，"-" 表示最小
+// XRevRange return [{id, fields}]
+func (rd *Redis) XRevRange(key, end, start string, count *int) []StreamEntry {
+	args := []interface{}{rd.makeKey(key), end, start}
+	if count != nil {
+		args = append(args, "COUNT", *count)
+	}
+	reply, _ := rd.doRaw("XREVRANGE", args...)
+	return rd.makeStreamEntries(reply)
+}
+
+// XRead 从一个或多个Stream中读取消息
+// XRead streams 要读取的Stream和起始ID，格式为 {stream: id}，id 为 "$" 时只读取新消息
+// XRead options 选项 {count, block}，block 为阻塞等待的毫秒数，0 表示一直等待
+// XRead return {stream: [{id, fields}]}，超时时返回空对象
+func (rd *Redis) XRead(streams map[string]string, options *map[string]interface{}) map[string][]StreamEntry {
+	opt := xReadOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	args := rd.makeXReadArgs(opt, streams)
+	return rd.makeStreamsResult(rd.doStreamRead(opt, "XREAD", args...))
+}
+
+// XGroupCreate 创建消费者组
+// XGroupCreate id 从哪个ID开始消费，"$" 表示只消费新消息，"0" 表示从头开始
+// XGroupCreate mkStream Stream不存在时是否自动创建
+// XGroupCreate return 是否成功，消费者组已经存在时返回false
+func (rd *Redis) XGroupCreate(key, group, id string, mkStream bool) bool {
+	args := []interface{}{"CREATE", rd.makeKey(key), group, id}
+	if mkStream {
+		args = append(args, "MKSTREAM")
+	}
+	reply, _ := rd.doRaw("XGROUP", args...)
+	return replyBool(reply)
+}
+
+// XReadGroup 以消费者组的方式读取消息
+// XReadGroup streams 要读取的Stream和起始ID，格式为 {stream: id}，id 为 ">" 时读取未分配给其他消费者的新消息
+// XReadGroup options 选项 {count, block, noAck}
+// XReadGroup return {stream: [{id, fields}]}，超时时返回空对象
+func (rd *Redis) XReadGroup(group, consumer string, streams map[string]string, options *map[string]interface{}) map[string][]StreamEntry {
+	opt := xReadOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	args := []interface{}{"GROUP", group, consumer}
+	if opt.NoAck {
+		args = append(args, "NOACK")
+	}
+	args = append(args, rd.makeXReadArgs(opt, streams)...)
+	return rd.makeStreamsResult(rd.doStreamRead(opt, "XREADGROUP", args...))
+}
+
+func (rd *Redis) makeXReadArgs(opt xReadOption, streams map[string]string) []interface{} {
+	args := make([]interface{}, 0)
+	if opt.Count > 0 {
+		args = append(args, "COUNT", opt.Count)
+	}
+	if opt.Block != nil {
+		args = append(args, "BLOCK", *opt.Block)
+	}
+	keys := make([]string, 0, len(streams))
+	for key := range streams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	args = append(args, "STREAMS")
+	for _, key := range keys {
+		args = append(args, rd.makeKey(key))
+	}
+	for _, key := range keys {
+		args = append(args, streams[key])
+	}
+	return args
+}
+
+func (rd *Redis) doStreamRead(opt xReadOption, cmd string, args ...interface{}) interface{} {
+	var reply interface{}
+	if opt.Block != nil {
+		reply, _ = rd.doBlocking(time.Duration(*opt.Block)*time.Millisecond, cmd, args...)
+	} else {
+		reply, _ = rd.doRaw(cmd, args...)
+	}
+	return reply
+}
+
+// XAck 确认消费者组中的消息已经处理完成
+// XAck return 成功确认的消息数
+func (rd *Redis) XAck(key, group string, ids ...string) int64 {
+	reply, _ := rd.doRaw("XACK", append([]interface{}{rd.makeKey(key), group}, u.ToInterfaceArray(ids)...)...)
+	return replyInt(reply)
+}
+
+// XPending 查询消费者组中已读取但未确认的消息
+// XPending options 选项 {start, end, count, consumer, idle}，不指定 count 时返回汇总信息 {count, minId, maxId, consumers}
+// XPending return 指定 count 时返回 [{id, consumer, idle, deliveries}]
+func (rd *Redis) XPending(key, group string, options *map[string]interface{}) interface{} {
+	opt := xPendingOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	if opt.Count <= 0 {
+		reply, _ := rd.doRaw("XPENDING", rd.makeKey(key), group)
+		arr, _ := reply.([]interface{})
+		summary := StreamPendingSummary{Consumers: map[string]int64{}}
+		if len(arr) == 4 {
+			summary.Count = replyInt(arr[0])
+			summary.MinId = replyString(arr[1])
+			summary.MaxId = replyString(arr[2])
+			consumers, _ := arr[3].([]interface{})
+			for _, c := range consumers {
+				if pair, ok := c.([]interface{}); ok && len(pair) == 2 {
+					summary.Consumers[replyString(pair[0])] = replyInt(pair[1])
+				}
+			}
+		}
+		return summary
+	}
+
+	if opt.Start == "" {
+		opt.Start = "-"
+	}
+	if opt.End == "" {
+		opt.End = "+"
+	}
+	args := []interface{}{rd.makeKey(key), group}
+	if opt.Idle > 0 {
+		args = append(args, "IDLE", opt.Idle)
+	}
+	args = append(args, opt.Start, opt.End, opt.Count)
+	if opt.Consumer != "" {
+		args = append(args, opt.Consumer)
+	}
+	reply, _ := rd.doRaw("XPENDING", args...)
+	arr, _ := reply.([]interface{})
+	out := make([]StreamPending, 0, len(arr))
+	for _, v := range arr {
+		if item, ok := v.([]interface{}); ok && len(item) == 4 {
+			out = append(out, StreamPending{
+				Id:         replyString(item[0]),
+				Consumer:   replyString(item[1]),
+				Idle:       replyInt(item[2]),
+				Deliveries: replyInt(item[3]),
+			})
+		}
+	}
+	return out
+}
+
+// XClaim 将空闲时间超过 minIdleTime 的待确认消息转移给指定的消费者
+// XClaim minIdleTime 最小空闲时间，单位毫秒
+// XClaim return 成功转移的消息 [{id, fields}]
+func (rd *Redis) XClaim(key, group, consumer string, minIdleTime int64, ids ...string) []StreamEntry {
+	args := append([]interface{}{rd.makeKey(key), group, consumer, minIdleTime}, u.ToInterfaceArray(ids)...)
+	reply, _ := rd.doRaw("XCLAIM", args...)
+	return rd.makeStreamEntries(reply)
+}
+
+// XAutoClaim 自动扫描并转移空闲时间超过 minIdleTime 的待确认消息
+// XAutoClaim start 开始扫描的ID，首次调用传入 "0-0"，之后传入上次返回的 next
+// XAutoClaim count 最多转移的数量，默认为100
+// XAutoClaim return {next, entries, deleted}，next 为 "0-0" 时表示扫描完成
+func (rd *Redis) XAutoClaim(key, group, consumer string, minIdleTime int64, start string, count *int) StreamAutoClaimResult {
+	args := []interface{}{rd.makeKey(key), group, consumer, minIdleTime, start}
+	if count != nil {
+		args = append(args, "COUNT", *count)
+	}
+	reply, _ := rd.doRaw("XAUTOCLAIM", args...)
+	arr, _ := reply.([]interface{})
+	out := StreamAutoClaimResult{Entries: []StreamEntry{}, Deleted: []string{}}
+	if len(arr) > 1 {
+		out.Next = replyString(arr[0])
+		out.Entries = rd.makeStreamEntries(arr[1])
+	}
+	if len(arr) > 2 {
+		out.Deleted = replyStrings(arr[2])
+	}
+	return out
+}
+
+// XDel 删除Stream中的消息
+// XDel return 成功删除的消息数
+func (rd *Redis) XDel(key string, ids ...string) int64 {
+	reply, _ := rd.doRaw("XDEL", append([]interface{}{rd.makeKey(key)}, u.ToInterfaceArray(ids)...)...)
+	return replyInt(reply)
+}
+
+// XLen 获取Stream中的消息数
+func (rd *Redis) XLen(key string) int64 {
+	reply, _ := rd.doRaw("XLEN", rd.makeKey(key))
+	return replyInt(reply)
+}
@@ -0,0 +1,85 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	rd := newTestRedis(t, "")
+	id1 := rd.XAdd("events", map[string]interface{}{"type": "login", "user": map[string]interface{}{"id": 1}}, &map[string]interface{}{"id": "1-1"})
+	id2 := rd.XAdd("events", map[string]interface{}{"type": "logout"}, nil)
+	rd.XAdd("events", map[string]interface{}{"type": "login"}, &map[string]interface{}{"maxLen": 2})
+	if id1 != "1-1" || id2 == "" || rd.XLen("events") != 2 {
+		t.Fatal("bad xadd", id1, id2, rd.XLen("events"))
+	}
+	if rd.XAdd("none", map[string]interface{}{"a": 1}, &map[string]interface{}{"noMkStream": true}) != "" || rd.XLen("none") != 0 {
+		t.Fatal("stream created with noMkStream")
+	}
+
+	entries := rd.XRange("events", "-", "+", nil)
+	if len(entries) != 2 || entries[0].Id != id2 || entries[0].Fields["type"] != "logout" {
+		t.Fatal("bad xrange", entries)
+	}
+	count := 1
+	if last := rd.XRevRange("events", "+", "-", &count); len(last) != 1 || last[0].Fields["type"] != "login" {
+		t.Fatal("bad xrevrange", last)
+	}
+	if out := rd.XRead(map[string]string{"events": id2}, nil); len(out["events"]) != 1 {
+		t.Fatal("bad xread", out)
+	}
+
+	// 阻塞读取时在独立的连接上等待新消息
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		rd.XAdd("events", map[string]interface{}{"type": "new", "data": map[string]interface{}{"n": 1}}, nil)
+	}()
+	block := 2000
+	out := rd.XRead(map[string]string{"events": "$"}, &map[string]interface{}{"block": block})
+	if len(out["events"]) != 1 || !reflect.DeepEqual(out["events"][0].Fields["data"], map[string]interface{}{"n": float64(1)}) {
+		t.Fatal("bad blocking xread", out)
+	}
+	block = 50
+	if out = rd.XRead(map[string]string{"events": "$"}, &map[string]interface{}{"block": block}); len(out) != 0 {
+		t.Fatal("blocking xread should time out", out)
+	}
+}
+
+func TestStreamGroup(t *testing.T) {
+	rd := newTestRedis(t, "")
+	if !rd.XGroupCreate("jobs", "workers", "0", true) || rd.XGroupCreate("jobs", "workers", "0", true) {
+		t.Fatal("bad xgroup create")
+	}
+	id1 := rd.XAdd("jobs", map[string]interface{}{"n": 1}, nil)
+	id2 := rd.XAdd("jobs", map[string]interface{}{"n": 2}, nil)
+
+	out := rd.XReadGroup("workers", "w1", map[string]string{"jobs": ">"}, &map[string]interface{}{"count": 1})
+	if len(out["jobs"]) != 1 || out["jobs"][0].Id != id1 {
+		t.Fatal("bad xreadgroup", out)
+	}
+	rd.XReadGroup("workers", "w2", map[string]string{"jobs": ">"}, nil)
+
+	summary, _ := rd.XPending("jobs", "workers", nil).(StreamPendingSummary)
+	if summary.Count != 2 || summary.MinId != id1 || summary.MaxId != id2 || summary.Consumers["w1"] != 1 || summary.Consumers["w2"] != 1 {
+		t.Fatal("bad pending summary", summary)
+	}
+	pending, _ := rd.XPending("jobs", "workers", &map[string]interface{}{"count": 10, "consumer": "w2"}).([]StreamPending)
+	if len(pending) != 1 || pending[0].Id != id2 || pending[0].Deliveries != 1 {
+		t.Fatal("bad pending", pending)
+	}
+
+	if rd.XAck("jobs", "workers", id1) != 1 {
+		t.Fatal("bad xack")
+	}
+	if claimed := rd.XClaim("jobs", "workers", "w1", 0, id2); len(claimed) != 1 || claimed[0].Id != id2 {
+		t.Fatal("bad xclaim", claimed)
+	}
+	result := rd.XAutoClaim("jobs", "workers", "w3", 0, "0-0", nil)
+	if result.Next != "0-0" || len(result.Entries) != 1 || result.Entries[0].Fields["n"] != float64(2) {
+		t.Fatal("bad xautoclaim", result)
+	}
+	if rd.XDel("jobs", id2) != 1 || rd.XLen("jobs") != 1 {
+		t.Fatal("bad xdel")
+	}
+}
@@ -0,0 +1,238 @@
+package redis
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/api-go/plugin"
+	"github.com/api-go/plugins/runtime"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// subscription 一个独立连接上的订阅，连接断开后自动重连并重新订阅
+// 消息在接收协程中依次交给 handle 处理，处理完一个消息后才接收下一个，不会在内存中堆积
+type subscription struct {
+	rd        *Redis
+	isPattern bool
+	names     map[string]bool
+	handle    func(redigo.Message)
+	done      chan bool
+	conn      *redigo.PubSubConn
+	running   bool
+	lock      sync.Mutex
+}
+
+// Subscribe 订阅频道，在独立的连接上接收消息，脚本结束时自动取消订阅
+// Subscribe channels 频道名称列表
+// Subscribe callback 收到消息时的回调函数，参数为(data, channel)，如果data是一个对象则传入反序列化后的对象，否则传入字符串
+func (rd *Redis) Subscribe(ctx *plugin.Context, channels []string, callback func(interface{}, string)) error {
+	return rd.subscribe(ctx, false, channels, callback)
+}
+
+// PSubscribe 按照模式订阅频道，例如："news.*"，脚本结束时自动取消订阅
+// PSubscribe patterns 模式列表
+// PSubscribe callback 收到消息时的回调函数，参数为(data, channel)
+func (rd *Redis) PSubscribe(ctx *plugin.Context, patterns []string, callback func(interface{}, string)) error {
+	return rd.subscribe(ctx, true, patterns, callback)
+}
+
+// Unsubscribe 取消订阅
+// Unsubscribe channels 频道名称或模式，不指定时取消当前对象上的所有订阅
+// Unsubscribe return 是否有订阅被取消
+func (rd *Redis) Unsubscribe(channels ...string) bool {
+	rd.subsLock.Lock()
+	subs := append([]*subscription{}, rd.subs...)
+	rd.subsLock.Unlock()
+
+	found := false
+	for _, sub := range subs {
+		if len(channels) == 0 {
+			found = sub.stop() || found
+		} else if sub.remove(channels) {
+			found = true
+		}
+	}
+	return found
+}
+
+func (rd *Redis) subscribe(ctx *plugin.Context, isPattern bool, names []string, callback func(interface{}, string)) error {
+	if len(names) == 0 {
+		return errors.New("no channel to subscribe")
+	}
+	if callback == nil {
+		return errors.New("no callback to receive messages")
+	}
+	sub := rd.newSubscription(isPattern, func(message redigo.Message) {
+		callback(rd.decodeBytes(message.Data), rd.trimKey(message.Channel))
+	})
+	for _, name := range names {
+		sub.names[rd.makeKey(name)] = true
+	}
+	return rd.startSubscription(ctx, sub)
+}
+
+func (rd *Redis) newSubscription(isPattern bool, handle func(redigo.Message)) *subscription {
+	return &subscription{
+		rd:        rd,
+		isPattern: isPattern,
+		names:     map[string]bool{},
+		handle:    handle,
+		done:      make(chan bool),
+	}
+}
+
+// startSubscription 建立订阅连接并开始在后台接收消息，向Context注册析构函数在脚本结束时停止订阅
+func (rd *Redis) startSubscription(ctx *plugin.Context, sub *subscription) error {
+	sub.running = true
+	if err := sub.connect(); err != nil {
+		sub.running = false
+		return err
+	}
+	go sub.receive()
+
+	rd.subsLock.Lock()
+	rd.subs = append(rd.subs, sub)
+	rd.subsLock.Unlock()
+	runtime.AddDestructor(ctx, func() { sub.stop() })
+	return nil
+}
+
+func (rd *Redis) removeSubscription(sub *subscription) {
+	rd.subsLock.Lock()
+	defer rd.subsLock.Unlock()
+	for i, s := range rd.subs {
+		if s == sub {
+			rd.subs = append(rd.subs[:i:i], rd.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (sub *subscription) connect() error {
+	// 使用独立的连接，避免长时间占用连接池
+	conn, err := sub.rd.pool.GetPool().Dial()
+	if err != nil {
+		return err
+	}
+
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	names := make([]interface{}, 0, len(sub.names))
+	for name := range sub.names {
+		names = append(names, name)
+	}
+	psc := &redigo.PubSubConn{Conn: conn}
+	if !sub.running {
+		_ = psc.Close()
+		return nil
+	}
+	if sub.isPattern {
+		err = psc.PSubscribe(names...)
+	} else {
+		err = psc.Subscribe(names...)
+	}
+	if err != nil {
+		_ = psc.Close()
+		return err
+	}
+	sub.conn = psc
+	return nil
+}
+
+func (sub *subscription) receive() {
+	logger := sub.rd.pool.GetLogger()
+	for sub.isRunning() {
+		sub.lock.Lock()
+		conn := sub.conn
+		sub.lock.Unlock()
+
+		if conn == nil {
+			// 连接断开后重新连接并恢复订阅
+			if err := sub.connect(); err != nil {
+				logger.Error(err.Error())
+				select {
+				case <-sub.done:
+				case <-time.After(time.Second):
+				}
+			}
+			continue
+		}
+
+		switch v := conn.ReceiveWithTimeout(0).(type) {
+		case redigo.Message:
+			// 停止后不再调用回调，已经开始执行的回调会执行完
+			if sub.isRunning() {
+				sub.handle(v)
+			}
+		case error:
+			if sub.isRunning() && !strings.Contains(v.Error(), "use of closed network connection") {
+				logger.Error(v.Error())
+			}
+			sub.closeConn(conn)
+		}
+	}
+}
+
+func (sub *subscription) remove(names []string) bool {
+	sub.lock.Lock()
+	removes := make([]interface{}, 0)
+	for _, name := range names {
+		name = sub.rd.makeKey(name)
+		if sub.names[name] {
+			delete(sub.names, name)
+			removes = append(removes, name)
+		}
+	}
+	left := len(sub.names)
+	conn := sub.conn
+	sub.lock.Unlock()
+
+	if len(removes) == 0 {
+		return false
+	}
+	if left == 0 {
+		sub.stop()
+	} else if conn != nil {
+		if sub.isPattern {
+			_ = conn.PUnsubscribe(removes...)
+		} else {
+			_ = conn.Unsubscribe(removes...)
+		}
+	}
+	return true
+}
+
+func (sub *subscription) isRunning() bool {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	return sub.running
+}
+
+// closeConn 关闭连接，conn 不是当前连接时（已经重连过）不处理，为nil时关闭当前连接
+func (sub *subscription) closeConn(conn *redigo.PubSubConn) {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	if sub.conn != nil && (conn == nil || conn == sub.conn) {
+		_ = sub.conn.Close()
+		sub.conn = nil
+	}
+}
+
+// stop 停止订阅，关闭连接使阻塞中的接收立即返回，接收协程随后退出
+// stop return 是否停止了订阅，已经停止过时返回false
+func (sub *subscription) stop() bool {
+	sub.lock.Lock()
+	if !sub.running {
+		sub.lock.Unlock()
+		return false
+	}
+	sub.running = false
+	close(sub.done)
+	sub.lock.Unlock()
+
+	sub.closeConn(nil)
+	sub.rd.removeSubscription(sub)
+	return true
+}
@@ -0,0 +1,138 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/api-go/plugin"
+	"github.com/api-go/plugins/runtime"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+type testMessage struct {
+	channel string
+	data    interface{}
+}
+
+// receiveMessages 返回一个订阅回调和接收回调参数的通道
+func receiveMessages() (func(interface{}, string), chan testMessage) {
+	messages := make(chan testMessage, 10)
+	return func(data interface{}, channel string) {
+		messages <- testMessage{channel, data}
+	}, messages
+}
+
+func waitMessage(t *testing.T, messages chan testMessage, expect *testMessage) {
+	t.Helper()
+	timeout := time.Second
+	if expect == nil {
+		timeout = 50 * time.Millisecond
+	}
+	select {
+	case message := <-messages:
+		if expect == nil || !reflect.DeepEqual(message, *expect) {
+			t.Errorf("bad message %v, expect %v", message, expect)
+		}
+	case <-time.After(timeout):
+		if expect != nil {
+			t.Errorf("no message, expect %v", *expect)
+		}
+	}
+}
+
+// numSub 获取频道的订阅数量
+func numSub(rd *Redis, channel string) int64 {
+	conn := rd.pool.GetPool().Get()
+	defer conn.Close()
+	values, _ := redigo.Values(conn.Do("PUBSUB", "NUMSUB", channel))
+	if len(values) != 2 {
+		return -1
+	}
+	n, _ := redigo.Int64(values[1], nil)
+	return n
+}
+
+func TestSubscribe(t *testing.T) {
+	rd := newTestRedis(t, "")
+	ctx := plugin.NewContext(nil)
+	callback, messages := receiveMessages()
+	if err := rd.Subscribe(ctx, []string{"news", "sport"}, callback); err != nil {
+		t.Fatal(err)
+	}
+	pcallback, pmessages := receiveMessages()
+	if err := rd.PSubscribe(ctx, []string{"news.*"}, pcallback); err != nil {
+		t.Fatal(err)
+	}
+
+	rd.Publish("news", "hello")
+	waitMessage(t, messages, &testMessage{"news", "hello"})
+	rd.Publish("sport", `{"score":3}`)
+	waitMessage(t, messages, &testMessage{"sport", map[string]interface{}{"score": float64(3)}})
+	rd.Publish("news.tech", "go")
+	waitMessage(t, pmessages, &testMessage{"news.tech", "go"})
+	waitMessage(t, messages, nil)
+
+	// 取消部分频道后仍然可以接收其他频道
+	if !rd.Unsubscribe("news") || rd.Unsubscribe("none") {
+		t.Error("unsubscribe failed")
+	}
+	// 取消订阅的命令在订阅连接上异步执行
+	for i := 0; i < 100 && numSub(rd, "news") != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	rd.Publish("news", "ignored")
+	rd.Publish("sport", "kept")
+	waitMessage(t, messages, &testMessage{"sport", "kept"})
+
+	// 脚本结束时停止所有订阅
+	runtime.RunDestructors(ctx)
+	if len(rd.subs) != 0 || rd.Unsubscribe() {
+		t.Fatal("subscriptions not stopped", rd.subs)
+	}
+	rd.Publish("sport", "after end")
+	rd.Publish("news.tech", "after end")
+	waitMessage(t, messages, nil)
+	waitMessage(t, pmessages, nil)
+}
+
+func TestSubscribeReconnect(t *testing.T) {
+	rd := newTestRedis(t, "")
+	callback, messages := receiveMessages()
+	if err := rd.Subscribe(nil, []string{"news"}, callback); err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Unsubscribe()
+
+	// 模拟连接断开，接收协程重新连接并恢复订阅
+	sub := rd.subs[0]
+	sub.closeConn(nil)
+	for i := 0; i < 100; i++ {
+		if rd.Publish("news", "again") {
+			select {
+			case message := <-messages:
+				if message.data != "again" {
+					t.Fatal("bad message", message)
+				}
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	t.Fatal("not resubscribed")
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	rd := newTestRedis(t, "")
+	if err := rd.Subscribe(nil, nil, func(interface{}, string) {}); err == nil {
+		t.Error("subscribe without channels should fail")
+	}
+	if err := rd.PSubscribe(nil, []string{"a"}, nil); err == nil {
+		t.Error("subscribe without callback should fail")
+	}
+	if len(rd.subs) != 0 {
+		t.Error("failed subscriptions should not be kept", rd.subs)
+	}
+}
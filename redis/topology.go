@@ -0,0 +1,245 @@
+package redis
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/ssgo/redis"
+	"github.com/ssgo/u"
+)
+
+// makeRedisPool 根据连接地址创建连接池，支持 redis://、redis-sentinel://、redis-cluster:// 和 memory://
+func makeRedisPool(redisUrl string) *redis.Redis {
+	if urlInfo, err := url.Parse(redisUrl); err == nil {
+		switch urlInfo.Scheme {
+		case "redis-sentinel":
+			return newSentinel(urlInfo).pool
+		case "redis-cluster":
+			return newCluster(urlInfo).pool
+		case "memory":
+			return newMemoryPool(urlInfo)
+		}
+	}
+	return redis.GetRedis(redisUrl, nil)
+}
+
+// makeNodeUrl 使用拓扑地址中的密码和参数生成单个节点的连接地址
+func makeNodeUrl(urlInfo *url.URL, addr, db string) string {
+	nodeUrl := url.URL{Scheme: "redis", User: urlInfo.User, Host: addr, Path: "/" + db, RawQuery: urlInfo.RawQuery}
+	return nodeUrl.String()
+}
+
+// newTopologyPool 创建拓扑使用的连接池，连接池的 Dial 会被替换
+// 地址中加上拓扑类型，避免与相同地址的普通连接共用 ssgo/redis 中按地址缓存的连接池
+func newTopologyPool(urlInfo *url.URL, addr, db string) *redis.Redis {
+	query := urlInfo.Query()
+	query.Set("topology", urlInfo.Scheme)
+	nodeUrl := url.URL{Scheme: "redis", User: urlInfo.User, Host: addr, Path: "/" + db, RawQuery: query.Encode()}
+	return redis.GetRedis(nodeUrl.String(), nil)
+}
+
+// newNodePool 创建直接连接单个节点的连接池，使用拓扑连接池的配置（包括密码）
+// 不经过 ssgo/redis 的连接池缓存，节点地址与拓扑地址相同时也不会拿到 Dial 已经被替换的连接池
+func newNodePool(base *redis.Redis, addr string, db int) *redis.Redis {
+	conf := *base.Config
+	conf.Host = addr
+	conf.DB = db
+	return redis.NewRedis(&conf, nil)
+}
+
+func splitHosts(urlInfo *url.URL) []string {
+	hosts := make([]string, 0)
+	for _, host := range strings.Split(urlInfo.Host, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		hosts = append(hosts, "127.0.0.1:6379")
+	}
+	return hosts
+}
+
+// nodeConn 记录连接对应的节点地址，主节点切换后连接池可以丢弃连接到旧节点的连接
+type nodeConn struct {
+	redigo.Conn
+	addr string
+}
+
+func (c *nodeConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return redigo.DoWithTimeout(c.Conn, timeout, cmd, args...)
+}
+
+func (c *nodeConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redigo.ReceiveWithTimeout(c.Conn, timeout)
+}
+
+// sentinel 通过哨兵查找主节点，订阅 +switch-master 事件跟随故障转移
+// 地址格式：redis-sentinel://:password@sentinel1:26379,sentinel2:26379/masterName/db?sentinelPassword=
+type sentinel struct {
+	urlInfo     *url.URL
+	masterName  string
+	db          string
+	sentinels   []string
+	password    string
+	timeout     time.Duration
+	pool        *redis.Redis
+	master      string
+	masterPools map[string]*redis.Redis
+	lock        sync.Mutex
+}
+
+func newSentinel(urlInfo *url.URL) *sentinel {
+	path := strings.Split(strings.Trim(urlInfo.Path, "/"), "/")
+	s := &sentinel{
+		urlInfo:     urlInfo,
+		masterName:  path[0],
+		db:          "0",
+		sentinels:   splitHosts(urlInfo),
+		password:    urlInfo.Query().Get("sentinelPassword"),
+		timeout:     u.Duration(urlInfo.Query().Get("connectTimeout")),
+		masterPools: map[string]*redis.Redis{},
+	}
+	if len(path) > 1 && path[1] != "" {
+		s.db = path[1]
+	}
+	if s.timeout <= 0 {
+		s.timeout = 3 * time.Second
+	}
+
+	// 连接池的配置来自地址中的参数，实际的连接由哨兵查询到的主节点建立
+	s.pool = newTopologyPool(urlInfo, s.sentinels[0], s.db)
+	pool := s.pool.GetPool()
+	pool.Dial = s.dial
+	pool.TestOnBorrow = s.testOnBorrow
+	go s.watch()
+	return s
+}
+
+func (s *sentinel) dial() (redigo.Conn, error) {
+	master, err := s.getMaster(false)
+	if err == nil {
+		var conn redigo.Conn
+		if conn, err = s.masterPool(master).GetPool().Dial(); err == nil {
+			return &nodeConn{Conn: conn, addr: master}, nil
+		}
+	}
+	// 主节点无法连接时重新向哨兵查询，可能已经发生了故障转移
+	if master, err = s.getMaster(true); err != nil {
+		return nil, err
+	}
+	conn, err := s.masterPool(master).GetPool().Dial()
+	if err != nil {
+		return nil, err
+	}
+	return &nodeConn{Conn: conn, addr: master}, nil
+}
+
+func (s *sentinel) testOnBorrow(c redigo.Conn, _ time.Time) error {
+	if nc, ok := c.(*nodeConn); ok {
+		s.lock.Lock()
+		master := s.master
+		s.lock.Unlock()
+		if nc.addr != master {
+			return errors.New("redis master changed")
+		}
+	}
+	return nil
+}
+
+func (s *sentinel) masterPool(addr string) *redis.Redis {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	pool := s.masterPools[addr]
+	if pool == nil {
+		pool = newNodePool(s.pool, addr, s.pool.Config.DB)
+		s.masterPools[addr] = pool
+	}
+	return pool
+}
+
+// getMaster 获取主节点地址，refresh 为 true 时重新向哨兵查询
+func (s *sentinel) getMaster(refresh bool) (string, error) {
+	s.lock.Lock()
+	master := s.master
+	s.lock.Unlock()
+	if master != "" && !refresh {
+		return master, nil
+	}
+
+	err := errors.New("no sentinel available")
+	for _, addr := range s.sentinels {
+		var conn redigo.Conn
+		if conn, err = s.dialSentinel(addr); err != nil {
+			continue
+		}
+		var reply []string
+		reply, err = redigo.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", s.masterName))
+		_ = conn.Close()
+		if err == nil && len(reply) == 2 {
+			master = net.JoinHostPort(reply[0], reply[1])
+			s.setMaster(master)
+			return master, nil
+		}
+		if err == nil {
+			err = errors.New("unknown redis master: " + s.masterName)
+		}
+	}
+	return "", err
+}
+
+func (s *sentinel) setMaster(master string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.master = master
+}
+
+func (s *sentinel) dialSentinel(addr string) (redigo.Conn, error) {
+	return redigo.Dial("tcp", addr,
+		redigo.DialConnectTimeout(s.timeout),
+		redigo.DialReadTimeout(s.timeout),
+		redigo.DialWriteTimeout(s.timeout),
+		redigo.DialPassword(s.password),
+	)
+}
+
+// watch 依次连接哨兵订阅主节点切换事件，连接断开后切换到下一个哨兵
+func (s *sentinel) watch() {
+	for {
+		for _, addr := range s.sentinels {
+			s.watchSentinel(addr)
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+func (s *sentinel) watchSentinel(addr string) {
+	conn, err := s.dialSentinel(addr)
+	if err != nil {
+		return
+	}
+	psc := &redigo.PubSubConn{Conn: conn}
+	defer psc.Close()
+	if err := psc.Subscribe("+switch-master"); err != nil {
+		return
+	}
+	// 重新订阅之前可能错过了切换事件
+	_, _ = s.getMaster(true)
+	for {
+		switch v := psc.ReceiveWithTimeout(0).(type) {
+		case redigo.Message:
+			// 格式：<master name> <old ip> <old port> <new ip> <new port>
+			parts := strings.Split(string(v.Data), " ")
+			if len(parts) == 5 && parts[0] == s.masterName {
+				s.setMaster(net.JoinHostPort(parts[3], parts[4]))
+			}
+		case error:
+			return
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// fakeNode 测试用的 Redis 节点，命令转发到测试服务器的一个数据库中执行，额外实现集群的槽位和重定向以及哨兵命令
+type fakeNode struct {
+	addr     string
+	listener net.Listener
+	backend  string
+	cluster  *fakeCluster
+	master   func() string
+	subs     []*fakeNodeConn
+	lock     sync.Mutex
+}
+
+type fakeNodeConn struct {
+	conn   net.Conn
+	writer *bufio.Writer
+	lock   sync.Mutex
+}
+
+// fakeCluster 槽位分配，owners[slot] 为节点序号，asking[slot] 为正在迁移到的节点序号
+type fakeCluster struct {
+	nodes  []*fakeNode
+	owners []int
+	asking map[int]int
+	lock   sync.Mutex
+}
+
+// newFakeNode 创建一个节点，数据保存在测试服务器的 db 数据库中，没有可用的服务器时跳过测试
+func newFakeNode(t *testing.T, db int) *fakeNode {
+	redisUrl := testServerUrl()
+	if redisUrl == "" {
+		t.Skip("no redis server, set REDIS_TEST_URL or install redis-server")
+	}
+	backend, err := url.Parse(redisUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Path = "/" + strconv.Itoa(db)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := &fakeNode{addr: listener.Addr().String(), listener: listener, backend: backend.String()}
+	if _, err = node.call("FLUSHDB"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go node.serve(&fakeNodeConn{conn: conn, writer: bufio.NewWriter(conn)})
+		}
+	}()
+	return node
+}
+
+// newFakeCluster 创建 n 个节点，槽位平均分配，节点的数据分别保存在测试服务器的 11、12、13... 数据库中
+func newFakeCluster(t *testing.T, n int) *fakeCluster {
+	c := &fakeCluster{owners: make([]int, clusterSlots), asking: map[int]int{}}
+	for i := 0; i < n; i++ {
+		node := newFakeNode(t, 11+i)
+		node.cluster = c
+		c.nodes = append(c.nodes, node)
+	}
+	for slot := range c.owners {
+		c.owners[slot] = slot * n / clusterSlots
+	}
+	return c
+}
+
+func (c *fakeCluster) owner(slot int) (int, int, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	target, migrating := c.asking[slot]
+	return c.owners[slot], target, migrating
+}
+
+// slotsReply 生成 CLUSTER SLOTS 的回复
+func (c *fakeCluster) slotsReply() []interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	out := make([]interface{}, 0)
+	start := 0
+	for slot := 1; slot <= clusterSlots; slot++ {
+		if slot == clusterSlots || c.owners[slot] != c.owners[start] {
+			host, port, _ := net.SplitHostPort(c.nodes[c.owners[start]].addr)
+			out = append(out, []interface{}{int64(start), int64(slot - 1), []interface{}{[]byte(host), int64(fakeAtoi(port))}})
+			start = slot
+		}
+	}
+	return out
+}
+
+func fakeAtoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// call 直接在节点的数据库中执行命令，用来检查和修改节点上的数据
+func (n *fakeNode) call(cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := redigo.DialURL(n.backend)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.Do(cmd, args...)
+}
+
+func (n *fakeNode) serve(c *fakeNodeConn) {
+	defer c.conn.Close()
+	reader := bufio.NewReader(c.conn)
+	conn, err := redigo.DialURL(n.backend)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	asking := false
+	for {
+		args, err := readFakeCommand(reader)
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(string(args[0]))
+		params := make([]interface{}, len(args)-1)
+		for i, arg := range args[1:] {
+			params[i] = arg
+		}
+		var reply interface{}
+		switch {
+		case cmd == "SENTINEL" && n.master != nil:
+			host, port, _ := net.SplitHostPort(n.master())
+			reply = []interface{}{[]byte(host), []byte(port)}
+		case cmd == "SUBSCRIBE" && n.master != nil:
+			// 哨兵的 +switch-master 频道
+			n.lock.Lock()
+			n.subs = append(n.subs, c)
+			n.lock.Unlock()
+			reply = []interface{}{[]byte("subscribe"), args[1], int64(1)}
+		case cmd == "CLUSTER" && n.cluster != nil:
+			reply = n.cluster.slotsReply()
+		case cmd == "ASKING":
+			asking = true
+			reply = "OK"
+		default:
+			if n.cluster != nil {
+				if redirect := n.checkSlot(cmd, params, asking); redirect != nil {
+					reply = redirect
+					break
+				}
+			}
+			asking = false
+			if reply, err = conn.Do(cmd, params...); err != nil {
+				reply = err
+			}
+		}
+		c.write(reply)
+	}
+}
+
+// checkSlot 检查命令中的Key是否属于当前节点，不属于时返回 MOVED 或 ASK，多个Key不在同一个槽位时返回 CROSSSLOT
+func (n *fakeNode) checkSlot(cmd string, args []interface{}, asking bool) interface{} {
+	key, ok := commandKey(cmd, args)
+	if !ok {
+		return nil
+	}
+	slot := keySlot(key)
+	keys := []string{key}
+	switch cmd {
+	case "MGET", "DEL", "EXISTS", "SUNION", "SINTER", "RENAME":
+		for _, arg := range args[1:] {
+			keys = append(keys, keyString(arg))
+		}
+	case "EVAL", "EVALSHA":
+		numKeys := fakeAtoi(keyString(args[1]))
+		for _, arg := range args[3 : 2+numKeys] {
+			keys = append(keys, keyString(arg))
+		}
+	}
+	for _, k := range keys {
+		if keySlot(k) != slot {
+			return redigo.Error("CROSSSLOT Keys in request don't hash to the same slot")
+		}
+	}
+	owner, target, migrating := n.cluster.owner(slot)
+	self := n.cluster.nodes[owner] == n
+	if migrating && n.cluster.nodes[target] == n && asking {
+		return nil
+	}
+	if exists, _ := n.call("EXISTS", key); self && migrating && cmd == "GET" && exists == int64(0) {
+		return redigo.Error(fmt.Sprintf("ASK %d %s", slot, n.cluster.nodes[target].addr))
+	}
+	if !self {
+		return redigo.Error(fmt.Sprintf("MOVED %d %s", slot, n.cluster.nodes[owner].addr))
+	}
+	return nil
+}
+
+// publish 向订阅了哨兵频道的连接推送消息
+func (n *fakeNode) publish(channel, data string) {
+	n.lock.Lock()
+	subs := n.subs
+	n.lock.Unlock()
+	for _, c := range subs {
+		c.write([]interface{}{[]byte("message"), []byte(channel), []byte(data)})
+	}
+}
+
+func (c *fakeNodeConn) write(reply interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	writeFakeReply(c.writer, reply)
+	_ = c.writer.Flush()
+}
+
+func readFakeCommand(reader *bufio.Reader) ([][]byte, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, errors.New("bad command")
+	}
+	args := make([][]byte, fakeAtoi(strings.TrimSpace(line[1:])))
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, fakeAtoi(strings.TrimSpace(line[1:]))+2)
+		if _, err = io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = buf[:len(buf)-2]
+	}
+	return args, nil
+}
+
+func writeFakeReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		_, _ = w.WriteString("$-1\r\n")
+	case string:
+		_, _ = w.WriteString("+" + v + "\r\n")
+	case []byte:
+		_, _ = fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case int64:
+		_, _ = fmt.Fprintf(w, ":%d\r\n", v)
+	case redigo.Error:
+		_, _ = w.WriteString("-" + string(v) + "\r\n")
+	case error:
+		_, _ = w.WriteString("-" + v.Error() + "\r\n")
+	case []interface{}:
+		_, _ = fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeFakeReply(w, item)
+		}
+	default:
+		_, _ = fmt.Fprintf(w, "-ERR unsupported reply %T\r\n", v)
+	}
+}
+
+// withTimeout 在限定时间内执行，用来发现死锁
+func withTimeout(t *testing.T, fn func()) {
+	done := make(chan bool)
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout, maybe deadlock")
+	}
+}
+
+func TestClusterRouting(t *testing.T) {
+	c := newFakeCluster(t, 3)
+	redisUrl := "redis-cluster://" + c.nodes[0].addr + "," + c.nodes[1].addr + "?prefix=app:"
+	rd := newRedis(makeRedisPool(redisUrl), parseConnOption(redisUrl))
+
+	keys := make([]string, 0)
+	withTimeout(t, func() {
+		for i := 0; i < 30; i++ {
+			key := fmt.Sprint("k", i)
+			keys = append(keys, key)
+			if !rd.Set(key, i) {
+				t.Error("set failed", key)
+			}
+		}
+	})
+	for i, key := range keys {
+		if rd.Get(key) != float64(i) {
+			t.Error("get failed", key, rd.Get(key))
+		}
+		// 每个Key都保存在槽位所在的节点上
+		owner, _, _ := c.owner(keySlot("app:" + key))
+		if exists, _ := c.nodes[owner].call("EXISTS", "app:"+key); exists != int64(1) {
+			t.Error("key on wrong node", key)
+		}
+	}
+
+	// KEYS、SCAN、DBSIZE 覆盖所有的主节点
+	sort.Strings(keys)
+	found := rd.Keys("k*")
+	sort.Strings(found)
+	if !reflect.DeepEqual(found, keys) {
+		t.Error("keys not from all nodes", found)
+	}
+	scanned := rd.ScanAll("k*", &map[string]interface{}{"count": 2})
+	sort.Strings(scanned)
+	if !reflect.DeepEqual(scanned, keys) {
+		t.Error("scan not from all nodes", scanned)
+	}
+	if n := rd.DBSize(); n != int64(len(keys)) {
+		t.Error("dbsize not summed over all nodes", n)
+	}
+	if err := rd.OnKeyEvent(nil, nil, "", func(string, string) {}, nil); err == nil {
+		t.Error("key events should be rejected on cluster")
+	}
+
+	// 多Key命令和脚本使用 hash tag
+	if rd.MSet("{u1}:a", 1, "{u1}:b", 2) && !reflect.DeepEqual(rd.MGet("{u1}:a", "{u1}:b"), []interface{}{float64(1), float64(2)}) {
+		t.Error("mget with hash tag failed")
+	}
+	if l, err := rd.Lock(nil, "job", 1000, nil); err != nil || l == nil || l.Fence() != 1 || !l.Unlock() {
+		t.Error("lock failed on cluster", err)
+	}
+	if v := rd.Remember("cached", 10, func() interface{} { return "v" }, &map[string]interface{}{"staleTtl": 5}); v != "v" || rd.Remember("cached", 10, nil, &map[string]interface{}{"staleTtl": 5}) != "v" {
+		t.Error("remember failed on cluster")
+	}
+	// 新旧会话位于不同的槽位
+	session, _ := rd.Session("old", nil)
+	_ = session.Set("user", "u1")
+	if newId, err := session.Regenerate(); err != nil || keySlot("app:session:old") == keySlot("app:session:"+newId) || session.Get("user") != "u1" || rd.Exists("session:old") {
+		t.Error("regenerate failed on cluster", err)
+	}
+}
+
+func TestClusterRedirect(t *testing.T) {
+	c := newFakeCluster(t, 2)
+	redisUrl := "redis-cluster://" + c.nodes[0].addr
+	rd := newRedis(makeRedisPool(redisUrl), parseConnOption(redisUrl))
+	key := "moving"
+	slot := keySlot(key)
+	withTimeout(t, func() { rd.Set(key, "v1") })
+	from, _, _ := c.owner(slot)
+	to := 1 - from
+
+	// 槽位迁移中，已经迁移的Key通过 ASK 读取
+	c.lock.Lock()
+	c.asking[slot] = to
+	c.lock.Unlock()
+	_, _ = c.nodes[to].call("SET", key, "v2")
+	_, _ = c.nodes[from].call("DEL", key)
+	if v := rd.Get(key); v != "v2" {
+		t.Fatal("ask redirect failed", v)
+	}
+
+	// 迁移完成后通过 MOVED 更新槽位
+	c.lock.Lock()
+	delete(c.asking, slot)
+	c.owners[slot] = to
+	c.lock.Unlock()
+	if v := rd.Get(key); v != "v2" {
+		t.Fatal("moved redirect failed", v)
+	}
+	cl := clusterPools[rd.pool.GetPool()]
+	if cl.slotNode(slot) != c.nodes[to].addr {
+		t.Fatal("slot not updated after MOVED")
+	}
+
+	// 管道中的命令也会重定向
+	p := rd.Pipeline()
+	p.Set(key, "v3")
+	p.Get(key)
+	results, err := p.Exec()
+	if err != nil || results[1].Result != "v3" {
+		t.Fatal("pipeline redirect failed", results, err)
+	}
+}
+
+func TestSentinel(t *testing.T) {
+	// 哨兵与主节点使用同一个地址
+	node1 := newFakeNode(t, 11)
+	node2 := newFakeNode(t, 12)
+	var masterLock sync.Mutex
+	master := node1.addr
+	node1.master = func() string {
+		masterLock.Lock()
+		defer masterLock.Unlock()
+		return master
+	}
+
+	redisUrl := "redis-sentinel://" + node1.addr + "/mymaster/0"
+	rd := newRedis(makeRedisPool(redisUrl), parseConnOption(redisUrl))
+	withTimeout(t, func() {
+		if !rd.Set("k", "v1") || rd.Get("k") != "v1" {
+			t.Error("sentinel master failed")
+		}
+	})
+	if v, _ := node1.call("GET", "k"); v == nil {
+		t.Fatal("key not written to master")
+	}
+
+	// 故障转移后连接到新的主节点
+	masterLock.Lock()
+	master = node2.addr
+	masterLock.Unlock()
+	host1, port1, _ := net.SplitHostPort(node1.addr)
+	host2, port2, _ := net.SplitHostPort(node2.addr)
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		node1.publish("+switch-master", strings.Join([]string{"mymaster", host1, port1, host2, port2}, " "))
+		rd.Set("k", "v2")
+		if v, _ := node2.call("GET", "k"); replyString(v) == "v2" {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("failover not followed")
+}
+
+// startRedisServers 启动 n 个本地 redis-server 进程，没有安装 redis-server 时跳过测试
+func startRedisServers(t *testing.T, n int, args ...string) []string {
+	addrs := make([]string, n)
+	for i := range addrs {
+		addr, err := startRedisServer(args...)
+		if err != nil {
+			t.Skip("no redis-server:", err)
+		}
+		addrs[i] = addr
+	}
+	return addrs
+}
+
+func TestRealCluster(t *testing.T) {
+	addrs := startRedisServers(t, 3, "--cluster-enabled", "yes", "--cluster-config-file", "nodes.conf")
+	if out, err := exec.Command("redis-cli", append([]string{"--cluster", "create", "--cluster-yes"}, addrs...)...).CombinedOutput(); err != nil {
+		t.Skip("redis-cli --cluster create failed: ", string(out))
+	}
+	// 等待所有节点同步槽位
+	time.Sleep(2 * time.Second)
+
+	redisUrl := "redis-cluster://" + strings.Join(addrs, ",") + "?prefix=app:"
+	rd := newRedis(makeRedisPool(redisUrl), parseConnOption(redisUrl))
+	withTimeout(t, func() {
+		for i := 0; i < 20; i++ {
+			rd.Set(fmt.Sprint("k", i), i)
+		}
+	})
+	if rd.Get("k7") != float64(7) || len(rd.Keys("k*")) != 20 || len(rd.ScanAll("k*", nil)) != 20 {
+		t.Fatal("cluster keys failed")
+	}
+	if l, err := rd.Lock(nil, "job", 1000, nil); err != nil || l == nil || !l.Unlock() {
+		t.Fatal("lock failed on cluster", err)
+	}
+	if rd.Remember("cached", 10, func() interface{} { return "v" }, &map[string]interface{}{"staleTtl": 5}) != "v" {
+		t.Fatal("remember failed on cluster")
+	}
+}
+
+func TestRealSentinel(t *testing.T) {
+	addrs := startRedisServers(t, 1)
+	host, port, _ := net.SplitHostPort(addrs[0])
+	conf := path.Join(t.TempDir(), "sentinel.conf")
+	_ = os.WriteFile(conf, []byte("sentinel monitor mymaster "+host+" "+port+" 1\n"), 0644)
+	sentinels := startRedisServers(t, 1, "--sentinel", "--include", conf)
+
+	redisUrl := "redis-sentinel://" + sentinels[0] + "/mymaster/0"
+	rd := newRedis(makeRedisPool(redisUrl), parseConnOption(redisUrl))
+	withTimeout(t, func() {
+		if !rd.Set("k", "v") || rd.Get("k") != "v" {
+			t.Error("sentinel master failed")
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package redis
+
+import (
+	"errors"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/ssgo/u"
+)
+
+type Transaction struct {
+	commandQueue
+	rd        *Redis
+	conn      redigo.Conn
+	discarded bool
+}
+
+var defaultTransactionRetries = 3
+
+// Transaction 使用 WATCH + MULTI/EXEC 执行乐观锁事务，回调中的读操作立即执行，写操作在 EXEC 时原子提交
+// Transaction watchKeys 需要监视的Key，在 EXEC 前这些Key被其他连接修改时事务失败
+// Transaction callback 事务回调，参数为事务对象，监视的Key被修改后会重新执行回调
+// Transaction maxRetries 监视的Key被修改后的最大重试次数，默认为3
+// Transaction return 按照加入顺序返回每个写操作的结果，事务被 discard 时返回空
+func (rd *Redis) Transaction(watchKeys []string, callback func(*Transaction), maxRetries *int) ([]PipelineResult, error) {
+	retries := defaultTransactionRetries
+	if maxRetries != nil && *maxRetries >= 0 {
+		retries = *maxRetries
+	}
+	for i := 0; i <= retries; i++ {
+		results, done, err := rd.runTransaction(watchKeys, callback)
+		if err != nil || done {
+			return results, err
+		}
+	}
+	return nil, errors.New("transaction aborted: watched keys were modified")
+}
+
+func (rd *Redis) runTransaction(watchKeys []string, callback func(*Transaction)) ([]PipelineResult, bool, error) {
+	conn, err := rd.getConn()
+	if err != nil {
+		return nil, false, err
+	}
+	// 连接归还时会自动执行 DISCARD 或 UNWATCH
+	defer conn.Close()
+
+	if len(watchKeys) > 0 {
+		if _, err := rd.doOnConn(conn, "WATCH", rd.makeKeys(watchKeys)...); err != nil {
+			return nil, false, err
+		}
+	}
+
+	tx := &Transaction{rd: rd, conn: conn, commandQueue: commandQueue{keyPrefix: rd.keyPrefix, valueCodec: rd.valueCodec}}
+	callback(tx)
+	if tx.discarded {
+		return nil, true, nil
+	}
+	if len(tx.cmds) == 0 {
+		return []PipelineResult{}, true, nil
+	}
+
+	startTime := time.Now()
+	_ = conn.Send("MULTI")
+	for _, c := range tx.cmds {
+		_ = conn.Send(c.cmd, makeRedisArgs(c.args)...)
+	}
+	reply, err := conn.Do("EXEC")
+	rd.logCommand("EXEC", []interface{}{len(tx.cmds)}, startTime, err)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		// 监视的Key被修改，需要重试
+		return nil, false, nil
+	}
+
+	replies, _ := reply.([]interface{})
+	out := make([]PipelineResult, len(tx.cmds))
+	for i, c := range tx.cmds {
+		if i >= len(replies) {
+			break
+		}
+		if e, ok := replies[i].(redigo.Error); ok {
+			out[i].Error = e.Error()
+		} else {
+			out[i].Result = c.decode(replies[i])
+		}
+	}
+	return out, true, nil
+}
+
+// Discard 放弃事务，已经加入队列的写操作都不会执行，也不会重试
+func (tx *Transaction) Discard() {
+	tx.discarded = true
+	tx.cmds = nil
+}
+
+// Read 在事务的连接上立即执行一个读命令，参数原样发送，不会为其中的Key添加前缀
+// Read return 如果是一个对象则返回反序列化后的对象，否则返回字符串
+func (tx *Transaction) Read(cmd string, values ...interface{}) (interface{}, error) {
+	cmd, values = splitCommand(cmd, values)
+	reply, err := tx.rd.doOnConn(tx.conn, cmd, values...)
+	return tx.rd.replyValue(reply), err
+}
+
+// Get 立即读取Key的内容
+func (tx *Transaction) Get(key string) (interface{}, error) {
+	reply, err := tx.rd.doOnConn(tx.conn, "GET", tx.rd.makeKey(key))
+	return tx.rd.replyValue(reply), err
+}
+
+// MGet 立即读取多个Key的内容
+func (tx *Transaction) MGet(keys ...string) ([]interface{}, error) {
+	reply, err := tx.rd.doOnConn(tx.conn, "MGET", tx.rd.makeKeys(keys)...)
+	return tx.rd.replyValues(reply), err
+}
+
+// Exists 立即判断Key是否存在
+func (tx *Transaction) Exists(key string) (bool, error) {
+	reply, err := tx.rd.doOnConn(tx.conn, "EXISTS", tx.rd.makeKey(key))
+	return replyBool(reply), err
+}
+
+// HGet 立即读取哈希表中指定字段的值
+func (tx *Transaction) HGet(key, field string) (interface{}, error) {
+	reply, err := tx.rd.doOnConn(tx.conn, "HGET", tx.rd.makeKey(key), field)
+	return tx.rd.replyValue(reply), err
+}
+
+// HMGet 立即读取哈希表中多个字段的值
+func (tx *Transaction) HMGet(key string, fields ...string) ([]interface{}, error) {
+	reply, err := tx.rd.doOnConn(tx.conn, "HMGET", append([]interface{}{tx.rd.makeKey(key)}, u.ToInterfaceArray(fields)...)...)
+	return tx.rd.replyValues(reply), err
+}
+
+// HGetAll 立即读取哈希表中所有的字段和值
+func (tx *Transaction) HGetAll(key string) (map[string]interface{}, error) {
+	reply, err := tx.rd.doOnConn(tx.conn, "HGETALL", tx.rd.makeKey(key))
+	return tx.rd.replyMap(reply), err
+}
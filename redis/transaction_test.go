@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	rd := newTestRedis(t, "")
+	rd.Set("balance", 10)
+	rd.Set("name", "abc")
+
+	calls := 0
+	results, err := rd.Transaction([]string{"balance"}, func(tx *Transaction) {
+		calls++
+		v, _ := tx.Get("balance")
+		if calls == 1 {
+			// 其他连接修改了监视的Key，EXEC 返回空，重新执行回调
+			rd.Set("balance", 20)
+		}
+		tx.Set("balance", v.(float64)+1)
+		tx.Incr("name")
+		tx.HSet("h", "a", 1)
+	}, nil)
+	if err != nil || calls != 2 {
+		t.Fatal("transaction not retried", calls, err)
+	}
+	expects := []PipelineResult{
+		{Result: true},
+		{Error: "ERR value is not an integer or out of range"},
+		{Result: true},
+	}
+	if !reflect.DeepEqual(results, expects) || rd.Get("balance") != float64(21) || rd.HGet("h", "a") != float64(1) {
+		t.Fatal("bad transaction results", results, rd.Get("balance"))
+	}
+}
+
+func TestTransactionAborted(t *testing.T) {
+	rd := newTestRedis(t, "")
+	rd.Set("balance", 10)
+
+	calls := 0
+	maxRetries := 1
+	results, err := rd.Transaction([]string{"balance"}, func(tx *Transaction) {
+		calls++
+		rd.Incr("balance")
+		tx.Set("balance", 0)
+	}, &maxRetries)
+	if err == nil || results != nil || calls != 2 || rd.Get("balance") != float64(12) {
+		t.Fatal("transaction should abort after retries", calls, results, err, rd.Get("balance"))
+	}
+
+	// 放弃事务后不执行也不重试
+	calls = 0
+	results, err = rd.Transaction([]string{"balance"}, func(tx *Transaction) {
+		calls++
+		rd.Incr("balance")
+		tx.Set("balance", 0)
+		tx.Discard()
+	}, nil)
+	if err != nil || results != nil || calls != 1 || rd.Get("balance") != float64(13) {
+		t.Fatal("discarded transaction executed", calls, results, err)
+	}
+
+	// 没有监视的Key时直接提交
+	results, err = rd.Transaction(nil, func(tx *Transaction) {
+		tx.Do("SET", "plain", "v")
+		tx.Exists("plain")
+	}, nil)
+	if err != nil || len(results) != 1 || results[0].Result != "OK" || rd.Get("plain") != "v" {
+		t.Fatal("bad transaction without watch", results, err)
+	}
+}
@@ -0,0 +1,235 @@
+package redis
+
+import (
+	"sort"
+
+	"github.com/ssgo/u"
+)
+
+type ZMember struct {
+	Member interface{}
+	Score  float64
+}
+
+type zAddOption struct {
+	NX bool
+	XX bool
+	GT bool
+	LT bool
+	CH bool
+}
+
+type zRangeOption struct {
+	ByScore    bool
+	ByLex      bool
+	Rev        bool
+	Offset     int
+	Count      int
+	WithScores bool
+}
+
+type zStoreOption struct {
+	Weights   []float64
+	Aggregate string
+}
+
+func (c valueCodec) makeZMembers(reply interface{}) []ZMember {
+	arr, _ := reply.([]interface{})
+	out := make([]ZMember, 0, len(arr)/2)
+	for i := 0; i+1 < len(arr); i += 2 {
+		out = append(out, ZMember{Member: c.replyValue(arr[i]), Score: replyFloat(arr[i+1])})
+	}
+	return out
+}
+
+// ZAdd 向有序集合添加一个或多个成员，或者更新已存在成员的分数
+// ZAdd members 成员和分数，格式为 {member: score}
+// ZAdd options 选项 {nx, xx, gt, lt, ch}，nx 只添加新成员，xx 只更新已有成员，gt/lt 只在新分数更大/更小时更新，ch 返回变化的成员数
+// ZAdd return 新添加的成员数（指定ch时返回变化的成员数）
+func (rd *Redis) ZAdd(key string, members map[string]float64, options *map[string]interface{}) int64 {
+	opt := zAddOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	args := []interface{}{rd.makeKey(key)}
+	if opt.NX {
+		args = append(args, "NX")
+	} else if opt.XX {
+		args = append(args, "XX")
+	}
+	if opt.GT {
+		args = append(args, "GT")
+	} else if opt.LT {
+		args = append(args, "LT")
+	}
+	if opt.CH {
+		args = append(args, "CH")
+	}
+	names := make([]string, 0, len(members))
+	for member := range members {
+		names = append(names, member)
+	}
+	sort.Strings(names)
+	for _, member := range names {
+		args = append(args, members[member], rd.encodeValue(member))
+	}
+	reply, _ := rd.doRaw("ZADD", args...)
+	return replyInt(reply)
+}
+
+// ZIncrBy 有序集合中对指定成员的分数加上增量 increment
+// ZIncrBy return 成员的新分数
+func (rd *Redis) ZIncrBy(key string, increment float64, member string) float64 {
+	reply, _ := rd.doRaw("ZINCRBY", rd.makeKey(key), increment, rd.encodeValue(member))
+	return replyFloat(reply)
+}
+
+// ZScore 获取有序集合中成员的分数
+// ZScore return 成员的分数，成员不存在时返回null
+func (rd *Redis) ZScore(key, member string) interface{} {
+	reply, _ := rd.doRaw("ZSCORE", rd.makeKey(key), rd.encodeValue(member))
+	if reply == nil {
+		return nil
+	}
+	return replyFloat(reply)
+}
+
+// ZRank 获取有序集合中成员的排名（按分数从小到大，从0开始）
+// ZRank return 成员的排名，成员不存在时返回null
+func (rd *Redis) ZRank(key, member string) interface{} {
+	reply, _ := rd.doRaw("ZRANK", rd.makeKey(key), rd.encodeValue(member))
+	if reply == nil {
+		return nil
+	}
+	return replyInt(reply)
+}
+
+// ZRevRank 获取有序集合中成员的排名（按分数从大到小，从0开始）
+// ZRevRank return 成员的排名，成员不存在时返回null
+func (rd *Redis) ZRevRank(key, member string) interface{} {
+	reply, _ := rd.doRaw("ZREVRANK", rd.makeKey(key), rd.encodeValue(member))
+	if reply == nil {
+		return nil
+	}
+	return replyInt(reply)
+}
+
+// ZRange 获取有序集合指定范围内的成员
+// ZRange start 开始位置，指定byScore时为最小分数（例如 "(1"、"-inf"），指定byLex时为最小成员（例如 "[a"、"-"）
+// ZRange stop 结束位置，含义同start
+// ZRange options 选项 {byScore, byLex, rev, offset, count, withScores}，offset和count只在byScore或byLex时有效
+// ZRange return []any 成员列表，指定withScores时返回 [{member, score}]，如果成员是一个对象则返回反序列化后的对象
+func (rd *Redis) ZRange(key string, start, stop interface{}, options *map[string]interface{}) []interface{} {
+	opt := zRangeOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	args := []interface{}{rd.makeKey(key), start, stop}
+	if opt.ByScore {
+		args = append(args, "BYSCORE")
+	} else if opt.ByLex {
+		args = append(args, "BYLEX")
+	}
+	if opt.Rev {
+		args = append(args, "REV")
+	}
+	if (opt.ByScore || opt.ByLex) && (opt.Offset > 0 || opt.Count > 0) {
+		count := opt.Count
+		if count == 0 {
+			count = -1
+		}
+		args = append(args, "LIMIT", opt.Offset, count)
+	}
+	if opt.WithScores {
+		args = append(args, "WITHSCORES")
+	}
+	reply, _ := rd.doRaw("ZRANGE", args...)
+	if opt.WithScores {
+		members := rd.makeZMembers(reply)
+		out := make([]interface{}, len(members))
+		for i, m := range members {
+			out[i] = m
+		}
+		return out
+	}
+	return rd.replyValues(reply)
+}
+
+// ZRem 移除有序集合中的一个或多个成员
+// ZRem return 成功移除的个数
+func (rd *Redis) ZRem(key string, members ...string) int64 {
+	reply, _ := rd.doRaw("ZREM", append([]interface{}{rd.makeKey(key)}, rd.encodeValues(u.ToInterfaceArray(members))...)...)
+	return replyInt(reply)
+}
+
+// ZRemRangeByScore 移除有序集合中分数在指定区间内的成员
+// ZRemRangeByScore min 最小分数，例如 "-inf"、"(1"、10
+// ZRemRangeByScore return 成功移除的个数
+func (rd *Redis) ZRemRangeByScore(key string, min, max interface{}) int64 {
+	reply, _ := rd.doRaw("ZREMRANGEBYSCORE", rd.makeKey(key), min, max)
+	return replyInt(reply)
+}
+
+// ZCard 获取有序集合的成员数
+func (rd *Redis) ZCard(key string) int64 {
+	reply, _ := rd.doRaw("ZCARD", rd.makeKey(key))
+	return replyInt(reply)
+}
+
+// ZCount 获取有序集合中分数在指定区间内的成员数
+func (rd *Redis) ZCount(key string, min, max interface{}) int64 {
+	reply, _ := rd.doRaw("ZCOUNT", rd.makeKey(key), min, max)
+	return replyInt(reply)
+}
+
+// ZPopMin 移除并返回有序集合中分数最低的成员
+// ZPopMin count 数量，默认为1
+// ZPopMin return [{member, score}]
+func (rd *Redis) ZPopMin(key string, count *int) []ZMember {
+	return rd.zPop("ZPOPMIN", key, count)
+}
+
+// ZPopMax 移除并返回有序集合中分数最高的成员
+// ZPopMax return [{member, score}]
+func (rd *Redis) ZPopMax(key string, count *int) []ZMember {
+	return rd.zPop("ZPOPMAX", key, count)
+}
+
+func (rd *Redis) zPop(cmd, key string, count *int) []ZMember {
+	args := []interface{}{rd.makeKey(key)}
+	if count != nil {
+		args = append(args, *count)
+	}
+	reply, _ := rd.doRaw(cmd, args...)
+	return rd.makeZMembers(reply)
+}
+
+// ZUnionStore 计算多个有序集合的并集并存储到 destination
+// ZUnionStore options 选项 {weights, aggregate}，aggregate 可以是 SUM、MIN、MAX
+// ZUnionStore return 结果集合的成员数
+func (rd *Redis) ZUnionStore(destination string, keys []string, options *map[string]interface{}) int64 {
+	return rd.zStore("ZUNIONSTORE", destination, keys, options)
+}
+
+// ZInterStore 计算多个有序集合的交集并存储到 destination
+// ZInterStore return 结果集合的成员数
+func (rd *Redis) ZInterStore(destination string, keys []string, options *map[string]interface{}) int64 {
+	return rd.zStore("ZINTERSTORE", destination, keys, options)
+}
+
+func (rd *Redis) zStore(cmd, destination string, keys []string, options *map[string]interface{}) int64 {
+	opt := zStoreOption{}
+	if options != nil {
+		u.Convert(*options, &opt)
+	}
+	args := append([]interface{}{rd.makeKey(destination), len(keys)}, rd.makeKeys(keys)...)
+	if len(opt.Weights) > 0 {
+		args = append(args, "WEIGHTS")
+		args = append(args, u.ToInterfaceArray(opt.Weights)...)
+	}
+	if opt.Aggregate != "" {
+		args = append(args, "AGGREGATE", opt.Aggregate)
+	}
+	reply, _ := rd.doRaw(cmd, args...)
+	return replyInt(reply)
+}
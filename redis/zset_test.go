@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestZSet(t *testing.T) {
+	rd := newTestRedis(t, "")
+	if n := rd.ZAdd("z", map[string]float64{"a": 1, "b": 2, "c": 3}, nil); n != 3 {
+		t.Fatal("bad zadd", n)
+	}
+	// nx 只添加新成员，ch 返回变化的成员数
+	if n := rd.ZAdd("z", map[string]float64{"a": 10, "d": 4}, &map[string]interface{}{"nx": true, "ch": true}); n != 1 || rd.ZScore("z", "a") != float64(1) {
+		t.Fatal("bad zadd nx", n, rd.ZScore("z", "a"))
+	}
+	if n := rd.ZAdd("z", map[string]float64{"a": 0.5, "b": 5}, &map[string]interface{}{"gt": true, "ch": true}); n != 1 {
+		t.Fatal("bad zadd gt", n)
+	}
+	if rd.ZIncrBy("z", 1.5, "a") != 2.5 || rd.ZScore("z", "none") != nil {
+		t.Fatal("bad zincrby or zscore")
+	}
+	if rd.ZRank("z", "a") != int64(0) || rd.ZRevRank("z", "a") != int64(3) || rd.ZRank("z", "none") != nil {
+		t.Fatal("bad rank", rd.ZRank("z", "a"), rd.ZRevRank("z", "a"))
+	}
+	if rd.ZCard("z") != 4 || rd.ZCount("z", "(2.5", "+inf") != 3 {
+		t.Fatal("bad count", rd.ZCard("z"), rd.ZCount("z", "(2.5", "+inf"))
+	}
+
+	tests := []struct {
+		start, stop interface{}
+		options     map[string]interface{}
+		expect      []interface{}
+	}{
+		{0, -1, nil, []interface{}{"a", "c", "d", "b"}},
+		{0, 1, map[string]interface{}{"rev": true}, []interface{}{"b", "d"}},
+		{"(2.5", 4, map[string]interface{}{"byScore": true, "withScores": true}, []interface{}{ZMember{"c", 3}, ZMember{"d", 4}}},
+		{"-inf", "+inf", map[string]interface{}{"byScore": true, "offset": 1, "count": 2}, []interface{}{"c", "d"}},
+	}
+	for _, test := range tests {
+		if out := rd.ZRange("z", test.start, test.stop, &test.options); !reflect.DeepEqual(out, test.expect) {
+			t.Error("bad zrange", test.start, test.stop, test.options, out)
+		}
+	}
+
+	count := 2
+	if out := rd.ZPopMin("z", &count); !reflect.DeepEqual(out, []ZMember{{"a", 2.5}, {"c", 3}}) {
+		t.Error("bad zpopmin", out)
+	}
+	if out := rd.ZPopMax("z", nil); !reflect.DeepEqual(out, []ZMember{{"b", 5}}) {
+		t.Error("bad zpopmax", out)
+	}
+	if rd.ZRem("z", "d", "none") != 1 || rd.ZCard("z") != 0 {
+		t.Error("bad zrem")
+	}
+
+	rd.ZAdd("z1", map[string]float64{"a": 1, "b": 2}, nil)
+	rd.ZAdd("z2", map[string]float64{"b": 3, "c": 4}, nil)
+	if n := rd.ZUnionStore("u", []string{"z1", "z2"}, &map[string]interface{}{"weights": []float64{1, 2}, "aggregate": "MAX"}); n != 3 || rd.ZScore("u", "b") != float64(6) {
+		t.Error("bad zunionstore", n, rd.ZScore("u", "b"))
+	}
+	if n := rd.ZInterStore("i", []string{"z1", "z2"}, nil); n != 1 || rd.ZScore("i", "b") != float64(5) {
+		t.Error("bad zinterstore", n, rd.ZScore("i", "b"))
+	}
+	if rd.ZRemRangeByScore("u", "-inf", 6) != 2 || rd.ZCard("u") != 1 {
+		t.Error("bad zremrangebyscore")
+	}
+}
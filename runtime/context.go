@@ -0,0 +1,39 @@
+package runtime
+
+import (
+	"sync"
+
+	"github.com/api-go/plugin"
+)
+
+// destructorsKey 析构函数在Context中的数据名称
+const destructorsKey = "_destructors"
+
+var destructorsLock sync.Mutex
+
+// AddDestructor 向Context注册析构函数，插件用它在脚本结束时停止订阅、释放锁等后台资源
+// ctx 为nil时不注册，调用者需要自己释放资源
+func AddDestructor(ctx *plugin.Context, destructor func()) {
+	if ctx == nil || destructor == nil {
+		return
+	}
+	destructorsLock.Lock()
+	defer destructorsLock.Unlock()
+	destructors, _ := ctx.GetData(destructorsKey).([]func())
+	ctx.SetData(destructorsKey, append(destructors, destructor))
+}
+
+// RunDestructors 按照注册的相反顺序调用并清除Context中的析构函数，运行脚本的程序在脚本结束时调用
+// 例如：defer runtime.RunDestructors(ctx)
+func RunDestructors(ctx *plugin.Context) {
+	if ctx == nil {
+		return
+	}
+	destructorsLock.Lock()
+	destructors, _ := ctx.GetData(destructorsKey).([]func())
+	ctx.SetData(destructorsKey, nil)
+	destructorsLock.Unlock()
+	for i := len(destructors) - 1; i >= 0; i-- {
+		destructors[i]()
+	}
+}
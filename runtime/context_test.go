@@ -0,0 +1,23 @@
+package runtime
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/api-go/plugin"
+)
+
+func TestDestructors(t *testing.T) {
+	ctx := plugin.NewContext(nil)
+	calls := make([]int, 0)
+	AddDestructor(ctx, func() { calls = append(calls, 1) })
+	AddDestructor(ctx, func() { calls = append(calls, 2) })
+	AddDestructor(nil, func() { calls = append(calls, 3) })
+
+	RunDestructors(ctx)
+	RunDestructors(ctx)
+	RunDestructors(nil)
+	if !reflect.DeepEqual(calls, []int{2, 1}) {
+		t.Fatal("bad destructor calls", calls)
+	}
+}